@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"human-git-history/internal/cache"
+	"human-git-history/internal/git"
+
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the commit cache",
+	Long: `Parsed commits are cached under .git/git-history (or the user cache
+directory) so later runs only process new commits.`,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Delete the commit cache for this repository",
+	Run: func(cmd *cobra.Command, args []string) {
+		gitDir, err := git.CommonDir("")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding repository: %v\n", err)
+			exit(1)
+		}
+
+		if err := cache.Clear(gitDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error clearing cache: %v\n", err)
+			exit(1)
+		}
+		fmt.Println("✅ Commit cache cleared")
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"human-git-history/internal/formatter"
+)
+
+var (
+	colorMode  string
+	colorTheme string
+)
+
+// setColor applies --color and --color-theme. Without --color, a set
+// NO_COLOR turns colours off, and otherwise git's color.ui decides, as it
+// does for git log. In auto mode output is coloured when terminal says it
+// goes to a terminal, pager or not.
+func setColor(terminal bool) {
+	mode := colorMode
+	if mode == "" {
+		mode = "auto"
+		if os.Getenv("NO_COLOR") != "" {
+			mode = "never"
+		} else if ui, ok := gitConfig("color.ui"); ok {
+			mode = ui
+		}
+	}
+
+	switch strings.ToLower(mode) {
+	case "always":
+		formatter.SetColor(true)
+	case "never", "false", "no", "off", "0":
+		formatter.SetColor(false)
+	case "auto", "true", "yes", "on", "1":
+		formatter.SetColor(terminal && os.Getenv("TERM") != "dumb")
+	default:
+		fmt.Fprintf(os.Stderr, "Error setting colors: unknown color mode %q (use auto, always or never)\n", mode)
+		exit(1)
+	}
+
+	if err := formatter.SetTheme(colorTheme); err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting colors: %v (use %s)\n", err, strings.Join(formatter.Themes(), ", "))
+		exit(1)
+	}
+}
+
+// isColorMode reports whether word is a value --color takes.
+func isColorMode(word string) bool {
+	switch strings.ToLower(word) {
+	case "always", "never", "false", "no", "off", "0", "auto", "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+// gitConfig returns the value of a git configuration key, if it is set.
+func gitConfig(key string) (string, bool) {
+	out, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(out)), true
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestSetColor(t *testing.T) {
+	defer func(saved bool) { color.NoColor = saved }(color.NoColor)
+	defer func(saved string) { colorMode = saved }(colorMode)
+
+	for _, test := range []struct {
+		name     string
+		mode     string
+		env      map[string]string
+		config   map[string]string
+		terminal bool
+		want     bool
+	}{
+		{name: "auto on a terminal", terminal: true, want: true},
+		{name: "auto into a pipe", terminal: false, want: false},
+		{name: "auto on a dumb terminal", env: map[string]string{"TERM": "dumb"}, terminal: true, want: false},
+		{name: "always into a pipe", mode: "always", terminal: false, want: true},
+		{name: "never on a terminal", mode: "never", terminal: true, want: false},
+		{name: "NO_COLOR", env: map[string]string{"NO_COLOR": "1"}, terminal: true, want: false},
+		{name: "empty NO_COLOR", env: map[string]string{"NO_COLOR": ""}, terminal: true, want: true},
+		{name: "--color over NO_COLOR", mode: "always", env: map[string]string{"NO_COLOR": "1"}, want: true},
+		{name: "NO_COLOR over color.ui", env: map[string]string{"NO_COLOR": "1"}, config: map[string]string{"color.ui": "always"}, terminal: true, want: false},
+		{name: "color.ui always", config: map[string]string{"color.ui": "always"}, terminal: false, want: true},
+		{name: "color.ui false", config: map[string]string{"color.ui": "false"}, terminal: true, want: false},
+		{name: "color.ui auto", config: map[string]string{"color.ui": "auto"}, terminal: false, want: false},
+		{name: "--color over color.ui", mode: "auto", config: map[string]string{"color.ui": "never"}, terminal: true, want: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			isolateGit(t, test.config)
+			unsetenv(t, "NO_COLOR")
+			t.Setenv("TERM", "xterm")
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			colorMode = test.mode
+			setColor(test.terminal)
+			if got := !color.NoColor; got != test.want {
+				t.Errorf("colors on = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"human-git-history/internal/git"
+)
+
+var identityMap string
+
+// identities reads the repository's .mailmap and the file set with
+// git config mailmap.file, unless log.mailmap is false, followed by the
+// --identity-map file, which may merge the identities they produce.
+func identities() (git.Identities, error) {
+	var files []string
+	if enabled, ok := gitConfig("log.mailmap"); !ok || enabled != "false" {
+		if out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output(); err == nil {
+			files = append(files, filepath.Join(strings.TrimSpace(string(out)), ".mailmap"))
+		}
+		if file, ok := gitConfig("mailmap.file"); ok {
+			files = append(files, file)
+		}
+	}
+
+	var ids git.Identities
+	for _, file := range files {
+		m, err := git.LoadIdentityMap(file, git.ParseMailmap)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			ids = append(ids, m)
+		}
+	}
+	if identityMap != "" {
+		m, err := git.LoadIdentityMap(identityMap, git.ParseIdentityMap)
+		if err != nil {
+			return nil, err
+		}
+		if m == nil {
+			return nil, fmt.Errorf("identity map %s does not exist", identityMap)
+		}
+		ids = append(ids, m)
+	}
+	return ids, nil
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"human-git-history/internal/term"
+)
+
+// output is where the root command writes its listings: standard output,
+// or a pager when standard output is a terminal.
+var output = newPagedOutput()
+
+// isTerminal reports whether standard output is a terminal, which is when
+// output is paged.
+var isTerminal = term.IsTerminal
+
+// pagedOutput starts the pager on the first write, so commands that fail
+// before printing anything never open one. Closed tells the history walk
+// to stop once the pager has quit.
+type pagedOutput struct {
+	once   sync.Once
+	w      io.Writer
+	pipe   *os.File
+	cmd    *exec.Cmd
+	Closed chan struct{}
+}
+
+func newPagedOutput() *pagedOutput {
+	return &pagedOutput{Closed: make(chan struct{})}
+}
+
+func (p *pagedOutput) Write(b []byte) (int, error) {
+	p.once.Do(p.start)
+	return p.w.Write(b)
+}
+
+func (p *pagedOutput) start() {
+	p.w = os.Stdout
+	if noPager || !isTerminal(os.Stdout) {
+		return
+	}
+	command := pagerCommand()
+	if command == "" || command == "cat" {
+		return
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// Like git: quit when the output fits on one screen, pass colours
+	// through and leave the output on the screen.
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if _, ok := os.LookupEnv("LV"); !ok {
+		cmd.Env = append(cmd.Env, "LV=-c")
+	}
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return
+	}
+	r.Close()
+
+	p.w, p.pipe, p.cmd = w, w, cmd
+	go func() {
+		cmd.Wait()
+		close(p.Closed)
+	}()
+}
+
+// Close ends the output and waits for the user to quit the pager.
+func (p *pagedOutput) Close() {
+	if p.pipe == nil {
+		return
+	}
+	p.pipe.Close()
+	<-p.Closed
+}
+
+// pagerCommand picks the pager the way git does, with GIT_HISTORY_PAGER
+// taking precedence over git's own settings.
+func pagerCommand() string {
+	if pager, ok := os.LookupEnv("GIT_HISTORY_PAGER"); ok {
+		return strings.TrimSpace(pager)
+	}
+	if pager, ok := gitConfig("core.pager"); ok {
+		return pager
+	}
+	if pager, ok := os.LookupEnv("PAGER"); ok {
+		return strings.TrimSpace(pager)
+	}
+	return "less -R"
+}
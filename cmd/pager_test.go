@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// isolateGit keeps the user's and the system's git configuration out of a
+// test, which can then set its own with config.
+func isolateGit(t *testing.T, config map[string]string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	i := 0
+	for key, value := range config {
+		t.Setenv("GIT_CONFIG_KEY_"+strconv.Itoa(i), key)
+		t.Setenv("GIT_CONFIG_VALUE_"+strconv.Itoa(i), value)
+		i++
+	}
+	t.Setenv("GIT_CONFIG_COUNT", strconv.Itoa(i))
+}
+
+// unsetenv removes a variable for the rest of a test.
+func unsetenv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func TestPagerCommand(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		env    map[string]string
+		config map[string]string
+		want   string
+	}{
+		{"default", nil, nil, "less -R"},
+		{"PAGER", map[string]string{"PAGER": " more "}, nil, "more"},
+		{"core.pager over PAGER", map[string]string{"PAGER": "more"}, map[string]string{"core.pager": "most -s"}, "most -s"},
+		{"GIT_HISTORY_PAGER over everything", map[string]string{"PAGER": "more", "GIT_HISTORY_PAGER": "pg"}, map[string]string{"core.pager": "most"}, "pg"},
+		{"empty GIT_HISTORY_PAGER", map[string]string{"PAGER": "more", "GIT_HISTORY_PAGER": ""}, nil, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			isolateGit(t, test.config)
+			unsetenv(t, "PAGER")
+			unsetenv(t, "GIT_HISTORY_PAGER")
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			if got := pagerCommand(); got != test.want {
+				t.Errorf("pagerCommand() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// pagedWrite writes through a new pagedOutput, as if standard output were
+// a terminal when terminal is set, and reports whether a pager ran.
+func pagedWrite(t *testing.T, terminal bool, text string) bool {
+	t.Helper()
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	saved, savedTerminal := os.Stdout, isTerminal
+	os.Stdout, isTerminal = stdout, func(*os.File) bool { return terminal }
+	defer func() { os.Stdout, isTerminal = saved, savedTerminal }()
+
+	p := newPagedOutput()
+	if _, err := p.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	return p.cmd != nil
+}
+
+func TestPagedOutput(t *testing.T) {
+	isolateGit(t, nil)
+	out := filepath.Join(t.TempDir(), "paged")
+	unsetenv(t, "LESS")
+	t.Setenv("GIT_HISTORY_PAGER", `{ echo "LESS=$LESS"; tr a-z A-Z; } > '`+out+`'`)
+
+	if !pagedWrite(t, true, "paged\n") {
+		t.Fatal("no pager ran on a terminal")
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "LESS=FRX\nPAGED\n"; got != want {
+		t.Errorf("the pager read %q, want %q", got, want)
+	}
+
+	if pagedWrite(t, false, "plain\n") {
+		t.Error("a pager ran when standard output is not a terminal")
+	}
+	for _, pager := range []string{"cat", ""} {
+		t.Setenv("GIT_HISTORY_PAGER", pager)
+		if pagedWrite(t, true, "plain\n") {
+			t.Errorf("the pager %q ran", pager)
+		}
+	}
+
+	t.Setenv("GIT_HISTORY_PAGER", "cat > '"+out+"'")
+	noPager = true
+	defer func() { noPager = false }()
+	if pagedWrite(t, true, "plain\n") {
+		t.Error("a pager ran with --no-pager")
+	}
+}
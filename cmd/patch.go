@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"human-git-history/internal/formatter"
+	"human-git-history/internal/git"
+)
+
+var (
+	patchMode string
+	hunkLines int
+)
+
+// patchOptions applies --patch and --hunk-lines. The mode is empty when
+// no diffs are wanted.
+func patchOptions() formatter.PatchOptions {
+	if patchMode != "" && !isPatchMode(patchMode) {
+		fmt.Fprintf(os.Stderr, "Error: unknown --patch mode %q (use %s)\n", patchMode, strings.Join(formatter.PatchModes(), ", "))
+		exit(1)
+	}
+	return formatter.PatchOptions{Mode: patchMode, HunkLines: max(hunkLines, 0)}
+}
+
+func isPatchMode(word string) bool {
+	for _, mode := range formatter.PatchModes() {
+		if word == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// withPatchFiles makes commits list their file changes when --patch is
+// limited to the paths options select, so patchSource can find them.
+func withPatchFiles(options git.CommitOptions) git.CommitOptions {
+	if patchMode != "" && (len(options.Paths) > 0 || len(options.ExcludePaths) > 0) {
+		options.ShowFileChanges = true
+	}
+	return options
+}
+
+// patchSource reads the diff --patch shows for a commit: all of it, or,
+// as git log -p does with pathspecs, only the files the path filters let
+// through.
+func patchSource(repo git.Repository, options git.CommitOptions) func(commit git.Commit) (string, error) {
+	return func(commit git.Commit) (string, error) {
+		if len(options.Paths) == 0 && len(options.ExcludePaths) == 0 {
+			return repo.Patch(commit.Hash)
+		}
+		var files []string
+		for _, change := range commit.FileChanges {
+			files = append(files, change.FilePath)
+			if change.OldPath != "" {
+				files = append(files, change.OldPath)
+			}
+		}
+		if len(files) == 0 {
+			return "", nil
+		}
+		return repo.Patch(commit.Hash, files...)
+	}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"human-git-history/internal/formatter"
+	"human-git-history/internal/tui"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pickMulti bool
+	pickQuery string
+)
+
+var pickCmd = &cobra.Command{
+	Use:   "pick [flags] [<revision-range>...] [--] [<path>...]",
+	Short: "Pick commits with a fuzzy finder and print their hashes",
+	Long: `Pick commits with a fuzzy finder and print their hashes, for use in
+scripts: git rebase -i $(git-history pick)^ or git show $(git-history pick).
+
+Type to narrow the list: the compact line of each commit is matched
+fuzzily, every space-separated term has to match and lower-case terms
+ignore case. The commit under the cursor is previewed beside the list.
+Enter prints its hash; with --multi, Tab marks several commits and Enter
+prints theirs, one per line, newest first. Escape prints nothing and
+exits with status 130. The finder draws on the terminal, so standard
+output can be captured. History is read as far as it goes unless
+--limit is given.`,
+	Args: cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo, err := openRepository()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening repository: %v\n", err)
+			exit(1)
+		}
+
+		options := commitOptions(cmd, args)
+		if !cmd.Flags().Changed("limit") {
+			options.Limit = 0
+		}
+		options.ShowFileChanges = true
+		// The finder draws on the terminal, wherever standard output goes.
+		setColor(true)
+		if err := formatter.SetDateMode(dateMode); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v (use %s)\n", err, strings.Join(formatter.DateModes(), ", "))
+			exit(1)
+		}
+
+		picked, err := tui.Pick(repo, options, tui.PickOptions{Multi: pickMulti, Query: pickQuery})
+		switch {
+		case errors.Is(err, tui.ErrCancelled):
+			exit(130)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		case len(picked) == 0:
+			exit(1)
+		}
+		for _, commit := range picked {
+			fmt.Println(commit.Hash)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pickCmd)
+
+	pickCmd.Flags().BoolVarP(&pickMulti, "multi", "m", false, "Mark several commits with Tab and print every marked hash")
+	pickCmd.Flags().StringVarP(&pickQuery, "query", "q", "", "Start with this query")
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"human-git-history/internal/changelog"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	releaseFrom string
+	releaseTo   string
+)
+
+var releaseNotesCmd = &cobra.Command{
+	Use:   "release-notes [flags] [--] [<path>...]",
+	Short: "Write Markdown release notes for the changes since the last tag",
+	Long: `Write Markdown release notes for the commits between two revisions:
+by default from the latest tag to HEAD. When --to is tagged, the notes
+are for that release, since the tag before it. Changes are grouped by their
+Conventional Commit type, followed by contributors and stats, with the
+next semantic version suggested from the kinds of changes.`,
+	Args: cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		options := commitOptions(cmd, args)
+		// The range is --from..--to; revisions from arguments or --branch
+		// would silently add their history to it.
+		conflicts := options.Revisions
+		if options.Branch != "" {
+			conflicts = append(conflicts, "--branch "+options.Branch)
+		}
+		if len(conflicts) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: release-notes takes its range from --from and --to, not %s (paths go after \"--\")\n", strings.Join(conflicts, ", "))
+			exit(1)
+		}
+
+		repo, err := openRepository()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening repository: %v\n", err)
+			exit(1)
+		}
+
+		from, release, err := changelog.ReleaseRange(repo, releaseFrom, releaseTo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding the latest tag: %v\n", err)
+			exit(1)
+		}
+
+		// A release covers every commit, whatever the default --limit.
+		if !cmd.Flags().Changed("limit") {
+			options.Limit = 0
+		}
+		options.ShowFileChanges = true
+		if from != "" {
+			options.Revisions = []string{from + ".." + releaseTo}
+		} else {
+			options.Revisions = []string{releaseTo}
+		}
+
+		commits, err := repo.Log(options)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting commits: %v\n", err)
+			exit(1)
+		}
+		if err := changelog.WriteNotes(output, changelog.NewNotes(from, release, commits)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing release notes: %v\n", err)
+			exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(releaseNotesCmd)
+
+	releaseNotesCmd.Flags().StringVar(&releaseFrom, "from", "", "Previous release (default: the latest tag before --to)")
+	releaseNotesCmd.Flags().StringVar(&releaseTo, "to", "HEAD", "Revision being released")
+}
@@ -1,10 +1,20 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"human-git-history/internal/cache"
+	"human-git-history/internal/changelog"
+	"human-git-history/internal/export"
 	"human-git-history/internal/formatter"
 	"human-git-history/internal/git"
+	"human-git-history/internal/term"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
 
 	"github.com/spf13/cobra"
 )
@@ -20,72 +30,402 @@ var (
 	showStats  bool
 	showFiles  bool // New flag
 	graph      bool
+	graphStyle string
 	mergesOnly bool
 	noMerges   bool
+	backend    string
+	noCache    bool
+	noPager    bool
+
+	renameThreshold int
+	copyThreshold   int
+	noRenames       bool
+
+	paths        []string
+	excludePaths []string
+	follow       bool
+	firstParent  bool
+
+	grepPatterns []string
+	invertGrep   bool
+	ignoreCase   bool
+	pickaxe      string
+	pickaxeRegex string
+
+	templateFile string
+	groupBy      string
+	dateMode     string
+	sortOrder    string
 )
 
+// commitCache is the on-disk cache opened by openRepository, if any.
+var commitCache *cache.Store
+
 var rootCmd = &cobra.Command{
-	Use:   "git-history",
+	Use:   "git-history [flags] [<revision-range>...] [--] [<path>...]",
 	Short: "A human-friendly git history viewer",
 	Long: `A CLI tool that presents git history in a more readable,
 human-friendly format with various display options.`,
+	Args: cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		commits, err := git.GetCommits(git.CommitOptions{
-			Limit:          limit,
-			Author:         author,
-			Since:          since,
-			Until:          until,
-			Branch:         branch,
-			MergesOnly:     mergesOnly,
-			NoMerges:       noMerges,
-			ShowFileChanges: showFiles,
-		})
+		repo, err := openRepository()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening repository: %v\n", err)
+			exit(1)
+		}
+
+		options := withPatchFiles(commitOptions(cmd, args))
+		setColor(term.IsTerminal(os.Stdout))
+		setHighlight(options)
+		if err := formatter.SetDateMode(dateMode); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v (use %s)\n", err, strings.Join(formatter.DateModes(), ", "))
+			exit(1)
+		}
+		tmpl, err := formatter.ParseTemplate(format, templateFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading template: %v\n", err)
+			exit(1)
+		}
+		tags := releaseTags(repo)
+
+		switch {
+		// Machine-readable formats carry the whole commit, file changes
+		// included, and stream regardless of the layout flags.
+		case export.IsFormat(format):
+			options.ShowFileChanges = true
+			streamCommits(repo, options, func(it git.CommitIterator) error {
+				return export.Stream(output, it, export.Options{
+					Format: format,
+					Range:  git.DescribeRange(options),
+				})
+			})
+			return
+		// The Markdown changelog is a document of its own, without a graph.
+		case format == "changelog-md":
+			commits, err := repo.Log(options)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error getting commits: %v\n", err)
+				exit(1)
+			}
+			if err := changelog.WriteMarkdown(output, releases(commits, tags), git.DescribeRange(options)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing changelog: %v\n", err)
+				exit(1)
+			}
+			return
+		// Without the graph, commits are printed as soon as they are read.
+		case !graph:
+			streamCommits(repo, options, func(it git.CommitIterator) error {
+				return renderer().Stream(it, outputOptions(repo, options, tmpl, tags))
+			})
+			return
+		}
+
+		commits, err := repo.Log(options)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error getting commits: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
-		switch format {
-		case "detailed":
-			formatter.PrintDetailed(commits, showStats, graph, showFiles)
-		case "compact":
-			formatter.PrintCompact(commits, showFiles)
-		case "oneline":
-			formatter.PrintOneline(commits, showFiles)
-		case "changelog":
-			formatter.PrintChangelog(commits, showFiles)
-		default:
-			formatter.PrintHumanFriendly(commits, compact, showStats, graph, showFiles)
+		r := renderer()
+		if tmpl == nil {
+			r.PrintRangeHeader(format, git.DescribeRange(options))
+		}
+		if err := r.PrintGraph(commits, outputOptions(repo, options, tmpl, tags)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error printing commits: %v\n", err)
+			exit(1)
 		}
 	},
 }
 
+// renderer prints to the pager or standard output, fitted to the terminal's width.
+func renderer() *formatter.Renderer {
+	return formatter.NewRenderer(output, term.Width(os.Stdout))
+}
+
+// outputOptions are the formatter settings chosen on the command line.
+func outputOptions(repo git.Repository, options git.CommitOptions, tmpl *template.Template, tags []git.Tag) formatter.Options {
+	return formatter.Options{
+		Format:     format,
+		Compact:    compact,
+		ShowStats:  showStats,
+		ShowFiles:  showFiles,
+		Range:      git.DescribeRange(options),
+		Template:   tmpl,
+		GraphStyle: graphStyle,
+		ByTag:      groupBy == "tag",
+		Tags:       tags,
+		Patch:      patchOptions(),
+		Diff:       patchSource(repo, options),
+	}
+}
+
+// releaseTags are the tags changelogs split at with --group-by tag; nil
+// when they are split by day.
+func releaseTags(repo git.Repository) []git.Tag {
+	switch groupBy {
+	case "day":
+		return nil
+	case "tag":
+		tags, err := repo.Tags()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading tags: %v\n", err)
+			exit(1)
+		}
+		return tags
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --group-by %q (use day or tag)\n", groupBy)
+		exit(1)
+		return nil
+	}
+}
+
+// releases splits commits into the sections of a changelog.
+func releases(commits []git.Commit, tags []git.Tag) []changelog.Release {
+	if groupBy == "tag" {
+		return changelog.ByTag(commits, tags)
+	}
+	return changelog.ByDay(commits)
+}
+
+// streamCommits reads history with repo.Stream and hands it to show, which
+// prints each commit as it arrives. Ctrl-C or quitting the pager stops the
+// listing quietly.
+func streamCommits(repo git.Repository, options git.CommitOptions, show func(git.CommitIterator) error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// Quitting the pager early stops reading history too.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-output.Closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	it, err := repo.Stream(ctx, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting commits: %v\n", err)
+		exit(1)
+	}
+	defer it.Close()
+
+	if err := show(it); err != nil && ctx.Err() == nil {
+		fmt.Fprintf(os.Stderr, "Error getting commits: %v\n", err)
+		exit(1)
+	}
+}
+
+// setHighlight makes the formatters highlight what --grep matched.
+func setHighlight(options git.CommitOptions) {
+	if options.InvertGrep {
+		return
+	}
+	pattern, err := git.GrepPattern(options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting commits: %v\n", err)
+		exit(1)
+	}
+	formatter.SetHighlight(pattern)
+}
+
+// openRepository returns the history backend selected with --backend,
+// backed by the commit cache unless --no-cache is set.
+func openRepository() (git.Repository, error) {
+	repo, err := git.Open(backend, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// The cache only speeds things up; history is read without it if it
+	// cannot be opened.
+	if !noCache {
+		if gitDir, err := git.CommonDir(""); err == nil {
+			if store, err := cache.Open(gitDir); err == nil {
+				commitCache = store
+				git.SetCache(repo, store)
+			}
+		}
+	}
+
+	ids, err := identities()
+	if err != nil {
+		return nil, err
+	}
+	return git.WithIdentities(repo, ids), nil
+}
+
+// commitOptions collects the history filters shared by every command,
+// including the revisions and pathspecs given as arguments.
+func commitOptions(cmd *cobra.Command, args []string) git.CommitOptions {
+	revisions, pathArgs := splitArgs(cmd, args)
+	return git.CommitOptions{
+		Limit:           limit,
+		Author:          author,
+		Since:           since,
+		Until:           until,
+		Branch:          branch,
+		MergesOnly:      mergesOnly,
+		NoMerges:        noMerges,
+		ShowFileChanges: showFiles,
+		RenameThreshold: renameThreshold,
+		CopyThreshold:   copyThreshold,
+		NoRenames:       noRenames,
+		Paths:           append(pathArgs, paths...),
+		ExcludePaths:    excludePaths,
+		Follow:          follow,
+		Revisions:       revisions,
+		FirstParent:     firstParent,
+		Grep:            grepPatterns,
+		InvertGrep:      invertGrep,
+		IgnoreCase:      ignoreCase,
+		Pickaxe:         pickaxe,
+		PickaxeRegex:    pickaxeRegex,
+		Order:           sortOrder,
+	}
+}
+
+// splitArgs separates revisions from paths the way git does: everything
+// before "--" is a revision and everything after it a path. Without "--",
+// ranges and "^rev" are revisions, and arguments naming an existing file
+// or looking like a pathspec are paths.
+func splitArgs(cmd *cobra.Command, args []string) (revisions, pathArgs []string) {
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		return args[:dash], append([]string(nil), args[dash:]...)
+	}
+	for _, arg := range args {
+		switch {
+		case strings.Contains(arg, "..") || strings.HasPrefix(arg, "^"):
+			revisions = append(revisions, arg)
+		case strings.HasPrefix(arg, ":") || strings.ContainsAny(arg, "*?["):
+			pathArgs = append(pathArgs, arg)
+		default:
+			if _, err := os.Lstat(arg); err == nil {
+				pathArgs = append(pathArgs, arg)
+			} else {
+				revisions = append(revisions, arg)
+			}
+		}
+	}
+	return revisions, pathArgs
+}
+
+// optionalValueFlags are the flags whose value may be left out, so that it
+// has to follow "=": "--color never" reads as --color=always and a
+// revision "never". Each maps to whether a word is a value it takes.
+var optionalValueFlags = map[string]func(string) bool{
+	"color":        isColorMode,
+	"patch":        isPatchMode,
+	"find-renames": isPercentage,
+	"find-copies":  isPercentage,
+}
+
+// checkOptionalValues rejects an optional-value flag given without its
+// value when one of the arguments before "--" is a value it takes: the
+// command would otherwise read that value as a revision or path.
+func checkOptionalValues(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	if dash := flags.ArgsLenAtDash(); dash >= 0 {
+		args = args[:dash]
+	}
+	var names []string
+	for name := range optionalValueFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		flag := flags.Lookup(name)
+		if flag == nil || !flag.Changed || flag.Value.String() != flag.NoOptDefVal {
+			continue
+		}
+		for _, arg := range args {
+			if optionalValueFlags[name](arg) {
+				return fmt.Errorf("--%s takes its value after \"=\": use --%s=%s", name, name, arg)
+			}
+		}
+	}
+	return nil
+}
+
+func isPercentage(word string) bool {
+	n, err := strconv.Atoi(word)
+	return err == nil && n >= 0 && n <= 100
+}
+
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		exit(1)
 	}
+	exit(0)
+}
+
+// exit ends the program once the pager, if one shows the output, has quit.
+func exit(code int) {
+	output.Close()
+	if commitCache != nil {
+		commitCache.Close()
+	}
+	os.Exit(code)
 }
 
 func init() {
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if err := checkOptionalValues(cmd, args); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+	}
+
 	rootCmd.PersistentFlags().IntVarP(&limit, "limit", "n", 50, "Limit number of commits")
 	rootCmd.PersistentFlags().StringVarP(&author, "author", "a", "", "Filter by author")
+	rootCmd.PersistentFlags().StringVar(&identityMap, "identity-map", "", "Merge author aliases with this file of \"Name <email> = alias, ...\" lines, after .mailmap")
 	rootCmd.PersistentFlags().StringVar(&since, "since", "", "Show commits more recent than specific date")
 	rootCmd.PersistentFlags().StringVar(&until, "until", "", "Show commits older than specific date")
-	rootCmd.PersistentFlags().StringVarP(&branch, "branch", "b", "", "Show commits from specific branch")
-	rootCmd.PersistentFlags().StringVarP(&format, "format", "f", "", "Output format (detailed, compact, oneline, changelog)")
+	rootCmd.PersistentFlags().StringVarP(&branch, "branch", "b", "", "Show commits from specific branch or revision range")
+	rootCmd.PersistentFlags().StringVarP(&format, "format", "f", "", "Output format (detailed, compact, oneline, changelog, changelog-md, json, ndjson, csv, yaml, or template:<go template>)")
+	rootCmd.PersistentFlags().StringVar(&templateFile, "template-file", "", "Print each commit with the Go template in this file")
+	rootCmd.PersistentFlags().StringVar(&dateMode, "date-mode", "", "How to show dates ("+strings.Join(formatter.DateModes(), ", ")+"); default dates in the author's time zone, relative in compact output")
+	rootCmd.PersistentFlags().StringVar(&sortOrder, "sort", "", "List no commit before its children, ordered by "+strings.Join(git.Orders(), ", "))
+	rootCmd.PersistentFlags().StringVar(&groupBy, "group-by", "day", "Split changelogs into sections per day or per tag (day, tag)")
 	rootCmd.PersistentFlags().BoolVarP(&compact, "compact", "c", false, "Compact output")
 	rootCmd.PersistentFlags().BoolVar(&showStats, "stats", false, "Show file statistics")
 	rootCmd.PersistentFlags().BoolVar(&showFiles, "files", false, "Show changed files with details") // New flag
-	rootCmd.PersistentFlags().BoolVar(&graph, "graph", false, "Show ASCII commit graph")
+	rootCmd.PersistentFlags().StringVar(&patchMode, "patch", "", "Show each commit's diff, highlighted by file type; --patch=MODE picks "+strings.Join(formatter.PatchModes(), ", ")+" (default unified)")
+	rootCmd.PersistentFlags().Lookup("patch").NoOptDefVal = formatter.PatchUnified
+	rootCmd.PersistentFlags().IntVar(&hunkLines, "hunk-lines", 60, "Fold the middle of diff hunks longer than this many lines (0 shows every line)")
+	rootCmd.PersistentFlags().BoolVar(&graph, "graph", false, "Draw the commit graph beside the commits")
+	rootCmd.PersistentFlags().StringVar(&graphStyle, "graph-style", "unicode", "Characters used to draw the graph (unicode, ascii)")
 	rootCmd.PersistentFlags().BoolVar(&mergesOnly, "merges", false, "Show only merge commits")
 	rootCmd.PersistentFlags().BoolVar(&noMerges, "no-merges", false, "Exclude merge commits")
-	
+	rootCmd.PersistentFlags().StringVar(&backend, "backend", git.BackendExec, "History backend (exec, native)")
+	rootCmd.PersistentFlags().StringVar(&colorMode, "color", "", "Color output; --color=WHEN picks auto, always or never (default from NO_COLOR and color.ui)")
+	rootCmd.PersistentFlags().Lookup("color").NoOptDefVal = "always"
+	rootCmd.PersistentFlags().StringVar(&colorTheme, "color-theme", "default", "Colors of file statuses ("+strings.Join(formatter.Themes(), ", ")+")")
+	rootCmd.PersistentFlags().BoolVar(&noPager, "no-pager", false, "Do not pipe output into a pager")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Do not read or update the commit cache")
+	rootCmd.PersistentFlags().IntVarP(&renameThreshold, "find-renames", "M", 0, "Detect renames at this similarity percentage, given as -M=N or --find-renames=N (default 50)")
+	rootCmd.PersistentFlags().Lookup("find-renames").NoOptDefVal = "50"
+	rootCmd.PersistentFlags().IntVarP(&copyThreshold, "find-copies", "C", 0, "Also detect copies at this similarity percentage, given as -C=N or --find-copies=N (50 with -C alone)")
+	rootCmd.PersistentFlags().Lookup("find-copies").NoOptDefVal = "50"
+	rootCmd.PersistentFlags().BoolVar(&noRenames, "no-renames", false, "Turn off rename and copy detection")
+	rootCmd.PersistentFlags().StringArrayVar(&paths, "path", nil, "Only show commits touching this path or pathspec (repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&excludePaths, "exclude-path", nil, "Hide changes to this path or pathspec (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&follow, "follow", false, "Follow a single file's history across renames")
+	rootCmd.PersistentFlags().BoolVar(&firstParent, "first-parent", false, "Follow only the first parent of merge commits")
+	rootCmd.PersistentFlags().StringArrayVar(&grepPatterns, "grep", nil, "Show commits whose message matches this regex (repeatable); Go RE2 syntax, or POSIX extended when git lacks PCRE")
+	rootCmd.PersistentFlags().BoolVar(&invertGrep, "invert-grep", false, "Show commits whose message matches none of the --grep patterns")
+	rootCmd.PersistentFlags().BoolVarP(&ignoreCase, "regexp-ignore-case", "i", false, "Match --grep, --author, -S and -G without regard to case")
+	rootCmd.PersistentFlags().StringVarP(&pickaxe, "search", "S", "", "Show commits that change how often this string occurs")
+	rootCmd.PersistentFlags().StringVarP(&pickaxeRegex, "search-regex", "G", "", "Show commits whose added or removed lines match this regex, in POSIX extended syntax")
+
 	rootCmd.MarkFlagsMutuallyExclusive("merges", "no-merges")
 
 	// Add web command
 	rootCmd.AddCommand(webCmd)
-	
+
 	// Ensure web command inherits the right flags
 	webCmd.Flags().AddFlagSet(rootCmd.PersistentFlags())
-}
\ No newline at end of file
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCheckOptionalValues(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--color", "main"}, ""},
+		{[]string{"--color", "never", "main"}, "use --color=never"},
+		{[]string{"--color=never", "main"}, ""},
+		{[]string{"main", "--color", "--", "never"}, ""},
+		{[]string{"--patch", "word"}, "use --patch=word"},
+		{[]string{"--patch=word", "v1.0..main"}, ""},
+		{[]string{"-M", "60", "main"}, "use --find-renames=60"},
+		{[]string{"-M=60", "main"}, ""},
+		{[]string{"--find-copies", "main", "40"}, "use --find-copies=40"},
+		{[]string{"-C", "main"}, ""},
+	} {
+		// A command of its own keeps the parse out of the root command's
+		// flag values.
+		cmd := &cobra.Command{Use: "test"}
+		for name := range optionalValueFlags {
+			flag := rootCmd.PersistentFlags().Lookup(name)
+			cmd.Flags().StringP(name, flag.Shorthand, "", "")
+			cmd.Flags().Lookup(name).NoOptDefVal = flag.NoOptDefVal
+		}
+		if err := cmd.ParseFlags(test.args); err != nil {
+			t.Fatal(err)
+		}
+
+		err := checkOptionalValues(cmd, cmd.Flags().Args())
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%q: %v", test.args, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%q: err = %v, want %q", test.args, err, test.err)
+		}
+	}
+}
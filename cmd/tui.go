@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"human-git-history/internal/formatter"
+	"human-git-history/internal/tui"
+
+	"github.com/spf13/cobra"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui [flags] [<revision-range>...] [--] [<path>...]",
+	Short: "Browse history in a full-screen terminal interface",
+	Long: `Browse history in a full-screen terminal interface: a scrollable list of
+commits beside the selected commit's message, trailers and file changes.
+Enter shows the diff of each changed file, laid out as --patch selects
+and switched with v, / and @ filter the list by
+message or author as you type, y copies the selected hash and c checks
+the commit out. History is read as the list scrolls, so --limit only
+applies when given.`,
+	Args: cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		repo, err := openRepository()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening repository: %v\n", err)
+			exit(1)
+		}
+
+		options := commitOptions(cmd, args)
+		if !cmd.Flags().Changed("limit") {
+			options.Limit = 0
+		}
+		options.ShowFileChanges = true
+		// The browser draws on the terminal, wherever standard output goes.
+		setColor(true)
+		setHighlight(options)
+		if err := formatter.SetDateMode(dateMode); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v (use %s)\n", err, strings.Join(formatter.DateModes(), ", "))
+			exit(1)
+		}
+
+		if err := tui.Browse(repo, options, tui.Options{Checkout: checkout, Patch: patchOptions()}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+	},
+}
+
+// checkout checks out a commit, detaching HEAD, with git's own error
+// message when it refuses, say over uncommitted changes.
+func checkout(hash string) error {
+	out, err := exec.Command("git", "-c", "advice.detachedHead=false", "checkout", "--quiet", hash).CombinedOutput()
+	if err != nil {
+		if message := strings.TrimSpace(string(out)); message != "" {
+			return fmt.Errorf("%s", strings.Split(message, "\n")[0])
+		}
+		return err
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+}
@@ -2,49 +2,49 @@ package cmd
 
 import (
 	"fmt"
+	"human-git-history/internal/git"
+	"human-git-history/internal/patch"
+	"human-git-history/internal/template"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
-	"human-git-history/internal/git"
-	"human-git-history/internal/template"
 
 	"github.com/spf13/cobra"
 )
 
 import "runtime"
 
-
 var (
-	outputFile   string
-	title        string
-	description  string
-	groupByDate  bool
+	outputFile    string
+	title         string
+	description   string
+	groupByDate   bool
 	groupByAuthor bool
-	theme        string
-	openBrowser  bool
+	theme         string
+	openBrowser   bool
+	webChangelog  bool
 )
 
 var webCmd = &cobra.Command{
-	Use:   "web",
+	Use:   "web [flags] [<revision-range>...] [--] [<path>...]",
 	Short: "Generate HTML webpage from git history",
 	Long:  `Generate a beautifully formatted HTML webpage displaying git history with interactive features.`,
+	Args:  cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
+		repo, err := openRepository()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening repository: %v\n", err)
+			exit(1)
+		}
+
 		// Get commits
-		commits, err := git.GetCommits(git.CommitOptions{
-			Limit:          limit,
-			Author:         author,
-			Since:          since,
-			Until:          until,
-			Branch:         branch,
-			MergesOnly:     mergesOnly,
-			NoMerges:       noMerges,
-			ShowFileChanges: showFiles,
-		})
+		options := withPatchFiles(commitOptions(cmd, args))
+		commits, err := repo.Log(options)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error getting commits: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		// Calculate repository statistics
@@ -53,13 +53,13 @@ var webCmd = &cobra.Command{
 		// Initialize template renderer
 		templateDir := "templates"
 		assetDir := "assets"
-		
+
 		// Check if templates exist, create default if not
 		if _, err := os.Stat(templateDir); os.IsNotExist(err) {
 			fmt.Println("Creating default templates...")
 			if err := createDefaultTemplates(templateDir); err != nil {
 				fmt.Fprintf(os.Stderr, "Error creating templates: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		}
 
@@ -68,17 +68,18 @@ var webCmd = &cobra.Command{
 			fmt.Println("Creating default assets...")
 			if err := createDefaultAssets(assetDir); err != nil {
 				fmt.Fprintf(os.Stderr, "Error creating assets: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		}
 
 		renderer, err := template.NewRenderer(templateDir, assetDir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error initializing renderer: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		// Prepare template data
+		patchOpts := patchOptions()
 		data := template.TemplateData{
 			Commits:     commits,
 			Title:       getRepoTitle(title),
@@ -93,14 +94,34 @@ var webCmd = &cobra.Command{
 				Since:         since,
 				Until:         until,
 				Author:        author,
+				Range:         git.DescribeRange(options),
 				Theme:         theme,
 				CompactView:   compact,
+				Patch:         patchOpts.Mode,
+				HunkLines:     patchOpts.HunkLines,
 			},
 		}
 
+		// Read each commit's diff for the patch section of its card
+		if data.Options.Patch != "" && !webChangelog {
+			diff := patchSource(repo, options)
+			data.Patches = make(map[string][]patch.File)
+			for _, commit := range commits {
+				text, err := diff(commit)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error reading diff of %s: %v\n", commit.ShortHash, err)
+					exit(1)
+				}
+				data.Patches[commit.Hash] = patch.Parse(text)
+			}
+		}
+
 		// Determine output file
 		if outputFile == "" {
 			outputFile = "git-history.html"
+			if webChangelog {
+				outputFile = "changelog.html"
+			}
 		}
 
 		// Ensure output directory exists
@@ -108,19 +129,24 @@ var webCmd = &cobra.Command{
 		if outputDir != "." {
 			if err := os.MkdirAll(outputDir, 0755); err != nil {
 				fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
-				os.Exit(1)
+				exit(1)
 			}
 		}
 
 		// Render to file
 		fmt.Printf("Generating HTML to %s...\n", outputFile)
-		if err := renderer.RenderToFile(outputFile, data); err != nil {
+		render := renderer.RenderToFile
+		if webChangelog {
+			data.Releases = releases(commits, releaseTags(repo))
+			render = renderer.RenderChangelogToFile
+		}
+		if err := render(outputFile, data); err != nil {
 			fmt.Fprintf(os.Stderr, "Error rendering HTML: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
 
 		fmt.Printf("✅ Successfully generated %s\n", outputFile)
-		
+
 		// Open in browser if requested
 		if openBrowser {
 			openInBrowser(outputFile)
@@ -136,40 +162,46 @@ func calculateRepoStats(commits []git.Commit) *template.RepoStats {
 	}
 
 	stats := &template.RepoStats{
-		TotalCommits:   len(commits),
-		Authors:        make(map[string]template.AuthorStats),
-		FirstCommit:    commits[len(commits)-1].AuthorDate,
-		LastCommit:     commits[0].AuthorDate,
+		TotalCommits: len(commits),
+		Authors:      make(map[string]template.AuthorStats),
+		FirstCommit:  commits[len(commits)-1].AuthorDate,
+		LastCommit:   commits[0].AuthorDate,
 	}
 
 	authorsMap := make(map[string]bool)
-	
-	for _, commit := range commits {
-		// Track unique authors
-		authorKey := commit.AuthorName + "|" + commit.AuthorEmail
-		if !authorsMap[authorKey] {
-			authorsMap[authorKey] = true
-		}
 
-		// Update author stats
-		if _, exists := stats.Authors[authorKey]; !exists {
-			stats.Authors[authorKey] = template.AuthorStats{
-				Name:    commit.AuthorName,
-				Email:   commit.AuthorEmail,
-				Commits: 0,
-			}
-		}
-		
-		authorStat := stats.Authors[authorKey]
-		authorStat.Commits++
+	for _, commit := range commits {
 		if commit.Stats != nil {
-			authorStat.Insertions += commit.Stats.Insertions
-			authorStat.Deletions += commit.Stats.Deletions
 			stats.FilesChanged += commit.Stats.FilesChanged
 			stats.TotalInsertions += commit.Stats.Insertions
 			stats.TotalDeletions += commit.Stats.Deletions
 		}
-		stats.Authors[authorKey] = authorStat
+
+		// Co-authors count as authors of the commit too
+		for _, person := range commit.Authors() {
+			// Track unique authors
+			authorKey := person.Name + "|" + person.Email
+			if !authorsMap[authorKey] {
+				authorsMap[authorKey] = true
+			}
+
+			// Update author stats
+			if _, exists := stats.Authors[authorKey]; !exists {
+				stats.Authors[authorKey] = template.AuthorStats{
+					Name:    person.Name,
+					Email:   person.Email,
+					Commits: 0,
+				}
+			}
+
+			authorStat := stats.Authors[authorKey]
+			authorStat.Commits++
+			if commit.Stats != nil {
+				authorStat.Insertions += commit.Stats.Insertions
+				authorStat.Deletions += commit.Stats.Deletions
+			}
+			stats.Authors[authorKey] = authorStat
+		}
 	}
 
 	stats.TotalAuthors = len(authorsMap)
@@ -180,7 +212,7 @@ func getRepoTitle(defaultTitle string) string {
 	if defaultTitle != "" {
 		return defaultTitle
 	}
-	
+
 	// Try to get repo name from git
 	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
 	output, err := cmd.Output()
@@ -189,7 +221,7 @@ func getRepoTitle(defaultTitle string) string {
 		repoName := filepath.Base(repoPath)
 		return fmt.Sprintf("%s - Git History", repoName)
 	}
-	
+
 	// Try git config
 	cmd = exec.Command("git", "config", "--get", "remote.origin.url")
 	output, err = cmd.Output()
@@ -199,7 +231,7 @@ func getRepoTitle(defaultTitle string) string {
 			return fmt.Sprintf("%s - Git History", repoName)
 		}
 	}
-	
+
 	return "Git Repository History"
 }
 
@@ -207,14 +239,14 @@ func getRepoDescription(defaultDesc string) string {
 	if defaultDesc != "" {
 		return defaultDesc
 	}
-	
+
 	// Try to get repo description
 	cmd := exec.Command("git", "config", "--get", "gitweb.description")
 	output, err := cmd.Output()
 	if err == nil && len(output) > 0 {
 		return strings.TrimSpace(string(output))
 	}
-	
+
 	// Try README first line
 	if content, err := os.ReadFile("README.md"); err == nil {
 		lines := strings.Split(string(content), "\n")
@@ -225,7 +257,7 @@ func getRepoDescription(defaultDesc string) string {
 			}
 		}
 	}
-	
+
 	return "Interactive visualization of git commit history"
 }
 
@@ -233,10 +265,10 @@ func extractRepoName(url string) string {
 	// Extract repo name from git URL
 	// Handle various formats: git@github.com:user/repo.git, https://github.com/user/repo.git
 	url = strings.TrimSpace(url)
-	
+
 	// Remove .git suffix
 	url = strings.TrimSuffix(url, ".git")
-	
+
 	// Handle SSH format
 	if strings.Contains(url, "git@") {
 		parts := strings.Split(url, ":")
@@ -247,7 +279,7 @@ func extractRepoName(url string) string {
 			}
 		}
 	}
-	
+
 	// Handle HTTPS format
 	if strings.Contains(url, "http") {
 		parts := strings.Split(url, "/")
@@ -255,7 +287,7 @@ func extractRepoName(url string) string {
 			return parts[len(parts)-1]
 		}
 	}
-	
+
 	return ""
 }
 
@@ -263,7 +295,7 @@ func createDefaultTemplates(templateDir string) error {
 	if err := os.MkdirAll(templateDir, 0755); err != nil {
 		return err
 	}
-	
+
 	// Create index.tpl
 	indexTPL := `<!DOCTYPE html>
 <html lang="en" data-theme="{{.Options.Theme | default "auto"}}">
@@ -305,6 +337,7 @@ func createDefaultTemplates(templateDir string) error {
                 <span><i class="fas fa-calendar"></i> Generated: {{.GeneratedAt | formatDateTime}}</span>
                 <span><i class="fas fa-code-branch"></i> Commits: {{.Stats.TotalCommits}}</span>
                 <span><i class="fas fa-users"></i> Authors: {{.Stats.TotalAuthors}}</span>
+                {{if .Options.Range}}<span><i class="fas fa-code-compare"></i> Range: {{.Options.Range}}</span>{{end}}
             </div>
         </header>
 
@@ -475,11 +508,11 @@ func createDefaultTemplates(templateDir string) error {
     </script>
 </body>
 </html>`
-	
+
 	if err := os.WriteFile(filepath.Join(templateDir, "index.tpl"), []byte(indexTPL), 0644); err != nil {
 		return err
 	}
-	
+
 	// Create commit.tpl (simplified version)
 	commitTPL := `<div class="commit-card" 
      data-author="{{.Commit.AuthorName}}"
@@ -556,11 +589,11 @@ func createDefaultTemplates(templateDir string) error {
         </div>
     </div>
 </div>`
-	
+
 	if err := os.WriteFile(filepath.Join(templateDir, "commit.tpl"), []byte(commitTPL), 0644); err != nil {
 		return err
 	}
-	
+
 	// Create stats.tpl (simplified)
 	statsTPL := `<div class="stats-container">
     <h2><i class="fas fa-chart-bar"></i> Repository Statistics</h2>
@@ -618,11 +651,11 @@ func createDefaultTemplates(templateDir string) error {
         </div>
     </div>
 </div>`
-	
+
 	if err := os.WriteFile(filepath.Join(templateDir, "stats.tpl"), []byte(statsTPL), 0644); err != nil {
 		return err
 	}
-	
+
 	return nil
 }
 
@@ -630,8 +663,6 @@ func createDefaultAssets(assetDir string) error {
 	if err := os.MkdirAll(assetDir, 0755); err != nil {
 		return err
 	}
-	
-	
 
 	// For brevity, let me provide a minimal version
 	minimalCSS := `* { margin: 0; padding: 0; box-sizing: border-box; }
@@ -654,7 +685,7 @@ body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-
     .header { padding: 20px; }
     .commit-header { flex-direction: column; }
 }`
-	
+
 	return os.WriteFile(filepath.Join(assetDir, "style.css"), []byte(minimalCSS), 0644)
 }
 
@@ -666,7 +697,7 @@ func openInBrowser(filePath string) {
 	}
 
 	fileURL := "file://" + absPath
-	
+
 	// Platform-specific browser opening
 	switch runtime.GOOS {
 	case "darwin":
@@ -681,18 +712,19 @@ func openInBrowser(filePath string) {
 }
 
 func init() {
-    rootCmd.AddCommand(webCmd)
-
-    // Web-specific flags (these are fine)
-    webCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output HTML file (default: git-history.html)")
-    webCmd.Flags().StringVar(&title, "title", "", "Custom title for the webpage")
-    webCmd.Flags().StringVar(&description, "description", "", "Custom description for the webpage")
-    webCmd.Flags().BoolVar(&groupByDate, "group-by-date", false, "Group commits by date")
-    webCmd.Flags().BoolVar(&groupByAuthor, "group-by-author", false, "Group commits by author")
-    webCmd.Flags().StringVar(&theme, "theme", "auto", "Theme (light, dark, auto)")
-    webCmd.Flags().BoolVarP(&openBrowser, "open", "p", false, "Open in browser after generation")
-
-    // OPTIONAL: Inherit root flags cleanly (DO NOT re-declare)
-    webCmd.Flags().AddFlagSet(rootCmd.PersistentFlags())
-    webCmd.Flags().AddFlagSet(rootCmd.Flags())
+	rootCmd.AddCommand(webCmd)
+
+	// Web-specific flags (these are fine)
+	webCmd.Flags().StringVarP(&outputFile, "output", "o", "", "Output HTML file (default: git-history.html)")
+	webCmd.Flags().StringVar(&title, "title", "", "Custom title for the webpage")
+	webCmd.Flags().StringVar(&description, "description", "", "Custom description for the webpage")
+	webCmd.Flags().BoolVar(&groupByDate, "group-by-date", false, "Group commits by date")
+	webCmd.Flags().BoolVar(&groupByAuthor, "group-by-author", false, "Group commits by author")
+	webCmd.Flags().StringVar(&theme, "theme", "auto", "Theme (light, dark, auto)")
+	webCmd.Flags().BoolVarP(&openBrowser, "open", "p", false, "Open in browser after generation")
+	webCmd.Flags().BoolVar(&webChangelog, "changelog", false, "Generate a Keep a Changelog page instead of the history (default: changelog.html)")
+
+	// OPTIONAL: Inherit root flags cleanly (DO NOT re-declare)
+	webCmd.Flags().AddFlagSet(rootCmd.PersistentFlags())
+	webCmd.Flags().AddFlagSet(rootCmd.Flags())
 }
@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"human-git-history/internal/git"
+)
+
+// Version is bumped whenever the stored commit data changes shape. Caches
+// written by another version are discarded and rebuilt.
+const Version = 3
+
+const fileName = "commits.jsonl"
+
+type header struct {
+	Version int `json:"version"`
+}
+
+type entry struct {
+	DiffKey string     `json:"diff_key"`
+	Files   bool       `json:"files"`
+	Commit  git.Commit `json:"commit"`
+}
+
+// Store is a persistent commit cache: a JSON-lines file whose first line is
+// a version header and whose other lines each hold one parsed commit. New
+// commits are appended, so a run only pays for commits it has not seen. A
+// commit stored again, with other diff options or its files, supersedes
+// its earlier line; load rewrites the file once those make up most of it.
+type Store struct {
+	path  string
+	lines int // Entry lines in the file, superseded ones included
+
+	mu      sync.Mutex
+	entries map[string]entry
+	file    *os.File
+	writer  *bufio.Writer
+}
+
+// Dirs returns the directories a cache for the repository at gitDir may
+// live in: inside the git directory, then under the user cache directory.
+func Dirs(gitDir string) []string {
+	dirs := []string{filepath.Join(gitDir, "git-history")}
+	if userCache, err := os.UserCacheDir(); err == nil {
+		sum := sha1.Sum([]byte(gitDir))
+		dirs = append(dirs, filepath.Join(userCache, "git-history", hex.EncodeToString(sum[:8])))
+	}
+	return dirs
+}
+
+// Open loads the cache for the repository at gitDir, creating it in the
+// first writable location from Dirs.
+func Open(gitDir string) (*Store, error) {
+	var lastErr error
+	for _, dir := range Dirs(gitDir) {
+		store, err := openDir(dir)
+		if err == nil {
+			return store, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Clear removes every cache kept for the repository at gitDir.
+func Clear(gitDir string) error {
+	for _, dir := range Dirs(gitDir) {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("failed to remove cache %s: %v", dir, err)
+		}
+	}
+	return nil
+}
+
+func openDir(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	store := &Store{
+		path:    filepath.Join(dir, fileName),
+		entries: make(map[string]entry),
+	}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// load reads the cache file. A missing or foreign header resets the cache;
+// a torn or corrupt line truncates the file just before it, keeping every
+// commit read so far.
+func (s *Store) load() error {
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	valid, err := s.readEntries(reader)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	if valid == 0 {
+		// Empty, corrupt from the first line, or another version.
+		s.entries = make(map[string]entry)
+		if err := file.Truncate(0); err != nil {
+			file.Close()
+			return err
+		}
+		line, _ := json.Marshal(header{Version: Version})
+		if _, err := file.WriteAt(append(line, '\n'), 0); err != nil {
+			file.Close()
+			return err
+		}
+		valid = int64(len(line) + 1)
+	} else if err := file.Truncate(valid); err != nil {
+		file.Close()
+		return err
+	}
+
+	if s.lines > 2*len(s.entries)+compactSlack {
+		file.Close()
+		if err := s.compact(); err != nil {
+			return err
+		}
+		if file, err = os.OpenFile(s.path, os.O_RDWR, 0644); err != nil {
+			return err
+		}
+		s.lines = len(s.entries)
+	}
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return err
+	}
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	return nil
+}
+
+// compactSlack is how many superseded lines a small cache may keep before
+// load rewrites it.
+const compactSlack = 1000
+
+// compact rewrites the file with one line per cached commit. The new file
+// is written next to the old one and renamed over it, so a crash leaves
+// one or the other.
+func (s *Store) compact() error {
+	hashes := make([]string, 0, len(s.entries))
+	for hash := range s.entries {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), fileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once renamed
+
+	writer := bufio.NewWriter(tmp)
+	line, _ := json.Marshal(header{Version: Version})
+	writer.Write(append(line, '\n'))
+	for _, hash := range hashes {
+		line, err := json.Marshal(s.entries[hash])
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// readEntries returns the length of the valid prefix of the file: the header
+// and every complete, parseable entry after it.
+func (s *Store) readEntries(reader *bufio.Reader) (int64, error) {
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return 0, nil
+	}
+	var h header
+	if json.Unmarshal(line, &h) != nil || h.Version != Version {
+		return 0, nil
+	}
+	valid := int64(len(line))
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return valid, nil // Any partial final line is dropped
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var e entry
+		if json.Unmarshal(bytes.TrimSpace(line), &e) != nil || e.Commit.Hash == "" {
+			return valid, nil
+		}
+		s.entries[e.Commit.Hash] = e
+		s.lines++
+		valid += int64(len(line))
+	}
+}
+
+// Get returns the cached commit for hash if it was stored with the same diff
+// options and, when files are wanted, with its file changes.
+func (s *Store) Get(hash, diffKey string, withFiles bool) (git.Commit, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[hash]
+	if !ok || e.DiffKey != diffKey || (withFiles && !e.Files) {
+		return git.Commit{}, false
+	}
+	commit := e.Commit
+	if !withFiles {
+		commit.FileChanges = nil
+	}
+	return commit, true
+}
+
+// Put stores a commit. Ref names move over time, so they are not kept.
+func (s *Store) Put(commit git.Commit, diffKey string, withFiles bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[commit.Hash]; ok && e.DiffKey == diffKey && (e.Files || !withFiles) {
+		return
+	}
+
+	commit.RefNames = nil
+	e := entry{DiffKey: diffKey, Files: withFiles, Commit: commit}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	s.entries[commit.Hash] = e
+	s.writer.Write(append(line, '\n'))
+}
+
+// Close flushes new entries to disk.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.writer.Flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file = nil
+	return err
+}
+
+// Path is the cache file in use.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Len is the number of cached commits.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
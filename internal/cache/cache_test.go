@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"human-git-history/internal/git"
+)
+
+func TestStoreCompactsSupersededLines(t *testing.T) {
+	dir := t.TempDir()
+	store, err := openDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each diff key replaces the entry before it, as switching -M
+	// thresholds between runs does.
+	for i := 0; i <= 2*compactSlack; i++ {
+		store.Put(git.Commit{Hash: "abc", Message: "Subject"}, fmt.Sprint(i), false)
+	}
+	store.Put(git.Commit{Hash: "def"}, "", false)
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = openDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if store.Len() != 2 {
+		t.Errorf("Len = %d, want 2", store.Len())
+	}
+	if commit, ok := store.Get("abc", fmt.Sprint(2*compactSlack), false); !ok || commit.Message != "Subject" {
+		t.Errorf("Get = %+v, %v; the latest entry was lost", commit, ok)
+	}
+	data, err := os.ReadFile(store.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 3 {
+		t.Errorf("cache file has %d lines after compaction, want the header and 2 entries", lines)
+	}
+
+	// New entries still append after the rewrite.
+	store.Put(git.Commit{Hash: "123"}, "", false)
+	store.Close()
+	if store, err = openDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if store.Len() != 3 {
+		t.Errorf("Len after reopening = %d, want 3", store.Len())
+	}
+	store.Close()
+}
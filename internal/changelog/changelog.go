@@ -0,0 +1,179 @@
+// Package changelog turns history into a changelog in the style of Keep a
+// Changelog (https://keepachangelog.com): releases, each with its changes
+// sorted under Added, Changed, Deprecated, Removed, Fixed and Security.
+// Commits are sorted by their Conventional Commit type; those without one,
+// or with a type no section covers (docs, chore, ...), go under Other.
+// Merges without a type ("Merge branch 'x'") go under Merges, apart from
+// the changes they bring in, which have entries of their own.
+package changelog
+
+import (
+	"sort"
+	"time"
+
+	"human-git-history/internal/git"
+)
+
+// Sections are the headings of a release, in the order they are shown.
+var Sections = []string{"Added", "Changed", "Deprecated", "Removed", "Fixed", "Security", "Other", "Merges"}
+
+// Entry is one commit in a changelog.
+type Entry struct {
+	Commit git.Commit
+	Change Change
+}
+
+// NewEntry parses commit's message.
+func NewEntry(commit git.Commit) Entry {
+	return Entry{Commit: commit, Change: Parse(commit.Message, commit.Body)}
+}
+
+// Group is a section of a release: its entries, unscoped ones first and
+// the rest by scope, each newest first.
+type Group struct {
+	Name    string
+	Entries []Entry
+}
+
+// Release is a block of the changelog. Releases split by day have no
+// Version; the commits after the last tag are the Unreleased release,
+// which has no Date.
+type Release struct {
+	Version string
+	Date    time.Time // Of the tag, or of the newest commit when split by day
+	Message string    // Of an annotated tag
+	Groups  []Group
+}
+
+// Unreleased is the Version of the commits made since the latest tag.
+const Unreleased = "Unreleased"
+
+// Groups sorts commits into Sections, leaving out empty ones.
+func Groups(commits []git.Commit) []Group {
+	bySection := make(map[string][]Entry)
+	for _, commit := range commits {
+		entry := NewEntry(commit)
+		section := entry.Change.Section()
+		if len(commit.ParentHashes) > 1 && entry.Change.Type == "" {
+			section = "Merges"
+		}
+		bySection[section] = append(bySection[section], entry)
+	}
+
+	var groups []Group
+	for _, name := range Sections {
+		entries := bySection[name]
+		if len(entries) == 0 {
+			continue
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Change.Scope < entries[j].Change.Scope
+		})
+		groups = append(groups, Group{Name: name, Entries: entries})
+	}
+	return groups
+}
+
+// NewRelease groups the commits of one release, newest first.
+func NewRelease(version string, commits []git.Commit) Release {
+	release := Release{Version: version, Groups: Groups(commits)}
+	if len(commits) > 0 {
+		release.Date = commits[0].AuthorDate
+	}
+	return release
+}
+
+// ByDay makes a release of each day's commits, as the changelog format
+// has always split history.
+func ByDay(commits []git.Commit) []Release {
+	return split(NewSplitter(false, nil), commits)
+}
+
+// ByTag makes a release of each tag, holding the commits from it back to
+// the tag before, with the commits since the latest tag at the top.
+func ByTag(commits []git.Commit, tags []git.Tag) []Release {
+	return split(NewSplitter(true, tags), commits)
+}
+
+func split(s *Splitter, commits []git.Commit) []Release {
+	var releases []Release
+	for _, commit := range commits {
+		if release, ok := s.Add(commit); ok {
+			releases = append(releases, release)
+		}
+	}
+	if release, ok := s.Flush(); ok {
+		releases = append(releases, release)
+	}
+	return releases
+}
+
+// Splitter cuts history into releases as it is read, newest first: at
+// every tagged commit, or whenever the day changes.
+type Splitter struct {
+	tags    map[string]git.Tag // By commit; nil to split by day
+	current Release
+	commits []git.Commit
+}
+
+// NewSplitter splits at tags if byTag is set, and by day otherwise. A
+// commit with several tags belongs to the newest, and of tags made
+// together to the shortest name: v1.0.0 rather than v1.0.0-rc.1.
+func NewSplitter(byTag bool, tags []git.Tag) *Splitter {
+	s := &Splitter{}
+	if !byTag {
+		return s
+	}
+	s.tags = make(map[string]git.Tag)
+	for _, tag := range tags {
+		other, ok := s.tags[tag.Commit]
+		if !ok || tag.Date.After(other.Date) || tag.Date.Equal(other.Date) && len(tag.Name) < len(other.Name) {
+			s.tags[tag.Commit] = tag
+		}
+	}
+	return s
+}
+
+// Add puts commit in the current release. When commit starts a new one,
+// Add returns the release it ends, if that has any commits.
+func (s *Splitter) Add(commit git.Commit) (Release, bool) {
+	var next *Release
+	if s.tags != nil {
+		if tag, ok := s.tags[commit.Hash]; ok {
+			next = &Release{Version: tag.Name, Date: tag.Date, Message: tag.Message}
+		} else if len(s.commits) == 0 && s.current.Version == "" {
+			next = &Release{Version: Unreleased}
+		}
+	} else if len(s.commits) == 0 || !sameDay(s.commits[0], commit) {
+		next = &Release{Date: commit.AuthorDate}
+	}
+
+	var done Release
+	ended := false
+	if next != nil {
+		done, ended = s.Flush()
+		s.current = *next
+	}
+	s.commits = append(s.commits, commit)
+	return done, ended
+}
+
+// Current is the release being read, without its groups yet.
+func (s *Splitter) Current() Release {
+	return s.current
+}
+
+// Flush returns the release being read, if it has any commits.
+func (s *Splitter) Flush() (Release, bool) {
+	if len(s.commits) == 0 {
+		return Release{}, false
+	}
+	release := s.current
+	release.Groups = Groups(s.commits)
+	s.commits = nil
+	return release, true
+}
+
+func sameDay(a, b git.Commit) bool {
+	return a.AuthorDate.Format("2006-01-02") == b.AuthorDate.Format("2006-01-02")
+}
@@ -0,0 +1,84 @@
+package changelog
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Change is a commit message read as a Conventional Commit
+// (https://www.conventionalcommits.org): "type(scope)!: description", with
+// an optional "BREAKING CHANGE: note" footer in the body.
+type Change struct {
+	Type         string // Lower-cased; empty when the subject does not follow the convention
+	Scope        string
+	Description  string // The subject without type and scope, or all of it
+	Breaking     bool   // Marked with "!" or a BREAKING CHANGE footer
+	BreakingNote string // The footer's text, joined into one line
+}
+
+var (
+	subjectPattern  = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*)(?:\(([^()]*)\))?(!)?:\s+(.*\S)`)
+	breakingPattern = regexp.MustCompile(`^BREAKING[ -]CHANGE:\s*(.*)$`)
+)
+
+// Parse reads a commit's subject and body. Subjects that do not follow
+// the convention become a Change with no type and the subject as its
+// description.
+func Parse(subject, body string) Change {
+	change := Change{Description: strings.TrimSpace(subject)}
+	if m := subjectPattern.FindStringSubmatch(change.Description); m != nil {
+		change.Type = strings.ToLower(m[1])
+		change.Scope = strings.TrimSpace(m[2])
+		change.Breaking = m[3] == "!"
+		change.Description = m[4]
+	}
+
+	// The note runs to the end of its paragraph.
+	var note []string
+	inNote := false
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if m := breakingPattern.FindStringSubmatch(line); m != nil {
+			change.Breaking = true
+			inNote = true
+			note = append(note, m[1])
+			continue
+		}
+		if inNote && line == "" {
+			break
+		}
+		if inNote {
+			note = append(note, line)
+		}
+	}
+	change.BreakingNote = strings.TrimSpace(strings.Join(note, " "))
+	return change
+}
+
+// Section is the Keep a Changelog heading the change belongs under.
+func (c Change) Section() string {
+	switch c.Type {
+	case "feat", "feature":
+		return "Added"
+	case "fix", "bugfix":
+		return "Fixed"
+	case "perf", "refactor", "revert":
+		return "Changed"
+	case "deprecate", "deprecated":
+		return "Deprecated"
+	case "remove", "removed":
+		return "Removed"
+	case "security", "sec":
+		return "Security"
+	default:
+		return "Other"
+	}
+}
+
+// Text is the change as one line of a changelog: "scope: description".
+func (c Change) Text() string {
+	if c.Scope == "" {
+		return c.Description
+	}
+	return c.Scope + ": " + c.Description
+}
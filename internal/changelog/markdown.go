@@ -0,0 +1,62 @@
+package changelog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown writes releases as a Keep a Changelog Markdown document.
+// rangeDesc says which revisions it covers, if not all of HEAD.
+func WriteMarkdown(w io.Writer, releases []Release, rangeDesc string) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "# Changelog")
+	fmt.Fprintln(b)
+	fmt.Fprintln(b, "All notable changes, in the style of [Keep a Changelog](https://keepachangelog.com/en/1.1.0/).")
+	if rangeDesc != "" {
+		fmt.Fprintf(b, "Changes in `%s`.\n", rangeDesc)
+	}
+
+	for _, release := range releases {
+		fmt.Fprintln(b)
+		switch {
+		case release.Version == "":
+			fmt.Fprintf(b, "## %s\n", release.Date.Format("2006-01-02"))
+		case release.Date.IsZero():
+			fmt.Fprintf(b, "## [%s]\n", release.Version)
+		default:
+			fmt.Fprintf(b, "## [%s] - %s\n", release.Version, release.Date.Format("2006-01-02"))
+		}
+		if release.Message != "" {
+			fmt.Fprintln(b)
+			for _, line := range strings.Split(release.Message, "\n") {
+				fmt.Fprintln(b, strings.TrimRight("> "+line, " "))
+			}
+		}
+		for _, group := range release.Groups {
+			fmt.Fprintf(b, "\n### %s\n\n", group.Name)
+			for _, entry := range group.Entries {
+				writeMarkdownEntry(b, entry, true)
+			}
+		}
+	}
+	return b.Flush()
+}
+
+// writeMarkdownEntry writes an entry as a list item, labelled BREAKING if
+// mark is set and it is one.
+func writeMarkdownEntry(w io.Writer, entry Entry, mark bool) {
+	change := entry.Change
+	fmt.Fprint(w, "- ")
+	if mark && change.Breaking {
+		fmt.Fprint(w, "**BREAKING** ")
+	}
+	if change.Scope != "" {
+		fmt.Fprintf(w, "**%s:** ", change.Scope)
+	}
+	fmt.Fprintf(w, "%s (%s)\n", change.Description, entry.Commit.ShortHash)
+	if change.BreakingNote != "" {
+		fmt.Fprintf(w, "  - %s\n", change.BreakingNote)
+	}
+}
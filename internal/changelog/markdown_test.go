@@ -0,0 +1,48 @@
+package changelog
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"human-git-history/internal/git"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestWriteMarkdownGolden(t *testing.T) {
+	repo, err := git.LoadFixture("../git/testdata/history.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits, err := repo.Log(git.CommitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := repo.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, ByTag(commits, tags), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join("testdata", "changelog.md")
+	if *update {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("output differs from %s (run go test -update after checking it):\n%s", path, buf.Bytes())
+	}
+}
@@ -0,0 +1,149 @@
+package changelog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"human-git-history/internal/git"
+)
+
+// Notes are the release notes for the commits between two revisions.
+type Notes struct {
+	From, To     string // From is empty when there is no earlier release
+	Release      Release
+	Breaking     []Entry
+	Bump         Bump
+	BumpReason   string
+	Next         string // Suggested version, empty when From is not a semantic version
+	Contributors []Contributor
+	Commits      int
+	FilesChanged int
+	Insertions   int
+	Deletions    int
+}
+
+// Contributor is an author or co-author of commits in a release.
+type Contributor struct {
+	Name    string
+	Email   string
+	Commits int
+}
+
+// NewNotes collects the notes for commits, the history from from to to,
+// newest first.
+func NewNotes(from, to string, commits []git.Commit) Notes {
+	notes := Notes{From: from, To: to, Release: NewRelease(to, commits), Commits: len(commits)}
+
+	var entries []Entry
+	for _, group := range notes.Release.Groups {
+		for _, entry := range group.Entries {
+			entries = append(entries, entry)
+			if entry.Change.Breaking {
+				notes.Breaking = append(notes.Breaking, entry)
+			}
+		}
+	}
+	notes.Bump, notes.BumpReason = SuggestBump(entries, from)
+	if notes.Bump != BumpNone {
+		notes.Next, _ = NextVersion(from, notes.Bump)
+	}
+
+	authors := make(map[string]*Contributor)
+	files := make(map[string]bool)
+	for _, commit := range commits {
+		for _, person := range commit.Authors() {
+			key := person.Name + "|" + person.Email
+			if authors[key] == nil {
+				authors[key] = &Contributor{Name: person.Name, Email: person.Email}
+			}
+			authors[key].Commits++
+		}
+		if commit.Stats != nil {
+			notes.Insertions += commit.Stats.Insertions
+			notes.Deletions += commit.Stats.Deletions
+		}
+		for _, change := range commit.FileChanges {
+			files[change.FilePath] = true
+		}
+	}
+	notes.FilesChanged = len(files)
+	for _, c := range authors {
+		notes.Contributors = append(notes.Contributors, *c)
+	}
+	sort.Slice(notes.Contributors, func(i, j int) bool {
+		a, b := notes.Contributors[i], notes.Contributors[j]
+		if a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+		return a.Name < b.Name
+	})
+	return notes
+}
+
+// WriteNotes writes notes as Markdown ready to paste into a release. The
+// heading is To when it names a release, or else the suggested version.
+func WriteNotes(w io.Writer, notes Notes) error {
+	b := bufio.NewWriter(w)
+	heading := notes.To
+	if heading == "" || heading == "HEAD" {
+		heading = "Unreleased"
+		if notes.Next != "" {
+			heading = notes.Next
+		}
+	}
+	fmt.Fprintf(b, "## %s\n\n", heading)
+	if notes.From != "" {
+		fmt.Fprintf(b, "Changes since `%s`", notes.From)
+	} else {
+		fmt.Fprint(b, "All changes")
+	}
+	if notes.To != "" && notes.To != "HEAD" {
+		fmt.Fprintf(b, " up to `%s`", notes.To)
+	}
+	fmt.Fprintln(b, ".")
+
+	if notes.Bump != BumpNone {
+		fmt.Fprintf(b, "\n**Suggested version bump:** %s", notes.Bump)
+		if notes.Next != "" {
+			fmt.Fprintf(b, ", to `%s`", notes.Next)
+		}
+		fmt.Fprintf(b, " (%s)\n", notes.BumpReason)
+	}
+
+	if len(notes.Breaking) > 0 {
+		fmt.Fprint(b, "\n### Breaking changes\n\n")
+		for _, entry := range notes.Breaking {
+			writeMarkdownEntry(b, entry, false)
+		}
+	}
+	for _, group := range notes.Release.Groups {
+		fmt.Fprintf(b, "\n### %s\n\n", group.Name)
+		for _, entry := range group.Entries {
+			writeMarkdownEntry(b, entry, true)
+		}
+	}
+
+	if len(notes.Contributors) > 0 {
+		fmt.Fprint(b, "\n### Contributors\n\n")
+		for _, c := range notes.Contributors {
+			fmt.Fprintf(b, "- %s (%d commit%s)\n", c.Name, c.Commits, plural(c.Commits))
+		}
+	}
+
+	fmt.Fprint(b, "\n### Stats\n\n")
+	fmt.Fprintf(b, "%d commit%s, %d file%s changed, %d insertion%s(+), %d deletion%s(-)\n",
+		notes.Commits, plural(notes.Commits),
+		notes.FilesChanged, plural(notes.FilesChanged),
+		notes.Insertions, plural(notes.Insertions),
+		notes.Deletions, plural(notes.Deletions))
+	return b.Flush()
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,146 @@
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Bump is the part of a semantic version a release has to increase.
+type Bump int
+
+const (
+	BumpNone Bump = iota
+	BumpPatch
+	BumpMinor
+	BumpMajor
+)
+
+func (b Bump) String() string {
+	switch b {
+	case BumpPatch:
+		return "patch"
+	case BumpMinor:
+		return "minor"
+	case BumpMajor:
+		return "major"
+	default:
+		return "none"
+	}
+}
+
+var versionPattern = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)(?:-([^+]*))?(?:\+.*)?$`)
+
+// SuggestBump picks the bump semantic versioning asks for after entries:
+// major for breaking changes, minor for features and patch for anything
+// else. Before 1.0.0 breaking changes only need a minor bump, so current
+// is the version released last, or "" if unknown.
+func SuggestBump(entries []Entry, current string) (Bump, string) {
+	bump, reason := BumpNone, ""
+	for _, entry := range entries {
+		switch {
+		case entry.Change.Breaking:
+			bump, reason = BumpMajor, "breaking changes"
+		case entry.Change.Section() == "Added" && bump < BumpMinor:
+			bump, reason = BumpMinor, "new features"
+		case bump < BumpPatch:
+			bump, reason = BumpPatch, "fixes and other changes"
+		}
+		if bump == BumpMajor {
+			break
+		}
+	}
+	if m := versionPattern.FindStringSubmatch(current); bump == BumpMajor && m != nil && m[2] == "0" {
+		bump = BumpMinor
+		reason += ", before 1.0.0"
+	}
+	return bump, reason
+}
+
+// NextVersion applies bump to a version such as v1.4.2, keeping its "v"
+// prefix and dropping any pre-release or build suffix. It returns false
+// when version is not a semantic version.
+func NextVersion(version string, bump Bump) (string, bool) {
+	m := versionPattern.FindStringSubmatch(version)
+	if m == nil {
+		return "", false
+	}
+	major, _ := strconv.Atoi(m[2])
+	minor, _ := strconv.Atoi(m[3])
+	patch, _ := strconv.Atoi(m[4])
+	switch bump {
+	case BumpMajor:
+		major, minor, patch = major+1, 0, 0
+	case BumpMinor:
+		minor, patch = minor+1, 0
+	case BumpPatch:
+		patch++
+	}
+	return fmt.Sprintf("%s%d.%d.%d", m[1], major, minor, patch), true
+}
+
+// CompareVersions orders tag names by semantic version precedence:
+// v1.10.0 after v1.9.0, and v1.0.0 after v1.0.0-rc.1. Build metadata is
+// ignored. Semantic versions come after other names, which are compared
+// as strings. It returns -1, 0 or 1 like strings.Compare.
+func CompareVersions(a, b string) int {
+	ma, mb := versionPattern.FindStringSubmatch(a), versionPattern.FindStringSubmatch(b)
+	switch {
+	case ma == nil && mb == nil:
+		return strings.Compare(a, b)
+	case ma == nil:
+		return -1
+	case mb == nil:
+		return 1
+	}
+	for i := 2; i <= 4; i++ {
+		if c := compareNumbers(ma[i], mb[i]); c != 0 {
+			return c
+		}
+	}
+
+	// A pre-release comes before its release.
+	switch {
+	case ma[5] == mb[5]:
+		return 0
+	case ma[5] == "":
+		return 1
+	case mb[5] == "":
+		return -1
+	}
+	// Pre-releases compare field by field: numbers by value and before
+	// words, and a shorter list first when one is a prefix of the other.
+	fa, fb := strings.Split(ma[5], "."), strings.Split(mb[5], ".")
+	for i := 0; i < len(fa) && i < len(fb); i++ {
+		_, errA := strconv.Atoi(fa[i])
+		_, errB := strconv.Atoi(fb[i])
+		var c int
+		switch {
+		case errA == nil && errB == nil:
+			c = compareNumbers(fa[i], fb[i])
+		case errA == nil:
+			c = -1
+		case errB == nil:
+			c = 1
+		default:
+			c = strings.Compare(fa[i], fb[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareNumbers(strconv.Itoa(len(fa)), strconv.Itoa(len(fb)))
+}
+
+// compareNumbers compares strings of digits by value, however long.
+func compareNumbers(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
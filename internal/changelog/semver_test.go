@@ -0,0 +1,106 @@
+package changelog
+
+import (
+	"strings"
+	"testing"
+
+	"human-git-history/internal/git"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.10.0", "v1.9.0", 1},
+		{"v1.9.10", "v1.9.9", 1},
+		{"v2.0.0", "v10.0.0", -1},
+		{"1.0.0", "v1.0.0", 0},
+		{"v1.0.0", "v1.0.0-rc.1", 1},
+		{"v1.0.0-rc.2", "v1.0.0-rc.10", -1},
+		{"v1.0.0-alpha", "v1.0.0-alpha.1", -1},
+		{"v1.0.0-alpha.1", "v1.0.0-alpha.beta", -1},
+		{"v1.0.0-beta", "v1.0.0-alpha.beta", 1},
+		{"v1.0.0+build.5", "v1.0.0+build.4", 0},
+		{"v0.1.0", "nightly", 1},
+		{"release-b", "release-a", 1},
+	}
+	for _, test := range tests {
+		if got := CompareVersions(test.a, test.b); got != test.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := CompareVersions(test.b, test.a); got != -test.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", test.b, test.a, got, -test.want)
+		}
+	}
+}
+
+func TestLatestTag(t *testing.T) {
+	commits := []git.Commit{
+		{Hash: "c3", ParentHashes: []string{"c2"}},
+		{Hash: "c2", ParentHashes: []string{"c1"}},
+		{Hash: "c1"},
+	}
+	var refs []git.Ref
+	for _, name := range []string{"nightly", "v1.9.0", "v1.10.0", "v1.10.0-rc.1"} {
+		refs = append(refs, git.Ref{Name: "refs/tags/" + name, Hash: "c1"})
+	}
+	refs = append(refs, git.Ref{Name: "refs/tags/v2.0.0", Hash: "c3"})
+	repo := git.NewMemoryRepository(commits, refs)
+
+	// The tag on HEAD itself is the release being written.
+	tag, err := LatestTag(repo, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "v1.10.0" {
+		t.Errorf("LatestTag = %q, want v1.10.0", tag)
+	}
+}
+
+func TestReleaseRange(t *testing.T) {
+	commits := []git.Commit{
+		{Hash: "c3", Message: "feat: export trailers", ParentHashes: []string{"c2"}},
+		{Hash: "c2", Message: "fix: keep blank lines", ParentHashes: []string{"c1"}},
+		{Hash: "c1", Message: "Initial commit"},
+	}
+	refs := []git.Ref{
+		{Name: "refs/tags/v0.1.0", Hash: "c1"},
+		{Name: "refs/tags/v0.2.0", Hash: "c3"},
+		{Name: "refs/tags/v0.2.0-rc.1", Hash: "c3"},
+	}
+	repo := git.NewMemoryRepository(commits, refs)
+
+	tests := []struct {
+		from, to          string
+		start, release    string
+		heading, sinceTag string
+	}{
+		// HEAD is the tagged release: the notes are for it.
+		{"", "HEAD", "v0.1.0", "v0.2.0", "## v0.2.0", "Changes since `v0.1.0` up to `v0.2.0`."},
+		{"", "c2", "v0.1.0", "c2", "## c2", "Changes since `v0.1.0` up to `c2`."},
+		{"c2", "HEAD", "c2", "v0.2.0", "## v0.2.0", "Changes since `c2` up to `v0.2.0`."},
+	}
+	for _, test := range tests {
+		start, release, err := ReleaseRange(repo, test.from, test.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if start != test.start || release != test.release {
+			t.Errorf("ReleaseRange(%q, %q) = %q, %q, want %q, %q", test.from, test.to, start, release, test.start, test.release)
+			continue
+		}
+
+		log, err := repo.Log(git.CommitOptions{Revisions: []string{start + ".." + test.to}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf strings.Builder
+		if err := WriteNotes(&buf, NewNotes(start, release, log)); err != nil {
+			t.Fatal(err)
+		}
+		if notes := buf.String(); !strings.HasPrefix(notes, test.heading+"\n\n"+test.sinceTag+"\n") {
+			t.Errorf("notes for %s..%s start:\n%s", test.from, test.to, notes)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package changelog
+
+import (
+	"context"
+
+	"human-git-history/internal/git"
+)
+
+// LatestTag returns the first tag met walking history back from rev,
+// leaving out tags on rev itself so that a release just tagged finds the
+// one before it. Of several tags on one commit it picks the highest
+// version, by CompareVersions. It returns "" when no tag is reachable.
+func LatestTag(repo git.Repository, rev string) (string, error) {
+	tags, err := git.TagsByCommit(repo)
+	if err != nil || len(tags) == 0 {
+		return "", err
+	}
+
+	it, err := repo.Stream(context.Background(), git.CommitOptions{Revisions: []string{rev}})
+	if err != nil {
+		return "", err
+	}
+	defer it.Close()
+	for first := true; it.Next(); first = false {
+		names := tags[it.Commit().Hash]
+		if first || len(names) == 0 {
+			continue
+		}
+		return highestVersion(names), nil
+	}
+	return "", it.Err()
+}
+
+// ReleaseRange works out the release notes for to: from defaults to the
+// latest tag before it, and the release is named after the tag on to when
+// it has one, so notes for a tagged HEAD are headed by that tag.
+func ReleaseRange(repo git.Repository, from, to string) (start, release string, err error) {
+	tags, err := git.TagsByCommit(repo)
+	if err != nil {
+		return "", "", err
+	}
+	release = to
+	if len(tags) > 0 {
+		it, err := repo.Stream(context.Background(), git.CommitOptions{Revisions: []string{to}, Limit: 1})
+		if err != nil {
+			return "", "", err
+		}
+		defer it.Close()
+		if it.Next() {
+			if names := tags[it.Commit().Hash]; len(names) > 0 {
+				release = highestVersion(names)
+			}
+		}
+		if err := it.Err(); err != nil {
+			return "", "", err
+		}
+	}
+
+	if from == "" {
+		if from, err = LatestTag(repo, to); err != nil {
+			return "", "", err
+		}
+	}
+	return from, release, nil
+}
+
+// highestVersion picks the highest of several tag names by
+// CompareVersions.
+func highestVersion(names []string) string {
+	highest := names[0]
+	for _, name := range names[1:] {
+		if CompareVersions(name, highest) > 0 {
+			highest = name
+		}
+	}
+	return highest
+}
@@ -0,0 +1,88 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"human-git-history/internal/git"
+)
+
+// csvHeader names the CSV columns, in order.
+var csvHeader = []string{
+	"hash", "short_hash",
+	"author_name", "author_email", "author_date",
+	"committer_name", "committer_email", "committer_date",
+	"subject", "body", "parents", "refs", "merge",
+	"files_changed", "insertions", "deletions", "files", "trailers",
+}
+
+// csvWriter writes a header row and one row per commit, flushing after each
+// so rows show up as they are read.
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+	return c.w.Write(csvHeader)
+}
+
+func (c *csvWriter) Write(commit git.Commit) error {
+	if err := c.writeHeader(); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	record := NewCommit(commit)
+
+	files := make([]string, len(record.Files))
+	for i, file := range record.Files {
+		if file.OldPath != "" {
+			files[i] = fmt.Sprintf("%s %s -> %s", file.Status, file.OldPath, file.Path)
+		} else {
+			files[i] = fmt.Sprintf("%s %s", file.Status, file.Path)
+		}
+	}
+
+	trailers := make([]string, len(record.Trailers))
+	for i, trailer := range record.Trailers {
+		trailers[i] = trailer.Key + ": " + trailer.Value
+	}
+
+	row := []string{
+		record.Hash, record.ShortHash,
+		record.Author.Name, record.Author.Email, record.Author.Date,
+		record.Committer.Name, record.Committer.Email, record.Committer.Date,
+		record.Subject, record.Body,
+		strings.Join(record.Parents, " "),
+		strings.Join(record.Refs, "\n"),
+		strconv.FormatBool(record.Merge),
+		strconv.Itoa(record.Stats.FilesChanged),
+		strconv.Itoa(record.Stats.Insertions),
+		strconv.Itoa(record.Stats.Deletions),
+		strings.Join(files, "\n"),
+		strings.Join(trailers, "\n"),
+	}
+	if err := c.w.Write(row); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return fmt.Errorf("failed to write CSV: %v", err)
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
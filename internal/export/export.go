@@ -0,0 +1,227 @@
+// Package export writes commits in machine-readable formats for scripts:
+// JSON, NDJSON, CSV and YAML. Every format is built from the same records,
+// which form a documented schema versioned by SchemaVersion, so output stays
+// stable when git.Commit changes.
+//
+// JSON and YAML write one document:
+//
+//	schema_version  integer, SchemaVersion
+//	range           revisions shown, omitted for the history of HEAD
+//	commits         list of commit records
+//
+// NDJSON writes one commit record per line, each with its own
+// schema_version. A commit record has:
+//
+//	hash, short_hash  full and abbreviated commit hash
+//	author            person: name, email, date (RFC 3339)
+//	committer         person: name, email when known, date
+//	subject, body     first line and rest of the message
+//	trailers          key, value of each line in the message's trailer
+//	                  block (Co-authored-by, Signed-off-by...), in order
+//	parents           parent hashes, first parent first
+//	refs              branch and tag names pointing at the commit
+//	merge             whether the commit has several parents
+//	stats             files_changed, insertions, deletions
+//	files             file records: status (Added, Modified, Deleted,
+//	                  Renamed, Copied...), path, old_path for renames and
+//	                  copies, insertions, deletions, similarity, binary,
+//	                  and pickaxe_match when -S or -G matched the file
+//
+// CSV writes a header and one row per commit with the columns in csvHeader.
+// Parents are separated by spaces; refs, files and trailers, one per line
+// within the cell, with files as "<status> <path>" or "<status> <old> ->
+// <new>" and trailers as "<key>: <value>".
+//
+// Bump SchemaVersion when a field is renamed, removed or changes meaning.
+// Adding a field does not need a new version.
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"human-git-history/internal/git"
+)
+
+// SchemaVersion identifies the layout of the records below.
+const SchemaVersion = 1
+
+// Formats lists the output formats this package writes.
+var Formats = []string{"json", "ndjson", "csv", "yaml"}
+
+// IsFormat reports whether format is one of Formats.
+func IsFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Commit is the exported form of a git.Commit.
+type Commit struct {
+	Hash      string    `json:"hash"`
+	ShortHash string    `json:"short_hash"`
+	Author    Person    `json:"author"`
+	Committer Person    `json:"committer"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	Trailers  []Trailer `json:"trailers"`
+	Parents   []string  `json:"parents"`
+	Refs      []string  `json:"refs"`
+	Merge     bool      `json:"merge"`
+	Stats     Stats     `json:"stats"`
+	Files     []File    `json:"files"`
+}
+
+// Person is the author or committer of a commit.
+type Person struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	Date  string `json:"date"`
+}
+
+// Trailer is one "Key: value" line of a commit message's trailer block.
+type Trailer struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Stats sums up the changes of a commit.
+type Stats struct {
+	FilesChanged int `json:"files_changed"`
+	Insertions   int `json:"insertions"`
+	Deletions    int `json:"deletions"`
+}
+
+// File is one changed file of a commit.
+type File struct {
+	Status       string `json:"status"`
+	Path         string `json:"path"`
+	OldPath      string `json:"old_path,omitempty"`
+	Insertions   int    `json:"insertions"`
+	Deletions    int    `json:"deletions"`
+	Similarity   int    `json:"similarity,omitempty"`
+	Binary       bool   `json:"binary"`
+	PickaxeMatch bool   `json:"pickaxe_match,omitempty"`
+}
+
+// NewCommit converts a commit to its exported form. Lists are never nil,
+// so they serialize as empty lists rather than null.
+func NewCommit(commit git.Commit) Commit {
+	record := Commit{
+		Hash:      commit.Hash,
+		ShortHash: commit.ShortHash,
+		Author: Person{
+			Name:  commit.AuthorName,
+			Email: commit.AuthorEmail,
+			Date:  formatDate(commit.AuthorDate),
+		},
+		Committer: Person{
+			Name:  commit.Committer,
+			Email: commit.CommitterEmail,
+			Date:  formatDate(commit.CommitDate),
+		},
+		Subject:  commit.Message,
+		Body:     commit.Body,
+		Trailers: make([]Trailer, 0, len(commit.Trailers)),
+		Parents:  append([]string{}, commit.ParentHashes...),
+		Refs:     append([]string{}, commit.RefNames...),
+		Merge:    len(commit.ParentHashes) > 1,
+		Files:    make([]File, 0, len(commit.FileChanges)),
+	}
+	for _, trailer := range commit.Trailers {
+		record.Trailers = append(record.Trailers, Trailer{Key: trailer.Key, Value: trailer.Value})
+	}
+	if commit.Stats != nil {
+		record.Stats = Stats{
+			FilesChanged: commit.Stats.FilesChanged,
+			Insertions:   commit.Stats.Insertions,
+			Deletions:    commit.Stats.Deletions,
+		}
+	}
+	for _, change := range commit.FileChanges {
+		record.Files = append(record.Files, File{
+			Status:       change.Status,
+			Path:         change.FilePath,
+			OldPath:      change.OldPath,
+			Insertions:   change.Insertions,
+			Deletions:    change.Deletions,
+			Similarity:   change.Similarity,
+			Binary:       change.Binary,
+			PickaxeMatch: change.PickaxeMatch,
+		})
+	}
+	return record
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Options selects the format written by Stream.
+type Options struct {
+	Format string // One of Formats
+	Range  string // Revisions being shown, recorded in JSON and YAML
+}
+
+// Writer serializes commits one at a time. Close finishes the document; it
+// does not close the underlying io.Writer.
+type Writer interface {
+	Write(commit git.Commit) error
+	Close() error
+}
+
+// NewWriter returns the Writer for options.Format.
+func NewWriter(w io.Writer, options Options) (Writer, error) {
+	switch options.Format {
+	case "json":
+		return &jsonWriter{w: w, rangeDesc: options.Range}, nil
+	case "ndjson":
+		return &ndjsonWriter{w: w}, nil
+	case "csv":
+		return newCSVWriter(w), nil
+	case "yaml":
+		return &yamlWriter{w: w, rangeDesc: options.Range}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q (available: %s)", options.Format, strings.Join(Formats, ", "))
+	}
+}
+
+// Stream writes the commits of it as they are read, so NDJSON consumers
+// see each commit as soon as it is parsed.
+func Stream(w io.Writer, it git.CommitIterator, options Options) error {
+	writer, err := NewWriter(w, options)
+	if err != nil {
+		return err
+	}
+	for it.Next() {
+		if err := writer.Write(it.Commit()); err != nil {
+			return err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// Write serializes a complete list of commits.
+func Write(w io.Writer, commits []git.Commit, options Options) error {
+	writer, err := NewWriter(w, options)
+	if err != nil {
+		return err
+	}
+	for _, commit := range commits {
+		if err := writer.Write(commit); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
@@ -0,0 +1,49 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"human-git-history/internal/git"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestGolden(t *testing.T) {
+	repo, err := git.LoadFixture("../git/testdata/history.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"json", "csv"} {
+		t.Run(format, func(t *testing.T) {
+			it, err := repo.Stream(context.Background(), git.CommitOptions{ShowFileChanges: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := Stream(&buf, it, Options{Format: format, Range: "v1.0.0..main"}); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join("testdata", "history."+format)
+			if *update {
+				if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("output differs from %s (run go test -update after checking it):\n%s", path, buf.Bytes())
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"human-git-history/internal/git"
+)
+
+// marshal encodes v as JSON without escaping HTML characters, which only
+// make names and messages harder to read.
+func marshal(v interface{}, prefix, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent(prefix, indent)
+	if err := encoder.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode commit: %v", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// jsonWriter streams one indented document. The header is written with the
+// first commit, so an empty history still gets a complete document.
+type jsonWriter struct {
+	w         io.Writer
+	rangeDesc string
+	count     int
+}
+
+func (j *jsonWriter) header() error {
+	rangeDesc, err := marshal(j.rangeDesc, "", "")
+	if err != nil {
+		return err
+	}
+	header := fmt.Sprintf("{\n  \"schema_version\": %d,\n", SchemaVersion)
+	if j.rangeDesc != "" {
+		header += fmt.Sprintf("  \"range\": %s,\n", rangeDesc)
+	}
+	_, err = io.WriteString(j.w, header+"  \"commits\": [")
+	return err
+}
+
+func (j *jsonWriter) Write(commit git.Commit) error {
+	separator := ","
+	if j.count == 0 {
+		if err := j.header(); err != nil {
+			return err
+		}
+		separator = ""
+	}
+	j.count++
+
+	data, err := marshal(NewCommit(commit), "    ", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(j.w, "%s\n    %s", separator, data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	if j.count == 0 {
+		if err := j.header(); err != nil {
+			return err
+		}
+		_, err := io.WriteString(j.w, "]\n}\n")
+		return err
+	}
+	_, err := io.WriteString(j.w, "\n  ]\n}\n")
+	return err
+}
+
+// ndjsonWriter writes every commit as one self-describing line.
+type ndjsonWriter struct {
+	w io.Writer
+}
+
+type ndjsonRecord struct {
+	SchemaVersion int `json:"schema_version"`
+	Commit
+}
+
+func (n *ndjsonWriter) Write(commit git.Commit) error {
+	data, err := marshal(ndjsonRecord{SchemaVersion: SchemaVersion, Commit: NewCommit(commit)}, "", "")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(n.w, "%s\n", data)
+	return err
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
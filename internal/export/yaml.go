@@ -0,0 +1,105 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"human-git-history/internal/git"
+)
+
+// yamlWriter streams one YAML document with the same keys as the JSON one.
+// The records are written by a small emitter over their json tags instead
+// of a YAML library: strings are double-quoted JSON strings, which YAML
+// reads unchanged, so no value needs further escaping.
+type yamlWriter struct {
+	w         io.Writer
+	rangeDesc string
+	count     int
+}
+
+func (y *yamlWriter) header() string {
+	header := fmt.Sprintf("schema_version: %d\n", SchemaVersion)
+	if y.rangeDesc != "" {
+		header += "range: " + yamlScalar(reflect.ValueOf(y.rangeDesc)) + "\n"
+	}
+	return header + "commits:"
+}
+
+func (y *yamlWriter) Write(commit git.Commit) error {
+	var b strings.Builder
+	if y.count == 0 {
+		b.WriteString(y.header() + "\n")
+	}
+	y.count++
+	writeYAMLStruct(&b, reflect.ValueOf(NewCommit(commit)), "    ", "  - ")
+	_, err := io.WriteString(y.w, b.String())
+	return err
+}
+
+func (y *yamlWriter) Close() error {
+	if y.count > 0 {
+		return nil
+	}
+	_, err := io.WriteString(y.w, y.header()+" []\n")
+	return err
+}
+
+// writeYAMLStruct writes the fields of a struct as a block mapping. The
+// first line starts with first, the others with indent, which lets the
+// mapping open a list item.
+func writeYAMLStruct(b *strings.Builder, v reflect.Value, indent, first string) {
+	prefix := first
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && value.Kind() == reflect.Struct {
+			writeYAMLStruct(b, value, indent, prefix)
+			prefix = indent
+			continue
+		}
+		if name == "" || name == "-" || (opts == "omitempty" && value.IsZero()) {
+			continue
+		}
+
+		b.WriteString(prefix + name + ":")
+		prefix = indent
+		switch value.Kind() {
+		case reflect.Struct:
+			b.WriteString("\n")
+			writeYAMLStruct(b, value, indent+"  ", indent+"  ")
+		case reflect.Slice:
+			if value.Len() == 0 {
+				b.WriteString(" []\n")
+				continue
+			}
+			b.WriteString("\n")
+			for j := 0; j < value.Len(); j++ {
+				item := value.Index(j)
+				if item.Kind() == reflect.Struct {
+					writeYAMLStruct(b, item, indent+"    ", indent+"  - ")
+				} else {
+					b.WriteString(indent + "  - " + yamlScalar(item) + "\n")
+				}
+			}
+		default:
+			b.WriteString(" " + yamlScalar(value) + "\n")
+		}
+	}
+}
+
+func yamlScalar(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		quoted, _ := marshal(v.String(), "", "")
+		return string(quoted)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
@@ -0,0 +1,91 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/fatih/color"
+)
+
+// theme colours each file status. The other colours in the output are
+// the same in every theme.
+type theme struct {
+	added, modified, deleted, renamed, other func(...interface{}) string
+}
+
+// styles are every colour the formats use, so SetColor can switch them all.
+var styles []*color.Color
+
+// newStyle returns a function colouring text with attrs.
+func newStyle(attrs ...color.Attribute) func(...interface{}) string {
+	c := color.New(attrs...)
+	styles = append(styles, c)
+	return c.SprintFunc()
+}
+
+// palette256 is a colour from the 256-colour palette most terminals have.
+func palette256(n int, attrs ...color.Attribute) func(...interface{}) string {
+	return newStyle(append([]color.Attribute{38, 5, color.Attribute(n)}, attrs...)...)
+}
+
+var themes = map[string]theme{
+	"default": {added: green, modified: yellow, deleted: red, renamed: cyan, other: magenta},
+	"solarized": {
+		added:    palette256(64),
+		modified: palette256(136),
+		deleted:  palette256(160),
+		renamed:  palette256(37),
+		other:    palette256(125),
+	},
+	"high-contrast": {
+		added:    newStyle(color.FgHiGreen, color.Bold),
+		modified: newStyle(color.FgHiYellow, color.Bold),
+		deleted:  newStyle(color.FgHiRed, color.Bold),
+		renamed:  newStyle(color.FgHiCyan, color.Bold),
+		other:    newStyle(color.FgHiMagenta, color.Bold),
+	},
+	// The Okabe-Ito colours, which stay apart with every common kind of
+	// colour blindness: no red against green.
+	"colorblind": {
+		added:    palette256(25),
+		modified: palette256(214),
+		deleted:  palette256(166),
+		renamed:  palette256(74),
+		other:    palette256(175),
+	},
+}
+
+var statusColors = themes["default"]
+
+// Themes lists the names SetTheme accepts.
+func Themes() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetTheme selects the colours of file statuses in every format.
+func SetTheme(name string) error {
+	t, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q", name)
+	}
+	statusColors = t
+	return nil
+}
+
+// SetColor turns colours on or off in every format, whatever standard
+// output is connected to and even when NO_COLOR was set at start-up.
+func SetColor(enabled bool) {
+	color.NoColor = !enabled
+	for _, c := range styles {
+		if enabled {
+			c.EnableColor()
+		} else {
+			c.DisableColor()
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package formatter
+
+import (
+	"fmt"
+	"time"
+
+	"human-git-history/internal/git"
+)
+
+// dateModes are the values SetDateMode accepts. Without one, absolute
+// dates are shown in the author's time zone and the compact layouts say
+// how long ago a commit was written.
+var dateModes = []string{"relative", "local", "iso", "rfc", "author-tz"}
+
+var dateMode string
+
+// DateModes lists the modes SetDateMode accepts.
+func DateModes() []string {
+	return dateModes
+}
+
+// SetDateMode chooses how every layout shows dates: relative ("3 days
+// ago"), local (in this machine's time zone), iso, rfc (RFC 2822) or
+// author-tz (in the author's time zone, with its offset).
+func SetDateMode(mode string) error {
+	if mode == "" {
+		dateMode = ""
+		return nil
+	}
+	for _, m := range dateModes {
+		if m == mode {
+			dateMode = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown date mode %q", mode)
+}
+
+// date shows t where the layouts print a full date.
+func (r *Renderer) date(t time.Time) string {
+	switch dateMode {
+	case "relative":
+		return formatTimeAgo(t, r.Now())
+	case "local":
+		return formatDate(t.Local())
+	case "iso":
+		return t.Format("2006-01-02 15:04:05 -0700")
+	case "rfc":
+		return t.Format("Mon, 2 Jan 2006 15:04:05 -0700")
+	default:
+		return formatDate(t)
+	}
+}
+
+// age shows t where the layouts print how long ago something happened,
+// unless a date mode asks for dates.
+func (r *Renderer) age(t time.Time) string {
+	if dateMode == "" || dateMode == "relative" {
+		return formatTimeAgo(t, r.Now())
+	}
+	return r.date(t)
+}
+
+// when is how long ago commit was written for the compact layouts,
+// followed by who committed it when that was not its author.
+func (r *Renderer) when(commit git.Commit) string {
+	written := r.age(commit.AuthorDate)
+	if commit.Recommitted() {
+		return written + ", committed by " + commit.Committer
+	}
+	return written
+}
@@ -0,0 +1,68 @@
+package formatter
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"human-git-history/internal/git"
+)
+
+func TestDateModes(t *testing.T) {
+	// Written in Kolkata, shown on a machine in New York.
+	written := time.Date(2024, 3, 5, 21, 15, 30, 0, time.FixedZone("", 5*3600+30*60))
+	local := time.Local
+	time.Local = time.FixedZone("EST", -5*3600)
+	defer func() { time.Local = local }()
+	defer SetDateMode("")
+
+	tests := []struct {
+		mode string
+		date string // Where a full date is shown
+		age  string // Where the compact layouts show an age
+	}{
+		{"", "Tue, 05 Mar 2024 21:15:30 +0530", "4 days ago"},
+		{"relative", "4 days ago", "4 days ago"},
+		{"local", "Tue, 05 Mar 2024 10:45:30 -0500", "Tue, 05 Mar 2024 10:45:30 -0500"},
+		{"iso", "2024-03-05 21:15:30 +0530", "2024-03-05 21:15:30 +0530"},
+		{"rfc", "Tue, 5 Mar 2024 21:15:30 +0530", "Tue, 5 Mar 2024 21:15:30 +0530"},
+		{"author-tz", "Tue, 05 Mar 2024 21:15:30 +0530", "Tue, 05 Mar 2024 21:15:30 +0530"},
+	}
+	r := NewRenderer(io.Discard, 80)
+	r.Now = func() time.Time { return fixtureNow }
+	for _, test := range tests {
+		if err := SetDateMode(test.mode); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.date(written); got != test.date {
+			t.Errorf("date in mode %q = %q, want %q", test.mode, got, test.date)
+		}
+		if got := r.age(written); got != test.age {
+			t.Errorf("age in mode %q = %q, want %q", test.mode, got, test.age)
+		}
+	}
+
+	if err := SetDateMode("julian"); err == nil {
+		t.Error("SetDateMode accepted an unknown mode")
+	}
+}
+
+func TestWhen(t *testing.T) {
+	SetDateMode("")
+	r := NewRenderer(io.Discard, 80)
+	r.Now = func() time.Time { return fixtureNow }
+	written := fixtureNow.Add(-50 * time.Hour)
+	commit := git.Commit{
+		AuthorName: "Ann", AuthorEmail: "ann@example.com", AuthorDate: written,
+		Committer: "Ann", CommitterEmail: "ANN@example.com", CommitDate: written.Add(time.Hour),
+	}
+
+	// Amending your own commit is not recommitting it.
+	if got := r.when(commit); got != "2 days ago" {
+		t.Errorf("when by the author = %q", got)
+	}
+	commit.Committer, commit.CommitterEmail = "Sam", "sam@example.com"
+	if got := r.when(commit); got != "2 days ago, committed by Sam" {
+		t.Errorf("when by someone else = %q", got)
+	}
+}
@@ -2,204 +2,512 @@ package formatter
 
 import (
 	"fmt"
+	"human-git-history/internal/changelog"
 	"human-git-history/internal/git"
+	"io"
+	"regexp"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/fatih/color"
 )
 
 var (
-	yellow    = color.New(color.FgYellow).SprintFunc()
-	green     = color.New(color.FgGreen).SprintFunc()
-	cyan      = color.New(color.FgCyan).SprintFunc()
-	red       = color.New(color.FgRed).SprintFunc()
-	blue      = color.New(color.FgBlue).SprintFunc()
-	magenta   = color.New(color.FgMagenta).SprintFunc()
-	white     = color.New(color.FgWhite).SprintFunc()
-	bold      = color.New(color.Bold).SprintFunc()
-	dim       = color.New(color.Faint).SprintFunc()
-	highlight = color.New(color.BgHiBlack, color.FgHiWhite).SprintFunc()
+	yellow    = newStyle(color.FgYellow)
+	green     = newStyle(color.FgGreen)
+	cyan      = newStyle(color.FgCyan)
+	red       = newStyle(color.FgRed)
+	blue      = newStyle(color.FgBlue)
+	magenta   = newStyle(color.FgMagenta)
+	white     = newStyle(color.FgWhite)
+	bold      = newStyle(color.Bold)
+	dim       = newStyle(color.Faint)
+	highlight = newStyle(color.BgHiBlack, color.FgHiWhite)
+	matched   = newStyle(color.BgYellow, color.FgBlack)
 )
 
-func PrintHumanFriendly(commits []git.Commit, compact bool, showStats bool, showGraph bool, showFiles bool) {
-	for i, commit := range commits {
-		if showGraph {
-			printGraphLine(i, len(commits))
+// Renderer prints commits in the terminal formats to any io.Writer. With a
+// width set, separators span it, bodies wrap and long subjects and paths
+// are shortened to fit; with none, lines are never broken.
+type Renderer struct {
+	w     io.Writer
+	width int
+	// Now is the clock relative dates ("3 days ago") are measured
+	// against, time.Now unless set.
+	Now func() time.Time
+}
+
+// NewRenderer returns a Renderer writing to w, fitting output to width
+// columns, or without a limit when width is zero.
+func NewRenderer(w io.Writer, width int) *Renderer {
+	return &Renderer{w: w, width: width, Now: time.Now}
+}
+
+// ruleWidth is the length of separator lines: the full width, or 80
+// columns when there is no limit.
+func (r *Renderer) ruleWidth() int {
+	if r.width <= 0 {
+		return 80
+	}
+	return r.width
+}
+
+// fits reports the room left on a line after used columns; zero when
+// there is no limit, and at least 1 otherwise.
+func (r *Renderer) fits(used int) int {
+	if r.width <= 0 {
+		return 0
+	}
+	if r.width-used < 1 {
+		return 1
+	}
+	return r.width - used
+}
+
+// matchPattern is the --grep pattern highlighted in commit messages.
+var matchPattern *regexp.Regexp
+
+// SetHighlight makes every format highlight the text pattern matches in
+// commit subjects and bodies; nil turns highlighting off.
+func SetHighlight(pattern *regexp.Regexp) {
+	matchPattern = pattern
+}
+
+// Options selects the layout used by Stream and PrintGraph.
+type Options struct {
+	Format     string // detailed, compact, oneline or changelog; empty for the default
+	Compact    bool
+	ShowStats  bool
+	ShowFiles  bool
+	Range      string             // Revisions being shown, printed as a header when set
+	Template   *template.Template // Prints each commit instead of Format when set
+	GraphStyle string             // unicode or ascii lines for PrintGraph
+	// ByTag splits the changelog into a section per tag in Tags instead
+	// of one per day.
+	ByTag bool
+	Tags  []git.Tag
+	// Patch shows the diff of each commit, read with Diff, below it
+	// when its Mode is set. Templates and changelogs leave it out.
+	Patch PatchOptions
+	Diff  func(commit git.Commit) (string, error)
+}
+
+// Stream prints commits as they are read from it, so the first commit shows
+// up before the whole history has been walked. The graph needs the full list
+// of commits and is only drawn by PrintGraph.
+func (r *Renderer) Stream(it git.CommitIterator, options Options) error {
+	if options.Template == nil {
+		r.PrintRangeHeader(options.Format, options.Range)
+	}
+	if options.Format == "changelog" && options.Template == nil {
+		return r.streamChangelog(it, options)
+	}
+	between, printCommit := r.layout(options)
+	for i := 0; it.Next(); i++ {
+		commit := it.Commit()
+		between(i, commit)
+		if err := printCommit(commit); err != nil {
+			return err
 		}
-		
-		printCommitHeader(commit, compact)
-		
-		if !compact && commit.Body != "" {
-			printCommitBody(commit.Body)
+	}
+	return it.Err()
+}
+
+// PrintRangeHeader says which revisions a listing covers, in the style of
+// the format. Nothing is printed for an empty range.
+func (r *Renderer) PrintRangeHeader(format string, rangeDesc string) {
+	if rangeDesc == "" {
+		return
+	}
+	switch format {
+	case "compact", "oneline":
+		fmt.Fprintln(r.w, dim("# "+rangeDesc))
+	case "changelog":
+		fmt.Fprintf(r.w, "%s %s\n", bold("# Changes in"), cyan(rangeDesc))
+	case "detailed":
+		fmt.Fprintf(r.w, "%s %s\n", bold("Range:"), cyan(rangeDesc))
+		fmt.Fprintln(r.w, strings.Repeat("=", r.ruleWidth()))
+		fmt.Fprintln(r.w)
+	default:
+		fmt.Fprintf(r.w, "%s %s\n\n", bold("Range:"), cyan(rangeDesc))
+	}
+}
+
+// layout returns how a listing is printed in the chosen format: between
+// prints what comes before the i-th commit (separators, section headings)
+// and printCommit the commit itself. Only templates can fail.
+func (r *Renderer) layout(options Options) (between func(i int, commit git.Commit), printCommit func(commit git.Commit) error) {
+	between = func(i int, commit git.Commit) {}
+	if options.Template != nil {
+		return between, r.templatePrinter(options.Template)
+	}
+
+	switch options.Format {
+	case "detailed":
+		between = func(i int, commit git.Commit) {
+			if i > 0 {
+				r.printDetailedSeparator()
+			}
 		}
-		
-		if showFiles && len(commit.FileChanges) > 0 {
-			printFileChanges(commit.FileChanges)
+		printCommit = func(commit git.Commit) error {
+			r.printDetailedCommit(commit, options.ShowStats, options.ShowFiles)
+			return nil
 		}
-		
-		if showStats && commit.Stats != nil {
-			printCommitStats(*commit.Stats)
+	case "compact":
+		printCommit = func(commit git.Commit) error {
+			r.printCompactCommit(commit, options.ShowFiles)
+			return nil
 		}
-		
-		if !compact && len(commit.RefNames) > 0 {
-			printRefNames(commit.RefNames)
+	case "oneline":
+		printCommit = func(commit git.Commit) error {
+			r.printOnelineCommit(commit, options.ShowFiles)
+			return nil
 		}
-		
-		if i < len(commits)-1 && !compact {
-			fmt.Println(dim(strings.Repeat("─", 80)))
+	case "changelog":
+		between = r.changelogSections(options)
+		printCommit = func(commit git.Commit) error {
+			entry := changelog.NewEntry(commit)
+			mark := cyan(entry.Change.Section()+":") + " " + breakingMark(entry.Change)
+			r.printChangelogCommit(commit, entry.Change, mark, options.ShowFiles)
+			return nil
 		}
+	default:
+		between = func(i int, commit git.Commit) {
+			if i > 0 && !options.Compact {
+				r.printHumanFriendlySeparator()
+			}
+		}
+		printCommit = func(commit git.Commit) error {
+			r.printHumanFriendlyCommit(commit, options.Compact, options.ShowStats, options.ShowFiles)
+			return nil
+		}
+	}
+	if options.Patch.Mode != "" && options.Diff != nil && options.Format != "changelog" {
+		printCommit = r.patchPrinter(printCommit, options)
 	}
+	return between, printCommit
 }
 
-func PrintDetailed(commits []git.Commit, showStats bool, showGraph bool, showFiles bool) {
+func (r *Renderer) PrintHumanFriendly(commits []git.Commit, compact bool, showStats bool, showGraph bool, showFiles bool) {
+	options := Options{Compact: compact, ShowStats: showStats, ShowFiles: showFiles}
+	if showGraph {
+		r.PrintGraph(commits, options)
+		return
+	}
 	for i, commit := range commits {
-		if showGraph {
-			printGraphLine(i, len(commits))
+		if i > 0 && !compact {
+			r.printHumanFriendlySeparator()
 		}
-		
-		fmt.Printf("%s %s\n", bold("Commit:"), highlight(commit.ShortHash))
-		fmt.Printf("%s %s\n", bold("Hash:"), commit.Hash)
-		fmt.Printf("%s %s <%s>\n", bold("Author:"), yellow(commit.AuthorName), commit.AuthorEmail)
-		fmt.Printf("%s %s\n", bold("Date:"), formatDate(commit.AuthorDate))
-		fmt.Printf("%s %s\n\n", bold("Message:"), white(commit.Message))
-		
-		if commit.Body != "" {
-			fmt.Printf("%s\n%s\n\n", bold("Description:"), cyan(commit.Body))
-		}
-		
-		if showFiles && len(commit.FileChanges) > 0 {
-			printDetailedFileChanges(commit.FileChanges)
-			fmt.Println()
+		r.printHumanFriendlyCommit(commit, compact, showStats, showFiles)
+	}
+}
+
+func (r *Renderer) printHumanFriendlySeparator() {
+	fmt.Fprintln(r.w, dim(strings.Repeat("─", r.ruleWidth())))
+}
+
+func (r *Renderer) printHumanFriendlyCommit(commit git.Commit, compact bool, showStats bool, showFiles bool) {
+	r.printCommitHeader(commit, compact)
+
+	if !compact && commit.Body != "" {
+		r.printCommitBody(commit)
+	}
+
+	if showFiles && len(commit.FileChanges) > 0 {
+		r.printFileChanges(commit.FileChanges)
+	}
+
+	if showStats && commit.Stats != nil {
+		r.printCommitStats(*commit.Stats)
+	}
+
+	if !compact && len(commit.RefNames) > 0 {
+		r.printRefNames(commit.RefNames)
+	}
+}
+
+func (r *Renderer) PrintDetailed(commits []git.Commit, showStats bool, showGraph bool, showFiles bool) {
+	if showGraph {
+		r.PrintGraph(commits, Options{Format: "detailed", ShowStats: showStats, ShowFiles: showFiles})
+		return
+	}
+	for i, commit := range commits {
+		if i > 0 {
+			r.printDetailedSeparator()
 		}
-		
-		if showStats && commit.Stats != nil {
-			printCommitStats(*commit.Stats)
-			fmt.Println()
+		r.printDetailedCommit(commit, showStats, showFiles)
+	}
+}
+
+func (r *Renderer) printDetailedSeparator() {
+	fmt.Fprintln(r.w, strings.Repeat("=", r.ruleWidth()))
+	fmt.Fprintln(r.w)
+}
+
+func (r *Renderer) printDetailedCommit(commit git.Commit, showStats bool, showFiles bool) {
+	fmt.Fprintf(r.w, "%s %s\n", bold("Commit:"), highlight(commit.ShortHash))
+	fmt.Fprintf(r.w, "%s %s\n", bold("Hash:"), commit.Hash)
+	fmt.Fprintf(r.w, "%s %s <%s>\n", bold("Author:"), yellow(commit.AuthorName), commit.AuthorEmail)
+	fmt.Fprintf(r.w, "%s %s\n", bold("Date:"), r.date(commit.AuthorDate))
+	if commit.Recommitted() {
+		fmt.Fprintf(r.w, "%s %s <%s>\n", bold("Committer:"), yellow(commit.Committer), commit.CommitterEmail)
+		fmt.Fprintf(r.w, "%s %s\n", bold("Committed:"), r.date(commit.CommitDate))
+	}
+	r.printWrapped(bold("Message:")+" ", strings.Repeat(" ", len("Message: ")), commit.Message, white)
+	fmt.Fprintln(r.w)
+
+	if body := commit.BodyText(); body != "" {
+		fmt.Fprintf(r.w, "%s\n", bold("Description:"))
+		for _, line := range strings.Split(body, "\n") {
+			r.printWrapped("", "", line, cyan)
 		}
-		
-		if len(commit.RefNames) > 0 {
-			printRefNames(commit.RefNames)
-			fmt.Println()
+		fmt.Fprintln(r.w)
+	}
+
+	if len(commit.Trailers) > 0 {
+		r.printTrailers(commit.Trailers)
+		fmt.Fprintln(r.w)
+	}
+
+	if showFiles && len(commit.FileChanges) > 0 {
+		r.printDetailedFileChanges(commit.FileChanges)
+		fmt.Fprintln(r.w)
+	}
+
+	if showStats && commit.Stats != nil {
+		r.printCommitStats(*commit.Stats)
+		fmt.Fprintln(r.w)
+	}
+
+	if len(commit.RefNames) > 0 {
+		r.printRefNames(commit.RefNames)
+		fmt.Fprintln(r.w)
+	}
+}
+
+// printTrailers lists a commit's trailers under aligned keys, with the
+// people in Co-authored-by coloured like authors.
+func (r *Renderer) printTrailers(trailers []git.Trailer) {
+	fmt.Fprintf(r.w, "%s\n", bold("Trailers:"))
+	keyWidth := 0
+	for _, trailer := range trailers {
+		keyWidth = max(keyWidth, displayWidth(trailer.Key))
+	}
+	for _, trailer := range trailers {
+		lead := "  " + trailer.Key + ":" + strings.Repeat(" ", keyWidth-displayWidth(trailer.Key)+1)
+		style := fmt.Sprint
+		if strings.EqualFold(trailer.Key, git.CoAuthorKey) {
+			style = yellow
 		}
-		
-		if i < len(commits)-1 {
-			fmt.Println(strings.Repeat("=", 80))
-			fmt.Println()
+		r.printWrapped(dim(lead), strings.Repeat(" ", displayWidth(lead)), trailer.Value, style)
+	}
+}
+
+func (r *Renderer) PrintCompact(commits []git.Commit, showFiles bool) {
+	for _, commit := range commits {
+		r.printCompactCommit(commit, showFiles)
+	}
+}
+
+func (r *Renderer) printCompactCommit(commit git.Commit, showFiles bool) {
+	timeAgo := r.when(commit)
+	branchInfo := ""
+	if branches := getBranchNames(commit.RefNames); len(branches) > 0 {
+		branchInfo = fmt.Sprintf(" [%s]", strings.Join(branches, ", "))
+	}
+
+	// The subject gives way first, then the refs, so the author and date
+	// stay on the line.
+	fixed := displayWidth(fmt.Sprintf("%s  - %s (%s)", commit.ShortHash, commit.AuthorName, timeAgo))
+	message := r.subject(commit.Message, fixed+displayWidth(branchInfo))
+	if r.width > 0 && fixed+displayWidth(message)+displayWidth(branchInfo) > r.width {
+		if room := r.width - fixed - displayWidth(message); room >= 4 {
+			branchInfo = truncateEnd(branchInfo, room)
+		} else {
+			branchInfo = ""
 		}
 	}
+	fmt.Fprintf(r.w, "%s %s - %s (%s)%s\n",
+		green(commit.ShortHash),
+		markMatches(message, white),
+		yellow(commit.AuthorName),
+		dim(timeAgo),
+		magenta(branchInfo),
+	)
+
+	if showFiles && len(commit.FileChanges) > 0 {
+		r.printFileChangesCompact(commit.FileChanges)
+	}
 }
 
-func PrintCompact(commits []git.Commit, showFiles bool) {
+func (r *Renderer) PrintOneline(commits []git.Commit, showFiles bool) {
 	for _, commit := range commits {
-		timeAgo := formatTimeAgo(commit.AuthorDate)
-		branchInfo := ""
-		if len(commit.RefNames) > 0 {
-			branchInfo = fmt.Sprintf(" [%s]", strings.Join(getBranchNames(commit.RefNames), ", "))
+		r.printOnelineCommit(commit, showFiles)
+	}
+}
+
+func (r *Renderer) printOnelineCommit(commit git.Commit, showFiles bool) {
+	message := r.subject(commit.Message, displayWidth(commit.ShortHash)+1)
+	fmt.Fprintf(r.w, "%s %s\n",
+		green(commit.ShortHash),
+		markMatches(message, fmt.Sprint),
+	)
+
+	if showFiles && len(commit.FileChanges) > 0 {
+		for _, change := range commit.FileChanges {
+			statusColor := getStatusColor(change.Status)
+			r.printFileLine("  ", statusColor(change.Status[:1])+" ", change.FilePath, pickaxeMark(change))
 		}
-		
-		fmt.Printf("%s %s - %s (%s)%s\n",
-			green(commit.ShortHash),
-			white(commit.Message),
-			yellow(commit.AuthorName),
-			dim(timeAgo),
-			magenta(branchInfo),
-		)
-		
-		if showFiles && len(commit.FileChanges) > 0 {
-			printFileChangesCompact(commit.FileChanges)
+	}
+}
+
+// PrintChangelog prints commits in Keep a Changelog sections, one block
+// per day.
+func (r *Renderer) PrintChangelog(commits []git.Commit, showFiles bool) {
+	for _, release := range changelog.ByDay(commits) {
+		r.printRelease(release, showFiles)
+	}
+}
+
+// streamChangelog prints each release as soon as its last commit is read.
+func (r *Renderer) streamChangelog(it git.CommitIterator, options Options) error {
+	splitter := changelog.NewSplitter(options.ByTag, options.Tags)
+	for it.Next() {
+		if release, ok := splitter.Add(it.Commit()); ok {
+			r.printRelease(release, options.ShowFiles)
 		}
 	}
+	if release, ok := splitter.Flush(); ok {
+		r.printRelease(release, options.ShowFiles)
+	}
+	return it.Err()
 }
 
-func PrintOneline(commits []git.Commit, showFiles bool) {
-	for _, commit := range commits {
-		fmt.Printf("%s %s\n",
-			green(commit.ShortHash),
-			commit.Message,
-		)
-		
-		if showFiles && len(commit.FileChanges) > 0 {
-			for _, change := range commit.FileChanges {
-				statusColor := getStatusColor(change.Status)
-				fmt.Printf("  %s %s\n", statusColor(change.Status[:1]), change.FilePath)
-			}
+func (r *Renderer) printRelease(release changelog.Release, showFiles bool) {
+	r.printReleaseHeading(release)
+	for _, group := range release.Groups {
+		fmt.Fprintf(r.w, "%s %s\n", bold("###"), bold(group.Name))
+		for _, entry := range group.Entries {
+			r.printChangelogCommit(entry.Commit, entry.Change, breakingMark(entry.Change), showFiles)
 		}
 	}
 }
 
-func PrintChangelog(commits []git.Commit, showFiles bool) {
-	currentDate := ""
-	for _, commit := range commits {
-		commitDate := commit.AuthorDate.Format("2006-01-02")
-		if commitDate != currentDate {
-			currentDate = commitDate
-			fmt.Printf("\n%s %s\n", bold("##"), formatDate(commit.AuthorDate))
-		}
-		
-		fmt.Printf("- %s", commit.Message)
-		
-		if len(commit.RefNames) > 0 {
-			fmt.Printf(" %s", magenta("["+strings.Join(getBranchNames(commit.RefNames), ", ")+"]"))
-		}
-		fmt.Printf(" %s\n", dim("("+commit.AuthorName+")"))
-		
-		if showFiles && len(commit.FileChanges) > 0 {
-			fmt.Println("  Changes:")
-			for _, change := range commit.FileChanges {
-				statusSymbol := getStatusSymbol(change.Status)
-				statusColor := getStatusColor(change.Status)
-				fmt.Printf("    %s %s", statusColor(statusSymbol), change.FilePath)
-				if change.Insertions > 0 || change.Deletions > 0 {
-					fmt.Printf(" (+%d/-%d)", change.Insertions, change.Deletions)
-				}
-				if change.OldPath != "" {
-					fmt.Printf(" (from %s)", dim(change.OldPath))
-				}
-				fmt.Println()
+// printReleaseHeading starts a section: the day, or the tag with its date
+// and message.
+func (r *Renderer) printReleaseHeading(release changelog.Release) {
+	switch {
+	case release.Version == "":
+		fmt.Fprintf(r.w, "\n%s %s\n", bold("##"), r.date(release.Date))
+	case release.Date.IsZero():
+		fmt.Fprintf(r.w, "\n%s %s\n", bold("##"), yellow(release.Version))
+	default:
+		fmt.Fprintf(r.w, "\n%s %s %s\n", bold("##"), yellow(release.Version), dim("("+r.date(release.Date)+")"))
+	}
+	for _, line := range strings.Split(release.Message, "\n") {
+		if line != "" {
+			r.printWrapped("   ", "   ", line, dim)
+		}
+	}
+}
+
+// changelogSections starts a new section at each release. It lays out the
+// changelog beside the graph, where commits stay in graph order and are
+// labelled with their section instead.
+func (r *Renderer) changelogSections(options Options) func(i int, commit git.Commit) {
+	splitter := changelog.NewSplitter(options.ByTag, options.Tags)
+	return func(i int, commit git.Commit) {
+		if _, ok := splitter.Add(commit); ok || i == 0 {
+			r.printReleaseHeading(splitter.Current())
+		}
+	}
+}
+
+func breakingMark(change changelog.Change) string {
+	if !change.Breaking {
+		return ""
+	}
+	return red(bold("BREAKING")) + " "
+}
+
+// printChangelogCommit prints an entry of the changelog, with mark (the
+// coloured labels of the entry) before its text.
+func (r *Renderer) printChangelogCommit(commit git.Commit, change changelog.Change, mark string, showFiles bool) {
+	tail := dim("(" + commit.AuthorName + ")")
+	if branches := getBranchNames(commit.RefNames); len(branches) > 0 {
+		tail = magenta("["+strings.Join(branches, ", ")+"]") + " " + tail
+	}
+	lines := wrapText(change.Text(), r.fits(2+displayWidth(mark)))
+	for i, line := range lines {
+		lead := "  "
+		if i == 0 {
+			lead = "- " + mark
+		}
+		fmt.Fprint(r.w, lead+markMatches(line, fmt.Sprint))
+		if i < len(lines)-1 {
+			fmt.Fprintln(r.w)
+		} else if r.width > 0 && displayWidth(lead)+displayWidth(line)+1+displayWidth(tail) > r.width {
+			fmt.Fprintf(r.w, "\n  %s\n", tail)
+		} else {
+			fmt.Fprintf(r.w, " %s\n", tail)
+		}
+	}
+
+	if showFiles && len(commit.FileChanges) > 0 {
+		fmt.Fprintln(r.w, "  Changes:")
+		for _, change := range commit.FileChanges {
+			statusSymbol := getStatusSymbol(change.Status)
+			statusColor := getStatusColor(change.Status)
+			var notes []string
+			if counts := lineCounts(change); counts != "" {
+				notes = append(notes, counts)
+			}
+			if change.OldPath != "" {
+				notes = append(notes, "("+dim(sourceNote(change))+")")
 			}
+			notes = append(notes, pickaxeMark(change))
+			r.printFileLine("    ", statusColor(statusSymbol)+" ", change.FilePath, notes...)
 		}
-		
-		if commit.Body != "" {
-			lines := strings.Split(strings.TrimSpace(commit.Body), "\n")
-			for _, line := range lines {
-				if line != "" {
-					fmt.Printf("  %s\n", dim("  "+line))
-				}
+	}
+
+	if body := commit.BodyText(); body != "" {
+		for _, line := range strings.Split(body, "\n") {
+			if line != "" {
+				r.printWrapped("    ", "    ", line, dim)
 			}
 		}
 	}
 }
 
-func printFileChanges(changes []git.FileChange) {
-	fmt.Printf("    %s\n", bold("Files:"))
+func (r *Renderer) printFileChanges(changes []git.FileChange) {
+	fmt.Fprintf(r.w, "    %s\n", bold("Files:"))
 	for _, change := range changes {
 		statusColor := getStatusColor(change.Status)
 		statusSymbol := getStatusSymbol(change.Status)
-		
-		fmt.Printf("    %s %s", statusColor(statusSymbol), change.FilePath)
-		
-		if change.Insertions > 0 || change.Deletions > 0 {
-			fmt.Printf(" %s", dim(fmt.Sprintf("(+%d/-%d)", change.Insertions, change.Deletions)))
+
+		var notes []string
+		if counts := lineCounts(change); counts != "" {
+			notes = append(notes, dim(counts))
 		}
-		
+
 		if change.OldPath != "" {
-			fmt.Printf(" %s", dim(fmt.Sprintf("(renamed from %s)", change.OldPath)))
+			notes = append(notes, dim("("+sourceNote(change)+")"))
 		}
-		
-		fmt.Println()
+
+		r.printFileLine("    ", statusColor(statusSymbol)+" ", change.FilePath, append(notes, pickaxeMark(change))...)
 	}
 }
 
-func printDetailedFileChanges(changes []git.FileChange) {
-	fmt.Printf("%s\n", bold("File Changes:"))
-	
+func (r *Renderer) printDetailedFileChanges(changes []git.FileChange) {
+	fmt.Fprintf(r.w, "%s\n", bold("File Changes:"))
+
 	added := []git.FileChange{}
 	modified := []git.FileChange{}
 	deleted := []git.FileChange{}
 	renamed := []git.FileChange{}
 	other := []git.FileChange{}
-	
+
 	for _, change := range changes {
 		switch change.Status {
 		case "Added":
@@ -208,87 +516,202 @@ func printDetailedFileChanges(changes []git.FileChange) {
 			modified = append(modified, change)
 		case "Deleted":
 			deleted = append(deleted, change)
-		case "Renamed":
+		case "Renamed", "Copied":
 			renamed = append(renamed, change)
 		default:
 			other = append(other, change)
 		}
 	}
-	
+
 	if len(added) > 0 {
-		fmt.Printf("  %s:\n", green("Added"))
+		fmt.Fprintf(r.w, "  %s:\n", statusColors.added("Added"))
 		for _, change := range added {
-			fmt.Printf("    %s", change.FilePath)
-			if change.Insertions > 0 {
-				fmt.Printf(" %s", dim(fmt.Sprintf("(+%d lines)", change.Insertions)))
+			note := ""
+			if change.Binary {
+				note = dim("(binary)")
+			} else if change.Insertions > 0 {
+				note = dim(fmt.Sprintf("(+%d lines)", change.Insertions))
 			}
-			fmt.Println()
+			r.printFileLine("    ", "", change.FilePath, note, pickaxeMark(change))
 		}
 	}
-	
+
 	if len(modified) > 0 {
-		fmt.Printf("  %s:\n", yellow("Modified"))
+		fmt.Fprintf(r.w, "  %s:\n", statusColors.modified("Modified"))
 		for _, change := range modified {
-			fmt.Printf("    %s", change.FilePath)
-			if change.Insertions > 0 || change.Deletions > 0 {
-				fmt.Printf(" %s", dim(fmt.Sprintf("(+%d/-%d)", change.Insertions, change.Deletions)))
+			note := ""
+			if counts := lineCounts(change); counts != "" {
+				note = dim(counts)
 			}
-			fmt.Println()
+			r.printFileLine("    ", "", change.FilePath, note, pickaxeMark(change))
 		}
 	}
-	
+
 	if len(deleted) > 0 {
-		fmt.Printf("  %s:\n", red("Deleted"))
+		fmt.Fprintf(r.w, "  %s:\n", statusColors.deleted("Deleted"))
 		for _, change := range deleted {
-			fmt.Printf("    %s", change.FilePath)
+			note := ""
 			if change.Deletions > 0 {
-				fmt.Printf(" %s", dim(fmt.Sprintf("(-%d lines)", change.Deletions)))
+				note = dim(fmt.Sprintf("(-%d lines)", change.Deletions))
 			}
-			fmt.Println()
+			r.printFileLine("    ", "", change.FilePath, note, pickaxeMark(change))
 		}
 	}
-	
+
 	if len(renamed) > 0 {
-		fmt.Printf("  %s:\n", cyan("Renamed"))
+		fmt.Fprintf(r.w, "  %s:\n", statusColors.renamed("Renamed/Copied"))
 		for _, change := range renamed {
-			fmt.Printf("    %s → %s", dim(change.OldPath), change.FilePath)
-			fmt.Println()
+			var notes []string
+			if change.Status == "Copied" {
+				notes = append(notes, dim("(copy)"))
+			}
+			if change.Similarity > 0 && change.Similarity < 100 {
+				notes = append(notes, dim(fmt.Sprintf("%d%% similar", change.Similarity)))
+			}
+			if counts := lineCounts(change); counts != "" {
+				notes = append(notes, dim(counts))
+			}
+			// Both paths share the line, the old one getting at most half.
+			oldPath := truncatePath(change.OldPath, r.fits(4)/2)
+			r.printFileLine("    ", dim(oldPath)+" → ", change.FilePath, append(notes, pickaxeMark(change))...)
 		}
 	}
-	
+
 	if len(other) > 0 {
-		fmt.Printf("  %s:\n", magenta("Other"))
+		fmt.Fprintf(r.w, "  %s:\n", statusColors.other("Other"))
 		for _, change := range other {
-			fmt.Printf("    %s %s", change.Status, change.FilePath)
-			fmt.Println()
+			r.printFileLine("    ", change.Status+" ", change.FilePath, pickaxeMark(change))
 		}
 	}
 }
 
-func printFileChangesCompact(changes []git.FileChange) {
+func (r *Renderer) printFileChangesCompact(changes []git.FileChange) {
 	for _, change := range changes {
 		statusColor := getStatusColor(change.Status)
 		statusSymbol := getStatusSymbol(change.Status)
-		fmt.Printf("  %s %s", statusColor(statusSymbol), change.FilePath)
-		if change.Insertions > 0 || change.Deletions > 0 {
-			fmt.Printf(" %s", dim(fmt.Sprintf("(+%d/-%d)", change.Insertions, change.Deletions)))
+		note := ""
+		if counts := lineCounts(change); counts != "" {
+			note = dim(counts)
+		}
+		r.printFileLine("  ", statusColor(statusSymbol)+" ", change.FilePath, note, pickaxeMark(change))
+	}
+}
+
+// lineCounts is the "(+3/-1)" summary of a change, "(binary)" for binary
+// files and empty when no lines changed.
+func lineCounts(change git.FileChange) string {
+	if change.Binary {
+		return "(binary)"
+	}
+	if change.Insertions > 0 || change.Deletions > 0 {
+		return fmt.Sprintf("(+%d/-%d)", change.Insertions, change.Deletions)
+	}
+	return ""
+}
+
+// sourceNote says where a renamed or copied file came from, with the
+// similarity when the content changed too.
+func sourceNote(change git.FileChange) string {
+	verb := "renamed"
+	if change.Status == "Copied" {
+		verb = "copied"
+	}
+	if change.Similarity > 0 && change.Similarity < 100 {
+		return fmt.Sprintf("%s from %s, %d%% similar", verb, change.OldPath, change.Similarity)
+	}
+	return fmt.Sprintf("%s from %s", verb, change.OldPath)
+}
+
+// minSubject is the fewest columns a shortened subject keeps, even when
+// the rest of its line leaves less room.
+const minSubject = 20
+
+// subject shortens a commit subject to the room left beside used columns
+// of a one-line listing.
+func (r *Renderer) subject(message string, used int) string {
+	if r.width <= 0 {
+		return message
+	}
+	return truncateEnd(message, max(r.width-used, minSubject))
+}
+
+// printWrapped prints text wrapped to the width in style, the first line
+// after lead and the others after indent.
+func (r *Renderer) printWrapped(lead, indent, text string, style func(...interface{}) string) {
+	for i, line := range wrapText(text, r.fits(displayWidth(lead))) {
+		if i > 0 {
+			lead = indent
+		}
+		fmt.Fprintln(r.w, lead+markMatches(line, style))
+	}
+}
+
+// printFileLine prints a file of a change list: indent and lead, then the
+// path and the notes about it. Notes that do not fit move to the next
+// line, and paths too long for a line of their own lose their middle.
+func (r *Renderer) printFileLine(indent, lead, path string, notes ...string) {
+	line := indent + lead + truncatePath(path, r.fits(displayWidth(indent+lead)))
+	for _, note := range notes {
+		if note == "" {
+			continue
+		}
+		if r.width > 0 && displayWidth(line)+1+displayWidth(note) > r.width {
+			fmt.Fprintln(r.w, line)
+			line = indent + strings.Repeat(" ", displayWidth(lead)+2) + note
+			continue
+		}
+		line += " " + note
+	}
+	fmt.Fprintln(r.w, line)
+}
+
+// markMatches renders text in style, with the parts matching the --grep
+// pattern highlighted.
+func markMatches(text string, style func(...interface{}) string) string {
+	if matchPattern == nil {
+		return style(text)
+	}
+	var out strings.Builder
+	last := 0
+	for _, loc := range matchPattern.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		if loc[0] > last {
+			out.WriteString(style(text[last:loc[0]]))
 		}
-		fmt.Println()
+		out.WriteString(matched(text[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	if last == 0 {
+		return style(text)
 	}
+	if last < len(text) {
+		out.WriteString(style(text[last:]))
+	}
+	return out.String()
+}
+
+// pickaxeMark labels a file that -S or -G matched.
+func pickaxeMark(change git.FileChange) string {
+	if !change.PickaxeMatch {
+		return ""
+	}
+	return matched("match")
 }
 
 func getStatusColor(status string) func(...interface{}) string {
 	switch status {
 	case "Added":
-		return green
+		return statusColors.added
 	case "Modified":
-		return yellow
+		return statusColors.modified
 	case "Deleted":
-		return red
+		return statusColors.deleted
 	case "Renamed", "Copied":
-		return cyan
+		return statusColors.renamed
 	default:
-		return magenta
+		return statusColors.other
 	}
 }
 
@@ -309,41 +732,55 @@ func getStatusSymbol(status string) string {
 	}
 }
 
-func printCommitHeader(commit git.Commit, compact bool) {
-	timeAgo := formatTimeAgo(commit.AuthorDate)
-	
+func (r *Renderer) printCommitHeader(commit git.Commit, compact bool) {
+	timeAgo := r.when(commit)
+
 	if compact {
-		fmt.Printf("%s %s - %s (%s)\n",
+		message := r.subject(commit.Message, displayWidth(fmt.Sprintf("%s  - %s (%s)", commit.ShortHash, commit.AuthorName, timeAgo)))
+		fmt.Fprintf(r.w, "%s %s - %s (%s)\n",
 			green(commit.ShortHash),
-			white(commit.Message),
+			markMatches(message, white),
 			yellow(commit.AuthorName),
 			dim(timeAgo),
 		)
 	} else {
-		fmt.Printf("%s %s\n", bold("commit"), highlight(commit.ShortHash))
-		fmt.Printf("%s: %s <%s>\n", bold("Author"), yellow(commit.AuthorName), commit.AuthorEmail)
-		fmt.Printf("%s: %s\n\n", bold("Date"), formatDate(commit.AuthorDate))
-		fmt.Printf("    %s\n\n", white(commit.Message))
+		fmt.Fprintf(r.w, "%s %s\n", bold("commit"), highlight(commit.ShortHash))
+		fmt.Fprintf(r.w, "%s: %s <%s>\n", bold("Author"), yellow(commit.AuthorName), commit.AuthorEmail)
+		fmt.Fprintf(r.w, "%s: %s\n", bold("Date"), r.date(commit.AuthorDate))
+		if commit.Recommitted() {
+			fmt.Fprintf(r.w, "%s: %s <%s>, %s\n", bold("Committer"), yellow(commit.Committer), commit.CommitterEmail, r.date(commit.CommitDate))
+		}
+		fmt.Fprintln(r.w)
+		r.printWrapped("    ", "    ", commit.Message, white)
+		fmt.Fprintln(r.w)
 	}
 }
 
-func printCommitBody(body string) {
-	lines := strings.Split(strings.TrimSpace(body), "\n")
-	for _, line := range lines {
+// printCommitBody prints the description, then the trailers as parsed, so
+// co-authors show under their canonical identities.
+func (r *Renderer) printCommitBody(commit git.Commit) {
+	for _, line := range strings.Split(commit.BodyText(), "\n") {
 		if line != "" {
-			fmt.Printf("    %s\n", cyan(line))
+			r.printWrapped("    ", "    ", line, cyan)
 		}
 	}
-	fmt.Println()
+	for _, trailer := range commit.Trailers {
+		style := cyan
+		if strings.EqualFold(trailer.Key, git.CoAuthorKey) {
+			style = yellow
+		}
+		r.printWrapped("    "+cyan(trailer.Key+": "), "    ", trailer.Value, style)
+	}
+	fmt.Fprintln(r.w)
 }
 
-func printCommitStats(stats git.CommitStats) {
+func (r *Renderer) printCommitStats(stats git.CommitStats) {
 	changeColor := green
 	if stats.Deletions > stats.Insertions {
 		changeColor = red
 	}
-	
-	fmt.Printf("    %s: %d %s(+%d/-%d)\n",
+
+	fmt.Fprintf(r.w, "    %s: %d %s(+%d/-%d)\n",
 		bold("Changes"),
 		stats.FilesChanged,
 		changeColor("█"),
@@ -352,56 +789,32 @@ func printCommitStats(stats git.CommitStats) {
 	)
 }
 
-func printRefNames(refs []string) {
-	fmt.Printf("    %s: ", bold("Refs"))
+func (r *Renderer) printRefNames(refs []string) {
+	fmt.Fprintf(r.w, "    %s: ", bold("Refs"))
 	for i, ref := range refs {
 		if strings.HasPrefix(ref, "tag: ") {
-			fmt.Print(blue(strings.TrimPrefix(ref, "tag: ")))
+			fmt.Fprint(r.w, blue(strings.TrimPrefix(ref, "tag: ")))
 		} else if ref == "HEAD" {
-			fmt.Print(red("HEAD"))
+			fmt.Fprint(r.w, red("HEAD"))
 		} else if strings.HasPrefix(ref, "origin/") {
-			fmt.Print(magenta(ref))
+			fmt.Fprint(r.w, magenta(ref))
 		} else {
-			fmt.Print(green(ref))
+			fmt.Fprint(r.w, green(ref))
 		}
 		if i < len(refs)-1 {
-			fmt.Print(", ")
+			fmt.Fprint(r.w, ", ")
 		}
 	}
-	fmt.Println()
-}
-
-func printGraphLine(index, total int) {
-	position := float64(index) / float64(total-1)
-	width := 50
-	
-	bar := make([]rune, width)
-	for i := 0; i < width; i++ {
-		if float64(i)/float64(width) < position {
-			bar[i] = '█'
-		} else {
-			bar[i] = '░'
-		}
-	}
-	
-	symbol := "●"
-	if index == 0 {
-		symbol = "⭓"
-	} else if index == total-1 {
-		symbol = "⭔"
-	}
-	
-	fmt.Printf("%s %s\n", symbol, string(bar))
+	fmt.Fprintln(r.w)
 }
 
 func formatDate(t time.Time) string {
-	return t.Format("Mon, 02 Jan 2006 15:04:05 MST")
+	return t.Format("Mon, 02 Jan 2006 15:04:05 -0700")
 }
 
-func formatTimeAgo(t time.Time) string {
-	now := time.Now()
+func formatTimeAgo(t, now time.Time) string {
 	diff := now.Sub(t)
-	
+
 	switch {
 	case diff < time.Minute:
 		return "just now"
@@ -438,4 +851,4 @@ func getBranchNames(refs []string) []string {
 		}
 	}
 	return branches
-}
\ No newline at end of file
+}
@@ -0,0 +1,124 @@
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"human-git-history/internal/git"
+)
+
+// fixtureNow is the clock relative dates are measured against in tests, a
+// few days after the newest fixture commit.
+var fixtureNow = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func loadFixture(t *testing.T) *git.MemoryRepository {
+	t.Helper()
+	SetColor(false)
+	repo, err := git.LoadFixture("../git/testdata/history.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestStreamFixture(t *testing.T) {
+	repo := loadFixture(t)
+	commits, err := repo.Log(git.CommitOptions{ShowFileChanges: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"", "detailed", "compact", "oneline", "changelog"} {
+		t.Run(format, func(t *testing.T) {
+			it, err := repo.Stream(context.Background(), git.CommitOptions{ShowFileChanges: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			r := NewRenderer(&buf, 100)
+			r.Now = func() time.Time { return fixtureNow }
+			if err := r.Stream(it, Options{Format: format, ShowStats: true, ShowFiles: true}); err != nil {
+				t.Fatal(err)
+			}
+
+			output := buf.String()
+			for _, commit := range commits {
+				// Changelogs list changes by description, without hashes.
+				want := commit.ShortHash
+				if format == "changelog" {
+					want = commit.Message
+					if _, description, ok := strings.Cut(want, ": "); ok {
+						want = description
+					}
+				}
+				if !strings.Contains(output, want) {
+					t.Errorf("output has no %q:\n%s", want, output)
+				}
+			}
+			for _, line := range strings.Split(output, "\n") {
+				if width := displayWidth(line); width > 100 {
+					t.Errorf("line is %d columns wide, more than 100: %q", width, line)
+				}
+			}
+		})
+	}
+}
+
+// TestCompactRefs checks that compact lines show branch names only, with no
+// brackets at all for commits that carry just tags or a detached HEAD.
+func TestCompactRefs(t *testing.T) {
+	SetColor(false)
+	for _, test := range []struct {
+		refs []string
+		want string
+	}{
+		{[]string{"HEAD -> main", "tag: v1.0.0"}, " [HEAD -> main]\n"},
+		{[]string{"tag: v1.0.0"}, ")\n"},
+		{[]string{"HEAD"}, ")\n"},
+		{nil, ")\n"},
+	} {
+		var buf bytes.Buffer
+		r := NewRenderer(&buf, 0)
+		r.Now = func() time.Time { return fixtureNow }
+		r.printCompactCommit(git.Commit{
+			ShortHash:  "0123456",
+			Message:    "Subject",
+			AuthorName: "Ann",
+			AuthorDate: fixtureNow.Add(-time.Hour),
+			RefNames:   test.refs,
+		}, false)
+		if line := buf.String(); !strings.HasSuffix(line, test.want) {
+			t.Errorf("refs %q: compact line %q, want it to end in %q", test.refs, line, test.want)
+		}
+	}
+}
+
+func TestChangelogFixture(t *testing.T) {
+	repo := loadFixture(t)
+	it, err := repo.Stream(context.Background(), git.CommitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	r := NewRenderer(&buf, 100)
+	r.Now = func() time.Time { return fixtureNow }
+	if err := r.Stream(it, Options{Format: "changelog", ByTag: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	output := buf.String()
+	// Bodies are shown without their trailers.
+	for _, trailer := range []string{"Co-authored-by", "Signed-off-by"} {
+		if strings.Contains(output, trailer) {
+			t.Errorf("changelog shows the %s trailer:\n%s", trailer, output)
+		}
+	}
+	// The merge has its own section, after the changes it brought in.
+	other, merges := strings.Index(output, "### Other"), strings.Index(output, "### Merges")
+	if merges < 0 || other > merges || !strings.Contains(output[merges:], "Merge branch") {
+		t.Errorf("merge is not under Merges:\n%s", output)
+	}
+}
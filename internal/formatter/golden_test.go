@@ -0,0 +1,80 @@
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"human-git-history/internal/git"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares output with testdata/name, or rewrites the file
+// with -update.
+func checkGolden(t *testing.T, name string, output []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, output, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(output, want) {
+		t.Errorf("output differs from %s (run go test -update after checking it):\n%s", path, output)
+	}
+}
+
+// TestGolden renders the fixture 80 columns wide, relative dates measured
+// from fixtureNow.
+func TestGolden(t *testing.T) {
+	repo := loadFixture(t)
+	options := git.CommitOptions{ShowFileChanges: true}
+
+	for name, layout := range map[string]Options{
+		"compact.golden":  {Format: "compact"},
+		"detailed.golden": {Format: "detailed", ShowStats: true, ShowFiles: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			it, err := repo.Stream(context.Background(), options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			r := NewRenderer(&buf, 80)
+			r.Now = func() time.Time { return fixtureNow }
+			if err := r.Stream(it, layout); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, name, buf.Bytes())
+		})
+	}
+
+	commits, err := repo.Log(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, layout := range map[string]Options{
+		"graph.golden":         {GraphStyle: "unicode", ShowStats: true},
+		"graph-compact.golden": {Format: "compact", GraphStyle: "ascii"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			r := NewRenderer(&buf, 80)
+			r.Now = func() time.Time { return fixtureNow }
+			if err := r.PrintGraph(commits, layout); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, name, buf.Bytes())
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"human-git-history/internal/git"
+
+	"github.com/fatih/color"
+)
+
+// laneColors cycle over the lines of history, so each branch keeps one
+// colour from where it forks to where it is merged.
+var laneColors = []func(...interface{}) string{
+	newStyle(color.FgRed),
+	newStyle(color.FgGreen),
+	newStyle(color.FgYellow),
+	newStyle(color.FgBlue),
+	newStyle(color.FgMagenta),
+	newStyle(color.FgCyan),
+}
+
+// A cell of the graph is drawn from the directions lines leave it in.
+const (
+	lineUp = 1 << iota
+	lineDown
+	lineLeft
+	lineRight
+)
+
+var unicodeGlyphs = map[int]string{
+	lineUp:                                   "│",
+	lineDown:                                 "│",
+	lineUp | lineDown:                        "│",
+	lineLeft:                                 "─",
+	lineRight:                                "─",
+	lineLeft | lineRight:                     "─",
+	lineUp | lineRight:                       "╰",
+	lineUp | lineLeft:                        "╯",
+	lineDown | lineRight:                     "╭",
+	lineDown | lineLeft:                      "╮",
+	lineUp | lineDown | lineRight:            "├",
+	lineUp | lineDown | lineLeft:             "┤",
+	lineDown | lineLeft | lineRight:          "┬",
+	lineUp | lineLeft | lineRight:            "┴",
+	lineUp | lineDown | lineLeft | lineRight: "┼",
+}
+
+// graphGlyphs are the characters of one --graph-style.
+type graphGlyphs struct {
+	commit, merge, root string
+	line                func(dirs int) string
+}
+
+var graphStyles = map[string]graphGlyphs{
+	"unicode": {
+		commit: "●", merge: "◉", root: "○",
+		line: func(dirs int) string { return unicodeGlyphs[dirs] },
+	},
+	"ascii": {
+		commit: "*", merge: "*", root: "*",
+		line: func(dirs int) string {
+			switch dirs {
+			case lineUp, lineDown, lineUp | lineDown, lineUp | lineDown | lineLeft, lineUp | lineDown | lineRight:
+				return "|"
+			case lineLeft, lineRight, lineLeft | lineRight:
+				return "-"
+			case lineUp | lineLeft, lineDown | lineRight:
+				return "/"
+			case lineUp | lineRight, lineDown | lineLeft:
+				return "\\"
+			default:
+				return "+"
+			}
+		},
+	},
+}
+
+// lane is one column of the graph: the commit its line is heading for, or
+// an empty hash when the column is free.
+type lane struct {
+	hash  string
+	color int
+}
+
+// closedLane marks a lane ending on the current row.
+const closedLane = "-"
+
+// edge is a line from a column of one row to a column of the next.
+type edge struct {
+	from, to int
+	color    int
+}
+
+// graph allocates lanes the way git log --graph does: a commit takes the
+// lane waiting for it, its first parent continues that lane and further
+// parents fork new ones. Lanes waiting for the same commit join.
+type graph struct {
+	lanes   []lane
+	next    int // Colour of the next lane opened
+	visible map[string]bool
+	glyphs  graphGlyphs
+}
+
+func newGraph(commits []git.Commit, style string) *graph {
+	glyphs, ok := graphStyles[style]
+	if !ok {
+		glyphs = graphStyles["unicode"]
+	}
+	visible := make(map[string]bool, len(commits))
+	for _, commit := range commits {
+		visible[commit.Hash] = true
+	}
+	return &graph{visible: visible, glyphs: glyphs}
+}
+
+// PrintGraph prints commits in the layout options select, with the commit
+// graph drawn to the left of every line. Parents that are not listed, such
+// as those filtered out or past --limit, end their lines.
+func (r *Renderer) PrintGraph(commits []git.Commit, options Options) error {
+	g := newGraph(commits, options.GraphStyle)
+	// The commits are printed into buffers, in the width left of the lanes.
+	inner := *r
+	between, printCommit := inner.layout(options)
+
+	var before, body bytes.Buffer
+	for i, commit := range commits {
+		before.Reset()
+		body.Reset()
+		if r.width > 0 {
+			inner.width = max(r.width-2*(len(g.lanes)+1), 20)
+		}
+		inner.w = &before
+		between(i, commit)
+		inner.w = &body
+		if err := printCommit(commit); err != nil {
+			return err
+		}
+
+		for _, line := range splitLines(before.String()) {
+			r.writeGraphLine(g.row(-1, ""), line)
+		}
+		node, below, transition := g.place(commit)
+		lines := splitLines(body.String())
+		if len(lines) == 0 {
+			lines = []string{""}
+		}
+		r.writeGraphLine(node, lines[0])
+		for _, line := range lines[1:] {
+			r.writeGraphLine(below, line)
+		}
+		if transition != "" {
+			r.writeGraphLine(transition, "")
+		}
+	}
+	return nil
+}
+
+func (r *Renderer) writeGraphLine(prefix, line string) {
+	if line == "" {
+		prefix = strings.TrimRight(prefix, " ")
+	}
+	fmt.Fprintln(r.w, prefix+line)
+}
+
+// splitLines splits printed output into lines without the final newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// place puts commit in its lane and moves the lanes on to its parents. It
+// returns the graph for the commit's first line and the lines after it,
+// and the row joining and forking lanes below the commit, if one is needed.
+func (g *graph) place(commit git.Commit) (node, below, transition string) {
+	col := g.find(commit.Hash)
+	if col < 0 {
+		col = g.open(commit.Hash, -1)
+	}
+
+	var parents []string
+	for _, parent := range commit.ParentHashes {
+		if g.visible[parent] {
+			parents = append(parents, parent)
+		}
+	}
+	symbol := g.glyphs.commit
+	if len(commit.ParentHashes) > 1 {
+		symbol = g.glyphs.merge
+	} else if len(commit.ParentHashes) == 0 {
+		symbol = g.glyphs.root
+	}
+	node = g.row(col, laneColors[g.lanes[col].color%len(laneColors)](symbol))
+	if len(parents) == 0 {
+		below = g.row(col, " ")
+	} else {
+		below = g.row(-1, "")
+	}
+
+	// Work out where every line goes, then update the lanes.
+	var edges []edge
+	for i, l := range g.lanes {
+		if i != col && l.hash != "" {
+			edges = append(edges, edge{from: i, to: i, color: l.color})
+		}
+	}
+	// Lanes freed by this commit stay taken until the next row, so no
+	// new line starts where an old one ends.
+	own := g.lanes[col]
+	g.lanes[col] = lane{hash: closedLane}
+	for n, parent := range parents {
+		if j := g.find(parent); j >= 0 {
+			if n == 0 && j > col {
+				// The other lane joins this one, which goes on.
+				for k := range edges {
+					if edges[k].from == j {
+						edges[k].to = col
+					}
+				}
+				g.lanes[j] = lane{hash: closedLane}
+				g.lanes[col] = lane{hash: parent, color: own.color}
+				edges = append(edges, edge{from: col, to: col, color: own.color})
+			} else {
+				edges = append(edges, edge{from: col, to: j, color: g.lanes[j].color})
+			}
+			continue
+		}
+		if n == 0 {
+			g.lanes[col] = lane{hash: parent, color: own.color}
+			edges = append(edges, edge{from: col, to: col, color: own.color})
+			continue
+		}
+		j := g.open(parent, col)
+		edges = append(edges, edge{from: col, to: j, color: g.lanes[j].color})
+	}
+	for i := range g.lanes {
+		if g.lanes[i].hash == closedLane {
+			g.lanes[i] = lane{}
+		}
+	}
+	g.trim()
+
+	for _, e := range edges {
+		if e.from != e.to {
+			return node, below, g.draw(edges)
+		}
+	}
+	return node, below, ""
+}
+
+// find returns the lane waiting for hash, or -1.
+func (g *graph) find(hash string) int {
+	for i, l := range g.lanes {
+		if l.hash == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+// open gives hash the first free lane right of after, with a new colour.
+func (g *graph) open(hash string, after int) int {
+	l := lane{hash: hash, color: g.next}
+	g.next++
+	for i := after + 1; i < len(g.lanes); i++ {
+		if g.lanes[i].hash == "" {
+			g.lanes[i] = l
+			return i
+		}
+	}
+	g.lanes = append(g.lanes, l)
+	return len(g.lanes) - 1
+}
+
+// trim drops free lanes at the right edge.
+func (g *graph) trim() {
+	for len(g.lanes) > 0 && g.lanes[len(g.lanes)-1].hash == "" {
+		g.lanes = g.lanes[:len(g.lanes)-1]
+	}
+}
+
+// row draws every lane as a straight line, with symbol in column col.
+func (g *graph) row(col int, symbol string) string {
+	var b strings.Builder
+	for i, l := range g.lanes {
+		switch {
+		case i == col:
+			b.WriteString(symbol)
+		case l.hash != "":
+			b.WriteString(laneColors[l.color%len(laneColors)](g.glyphs.line(lineUp | lineDown)))
+		default:
+			b.WriteString(" ")
+		}
+		b.WriteString(" ")
+	}
+	return b.String()
+}
+
+// draw renders the lines between two rows. Every column has a cell for
+// its lane and one for the gap to its right; lines leaving a lane for
+// another run horizontally through the cells in between.
+func (g *graph) draw(edges []edge) string {
+	width := 0
+	for _, e := range edges {
+		if e.from+1 > width {
+			width = e.from + 1
+		}
+		if e.to+1 > width {
+			width = e.to + 1
+		}
+	}
+	dirs := make([]int, 2*width)
+	colors := make([]int, 2*width)
+	set := make([]bool, 2*width)
+	mark := func(cell, d, c int, vertical bool) {
+		dirs[cell] |= d
+		// A straight lane keeps its colour where other lines cross it.
+		if !set[cell] || vertical {
+			colors[cell] = c
+			set[cell] = true
+		}
+	}
+
+	for _, e := range edges {
+		from, to := 2*e.from, 2*e.to
+		if from == to {
+			mark(from, lineUp|lineDown, e.color, true)
+			continue
+		}
+		step, toward, back := 1, lineRight, lineLeft
+		if to < from {
+			step, toward, back = -1, lineLeft, lineRight
+		}
+		mark(from, lineUp|toward, e.color, false)
+		for cell := from + step; cell != to; cell += step {
+			mark(cell, lineLeft|lineRight, e.color, false)
+		}
+		mark(to, lineDown|back, e.color, false)
+	}
+
+	var b strings.Builder
+	for cell, d := range dirs {
+		if d == 0 {
+			b.WriteString(" ")
+			continue
+		}
+		b.WriteString(laneColors[colors[cell]%len(laneColors)](g.glyphs.line(d)))
+	}
+	return b.String() + " "
+}
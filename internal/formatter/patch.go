@@ -0,0 +1,404 @@
+package formatter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"human-git-history/internal/git"
+	"human-git-history/internal/patch"
+
+	"github.com/fatih/color"
+)
+
+// The layouts PrintPatch knows.
+const (
+	PatchUnified    = "unified"
+	PatchSideBySide = "side-by-side"
+	PatchWords      = "word"
+)
+
+// PatchModes lists the layouts PatchOptions.Mode accepts.
+func PatchModes() []string {
+	return []string{PatchUnified, PatchSideBySide, PatchWords}
+}
+
+// PatchOptions lay out the diffs PrintPatch prints.
+type PatchOptions struct {
+	Mode string // unified, side-by-side or word
+	// HunkLines is the longest hunk shown whole; longer ones lose their
+	// middle lines. Zero shows every line.
+	HunkLines int
+}
+
+// tint is the background of a diff line, or of the words a change touched
+// within it.
+type tint int
+
+const (
+	noTint tint = iota
+	addedTint
+	deletedTint
+	addedWordTint
+	deletedWordTint
+)
+
+// Changed lines are drawn on a dark background, and the words that
+// changed on a brighter one, so the syntax colours stay readable on both.
+var (
+	tintColors   = [...]int{noTint: 0, addedTint: 22, deletedTint: 52, addedWordTint: 28, deletedWordTint: 88}
+	syntaxColors = map[patch.Class]int{
+		patch.Keyword: 75,
+		patch.String:  180,
+		patch.Comment: 245,
+		patch.Number:  141,
+	}
+	// spanStyles colours code by its tint and syntax class.
+	spanStyles = make(map[tint]map[patch.Class]func(...interface{}) string)
+)
+
+func init() {
+	for t, background := range tintColors {
+		spanStyles[tint(t)] = make(map[patch.Class]func(...interface{}) string)
+		for _, class := range []patch.Class{patch.Plain, patch.Keyword, patch.String, patch.Comment, patch.Number} {
+			var attrs []color.Attribute
+			if background != 0 {
+				attrs = append(attrs, 48, 5, color.Attribute(background))
+			}
+			if foreground, ok := syntaxColors[class]; ok {
+				attrs = append(attrs, 38, 5, color.Attribute(foreground))
+			}
+			style := fmt.Sprint
+			if len(attrs) > 0 {
+				style = newStyle(attrs...)
+			}
+			spanStyles[tint(t)][class] = style
+		}
+	}
+}
+
+// tabWidth is how far apart the tab stops in diffs are.
+const tabWidth = 4
+
+// patchPrinter prints each commit's diff after the commit itself.
+func (r *Renderer) patchPrinter(printCommit func(commit git.Commit) error, options Options) func(commit git.Commit) error {
+	return func(commit git.Commit) error {
+		if err := printCommit(commit); err != nil {
+			return err
+		}
+		text, err := options.Diff(commit)
+		if err != nil {
+			return err
+		}
+		if text == "" {
+			return nil
+		}
+		r.PrintPatch(text, options.Patch)
+		fmt.Fprintln(r.w)
+		return nil
+	}
+}
+
+// PrintPatch prints a patch, as git.Repository.Patch returns it, file by
+// file in the layout options select, with the code highlighted for the
+// language of each file.
+func (r *Renderer) PrintPatch(text string, options PatchOptions) {
+	for _, file := range patch.Parse(text) {
+		r.printFileHeader(file)
+		for _, hunk := range file.Hunks {
+			hunk = hunk.Collapse(options.HunkLines)
+			header := cyan(hunk.Header())
+			if hunk.Section != "" {
+				header += " " + dim(hunk.Section)
+			}
+			fmt.Fprintln(r.w, header)
+			switch options.Mode {
+			case PatchSideBySide:
+				r.printSideBySide(file, hunk)
+			case PatchWords:
+				r.printWordDiff(file, hunk)
+			default:
+				r.printUnified(file, hunk)
+			}
+		}
+	}
+}
+
+// printFileHeader names the file a diff is for, with what happened to it
+// besides its lines changing.
+func (r *Renderer) printFileHeader(file patch.File) {
+	statusColor := getStatusColor(file.Status)
+	var notes []string
+	switch {
+	case file.Status == "Renamed" || file.Status == "Copied":
+		notes = append(notes, sourceNote(git.FileChange{Status: file.Status, OldPath: file.OldPath, Similarity: file.Similarity}))
+	case file.Status == "Added" && file.NewMode != "100644":
+		notes = append(notes, "mode "+file.NewMode)
+	case file.OldMode != "" && file.NewMode != "" && file.OldMode != file.NewMode:
+		notes = append(notes, "mode "+file.OldMode+" → "+file.NewMode)
+	}
+	if file.Binary {
+		notes = append(notes, "binary")
+	}
+	for i, note := range notes {
+		notes[i] = dim("(" + note + ")")
+	}
+	r.printFileLine("", statusColor(getStatusSymbol(file.Status))+" ", bold(file.Path()), notes...)
+}
+
+// printUnified prints a hunk the way git diff does, with the words that
+// changed within a replaced line picked out.
+func (r *Renderer) printUnified(file patch.File, hunk patch.Hunk) {
+	for _, line := range hunk.Unified() {
+		var lines []string
+		switch line.Kind {
+		case patch.Deleted:
+			lines = diffLine("-", line.Spans(file.Path()), patch.Deleted, r.width, line.NoNewline)
+		case patch.Added:
+			lines = diffLine("+", line.Spans(file.Path()), patch.Added, r.width, line.NoNewline)
+		default:
+			r.printSharedLine(file, *line.Line, " ")
+		}
+		for _, text := range lines {
+			fmt.Fprintln(r.w, text)
+		}
+	}
+}
+
+// printWordDiff prints a hunk the way git diff --word-diff does: a
+// replaced line once, with the deleted words beside the added ones.
+func (r *Renderer) printWordDiff(file patch.File, hunk patch.Hunk) {
+	for _, row := range hunk.Rows() {
+		if row.Old != nil && row.Old == row.New {
+			r.printSharedLine(file, *row.Old, "")
+			continue
+		}
+		_, _, words := row.Spans(file.Path())
+		last := row.New
+		if last == nil {
+			last = row.Old
+		}
+		for _, line := range diffLine("", words, patch.Context, 0, last.NoNewline) {
+			fmt.Fprintln(r.w, line)
+		}
+	}
+}
+
+// printSharedLine prints an unchanged or folded line after lead.
+func (r *Renderer) printSharedLine(file patch.File, line patch.Line, lead string) {
+	if line.Kind == patch.Folded {
+		fmt.Fprintln(r.w, dim(fmt.Sprintf("⋯ %d more line%s ⋯", line.Hidden, pluralize(line.Hidden))))
+		return
+	}
+	for _, text := range diffLine(lead, patch.Highlight(file.Path(), line.Text), patch.Context, 0, line.NoNewline) {
+		fmt.Fprintln(r.w, text)
+	}
+}
+
+// diffLine renders a line of a diff after its marker, tinted for kind and
+// filled out to width when there is one. A line without a final newline
+// gets git's note below it.
+func diffLine(marker string, spans []patch.Span, kind patch.Kind, width int, noNewline bool) []string {
+	text, used := renderSpans(spans, kind)
+	line := markerStyle(kind)(marker) + text
+	if fill := width - displayWidth(marker) - used; fill > 0 && kind != patch.Context {
+		line += spanStyles[lineTint(kind)][patch.Plain](strings.Repeat(" ", fill))
+	}
+	lines := []string{line}
+	if noNewline {
+		lines = append(lines, dim(`\ No newline at end of file`))
+	}
+	return lines
+}
+
+// printSideBySide prints a hunk in two columns, the old lines on the left
+// and the new ones on the right, each numbered. Lines too long for their
+// column carry on below.
+func (r *Renderer) printSideBySide(file patch.File, hunk patch.Hunk) {
+	total := r.width
+	if total <= 0 {
+		total = 160
+	}
+	digits := len(strconv.Itoa(max(hunk.OldStart+hunk.OldLines, hunk.NewStart+hunk.NewLines)))
+	// Each side is the line number, a space, the marker and the code.
+	column := max((total-1)/2, digits+4)
+	code := column - digits - 2
+
+	for _, row := range hunk.Rows() {
+		if row.Old != nil && row.Old.Kind == patch.Folded {
+			r.printSharedLine(file, *row.Old, "")
+			continue
+		}
+		old, new, _ := row.Spans(file.Path())
+		left := sideLines(row.Old, true, old, digits, code)
+		right := sideLines(row.New, false, new, digits, code)
+		for i := 0; i < len(left) || i < len(right); i++ {
+			oldText, newText := strings.Repeat(" ", column), ""
+			if i < len(left) {
+				oldText = left[i]
+			}
+			if i < len(right) {
+				newText = right[i]
+			}
+			fmt.Fprintln(r.w, strings.TrimRight(oldText+dim("│")+newText, " "))
+		}
+	}
+}
+
+// sideLines renders the old or new side of a side-by-side row, numbered
+// on that side, in lines of exactly digits+2+code columns, or none when
+// the row has nothing on that side.
+func sideLines(line *patch.Line, oldSide bool, spans []patch.Span, digits, code int) []string {
+	if line == nil {
+		return nil
+	}
+	number := line.New
+	if oldSide {
+		number = line.Old
+	}
+	kind, marker := line.Kind, " "
+	switch kind {
+	case patch.Deleted:
+		marker = "-"
+	case patch.Added:
+		marker = "+"
+	}
+	marker = markerStyle(kind)(marker)
+
+	var lines []string
+	for i, text := range wrapSpans(spans, code) {
+		gutter := dim(fmt.Sprintf("%*d ", digits, number)) + marker
+		if i > 0 {
+			gutter = strings.Repeat(" ", digits+2)
+		}
+		rendered, used := renderSpans(text, kind)
+		fill := strings.Repeat(" ", code-used)
+		if kind != patch.Context {
+			fill = spanStyles[lineTint(kind)][patch.Plain](fill)
+		}
+		lines = append(lines, gutter+rendered+fill)
+	}
+	return lines
+}
+
+// wrapSpans breaks spans into lines of at most width columns, tabs
+// expanded. A line with no text is one empty line.
+func wrapSpans(spans []patch.Span, width int) [][]patch.Span {
+	lines := [][]patch.Span{nil}
+	col := 0
+	for _, span := range spans {
+		var text strings.Builder
+		emit := func() {
+			if text.Len() > 0 {
+				span.Text = text.String()
+				lines[len(lines)-1] = append(lines[len(lines)-1], span)
+				text.Reset()
+			}
+		}
+		for _, r := range span.Text {
+			cell, w := string(r), runeWidth(r)
+			if r == '\t' {
+				w = tabWidth - col%tabWidth
+				cell = strings.Repeat(" ", w)
+			}
+			if col+w > width && col > 0 {
+				emit()
+				lines = append(lines, nil)
+				col = 0
+				if r == '\t' {
+					continue
+				}
+			}
+			text.WriteString(cell)
+			col += w
+		}
+		emit()
+	}
+	return lines
+}
+
+// markerStyle colours the + and - in front of changed lines like the
+// added and deleted files of the theme.
+func markerStyle(kind patch.Kind) func(...interface{}) string {
+	switch kind {
+	case patch.Added:
+		return statusColors.added
+	case patch.Deleted:
+		return statusColors.deleted
+	default:
+		return fmt.Sprint
+	}
+}
+
+// lineTint is the background of a whole line of kind.
+func lineTint(kind patch.Kind) tint {
+	switch kind {
+	case patch.Added:
+		return addedTint
+	case patch.Deleted:
+		return deletedTint
+	default:
+		return noTint
+	}
+}
+
+// renderSpans colours spans on a line of kind, with tabs expanded, and
+// returns the columns they take. Without colours, the
+// words a word diff deleted and added are marked [-like this-] and
+// {+like this+}, as git does.
+func renderSpans(spans []patch.Span, kind patch.Kind) (string, int) {
+	var out strings.Builder
+	col := 0
+	open := patch.Context
+	closeMark := func() {
+		switch open {
+		case patch.Deleted:
+			out.WriteString("-]")
+		case patch.Added:
+			out.WriteString("+}")
+		}
+		open = patch.Context
+	}
+	for _, span := range spans {
+		if color.NoColor && kind == patch.Context && span.Kind != open {
+			closeMark()
+			switch span.Kind {
+			case patch.Deleted:
+				out.WriteString("[-")
+			case patch.Added:
+				out.WriteString("{+")
+			}
+			open = span.Kind
+		}
+		var text strings.Builder
+		for _, r := range span.Text {
+			if r == '\t' {
+				n := tabWidth - col%tabWidth
+				text.WriteString(strings.Repeat(" ", n))
+				col += n
+				continue
+			}
+			text.WriteRune(r)
+			col += runeWidth(r)
+		}
+		out.WriteString(spanStyles[spanTint(kind, span.Kind)][span.Class](text.String()))
+	}
+	if color.NoColor {
+		closeMark()
+	}
+	return out.String(), col
+}
+
+// spanTint is the background of a span of kind on a line of lineKind:
+// the line's own, brighter for the words that changed. On the single
+// line of a word diff only the changed words are tinted.
+func spanTint(lineKind, kind patch.Kind) tint {
+	switch {
+	case kind == patch.Added && lineKind != patch.Deleted:
+		return addedWordTint
+	case kind == patch.Deleted && lineKind != patch.Added:
+		return deletedWordTint
+	default:
+		return lineTint(lineKind)
+	}
+}
@@ -0,0 +1,86 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"human-git-history/internal/git"
+	pages "human-git-history/internal/template"
+)
+
+// TemplatePrefix starts a --format value that is a Go template, as in
+// --format "template:{{.ShortHash}} {{.Message}}".
+const TemplatePrefix = "template:"
+
+// templateFuncs are the web page helpers plus the terminal colours, so
+// house styles can colour their output the way the built-in formats do.
+func templateFuncs() template.FuncMap {
+	funcs := template.FuncMap(pages.Funcs())
+	for name, style := range map[string]func(...interface{}) string{
+		"yellow":  yellow,
+		"green":   green,
+		"cyan":    cyan,
+		"red":     red,
+		"blue":    blue,
+		"magenta": magenta,
+		"white":   white,
+		"bold":    bold,
+		"dim":     dim,
+	} {
+		funcs[name] = style
+	}
+	funcs["statusColor"] = func(status string, text ...interface{}) string {
+		return getStatusColor(status)(text...)
+	}
+	funcs["highlight"] = func(text string) string {
+		return markMatches(text, fmt.Sprint)
+	}
+	return funcs
+}
+
+// ParseTemplate returns the template given with --format "template:..."
+// or --template-file, or nil when format names a built-in layout.
+func ParseTemplate(format, file string) (*template.Template, error) {
+	if file != "" {
+		if format != "" {
+			return nil, fmt.Errorf("--template-file cannot be combined with --format %s", format)
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template %s: %v", file, err)
+		}
+		return parseTemplate(file, string(content))
+	}
+	if strings.HasPrefix(format, TemplatePrefix) {
+		return parseTemplate("format", strings.TrimPrefix(format, TemplatePrefix))
+	}
+	return nil, nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// templatePrinter executes tmpl with each git.Commit as data. Every commit
+// ends with a newline unless the template wrote one.
+func (r *Renderer) templatePrinter(tmpl *template.Template) func(commit git.Commit) error {
+	var buf bytes.Buffer
+	return func(commit git.Commit) error {
+		buf.Reset()
+		if err := tmpl.Execute(&buf, commit); err != nil {
+			return fmt.Errorf("failed to render template: %v", err)
+		}
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		_, err := r.w.Write(buf.Bytes())
+		return err
+	}
+}
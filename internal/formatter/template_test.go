@@ -0,0 +1,141 @@
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"human-git-history/internal/git"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	repo := loadFixture(t)
+	commits, err := repo.Log(git.CommitOptions{ShowFileChanges: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byHash := make(map[string]git.Commit)
+	for _, commit := range commits {
+		byHash[commit.ShortHash] = commit
+	}
+
+	for _, test := range []struct {
+		commit string
+		text   string
+		want   string
+	}{
+		{"f6a1c3e", `{{shortHash .Hash}} {{.ShortHash}}`, "f6a1c3e f6a1c3e"},
+		{"f6a1c3e", `{{formatDate .AuthorDate}} | {{formatDateTime .AuthorDate}}`, "2024-03-08 | 2024-03-08 16:45:00 +0100"},
+		{"f6a1c3e", `{{truncate .Message 9}}|{{truncate .Message 99}}`, "feat(web)...|feat(web): render diffs | side by side"},
+		{"9b2d4f6", `{{truncate .Message 20}}`, "docs: explain the 修复..."},
+		{"f6a1c3e", `{{len .FileChanges}} file{{pluralize (len .FileChanges)}}, {{.Stats.FilesChanged}} change{{pluralize 1}}`, "3 files, 3 change"},
+		{"f6a1c3e", `{{add 1 2}} {{subtract 5 2}} {{multiply 2 3}} {{divide 7 2}} {{divide 1 0}} {{printf "%.0f" (percentage 1 4)}} {{percentage 1 0}}`, "3 3 6 3 0 25 0"},
+		{"f6a1c3e", `{{range .FileChanges}}{{fileStatusColor .Status}} {{fileStatusText .Status}}; {{end}}`, "warning Modified; success Added; info Renamed; "},
+		{"f6a1c3e", `{{commitStatus .}}`, "normal"},
+		{"c4e8a2f", `{{commitStatus .}} {{default "no body" .Body}}`, "merge no body"},
+		{"f6a1c3e", `{{join (split "a,b" ",") "+"}} {{toUpper "x"}}{{upper "y"}} {{toLower "X"}}{{lower "Y"}} {{replace "a-b" "-" "_"}}`, "a+b XY xy a_b"},
+		{"f6a1c3e", `{{contains .Message "diffs"}} {{hasPrefix .Message "feat"}} {{hasSuffix .Message "feat"}}`, "true true false"},
+		{"f6a1c3e", `{{with dict "hash" .ShortHash "n" 2}}{{.hash}}/{{.n}}{{end}} {{json .Stats}}`, `f6a1c3e/2 {"FilesChanged":3,"Insertions":57,"Deletions":9}`},
+		{"f6a1c3e", `{{range .CoAuthors}}{{.}}{{end}} {{range .Trailers}}{{.Key}} {{end}}`, "Sam Lee <sam@example.com> Co-authored-by Signed-off-by "},
+		{"f6a1c3e", `{{yellow .ShortHash}} {{bold "b"}} {{statusColor "Added" "A"}} {{highlight .Message}}`, "f6a1c3e b A feat(web): render diffs | side by side"},
+	} {
+		tmpl, err := ParseTemplate(TemplatePrefix+test.text, "")
+		if err != nil {
+			t.Errorf("ParseTemplate(%q): %v", test.text, err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, byHash[test.commit]); err != nil {
+			t.Errorf("%q: %v", test.text, err)
+			continue
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%q on %s = %q, want %q", test.text, test.commit, got, test.want)
+		}
+	}
+}
+
+func TestTemplateColors(t *testing.T) {
+	loadFixture(t)
+	SetColor(true)
+	defer SetColor(false)
+	SetHighlight(regexp.MustCompile(`diffs`))
+	defer SetHighlight(nil)
+
+	tmpl, err := ParseTemplate(TemplatePrefix+`{{red "r"}} {{statusColor "Deleted" "D"}} {{highlight "render diffs"}}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, git.Commit{}); err != nil {
+		t.Fatal(err)
+	}
+	want := red("r") + " " + getStatusColor("Deleted")("D") + " " + markMatches("render diffs", fmt.Sprint)
+	if got := buf.String(); got != want || !strings.Contains(got, matched("diffs")) {
+		t.Errorf("colored template = %q, want %q", got, want)
+	}
+}
+
+func TestTemplateStream(t *testing.T) {
+	repo := loadFixture(t)
+	file := filepath.Join(t.TempDir(), "house.tpl")
+	if err := os.WriteFile(file, []byte("{{.ShortHash}} {{commitStatus .}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		format, file string
+		want         string
+	}{
+		// A newline ends each commit unless the template wrote one.
+		{TemplatePrefix + "{{.ShortHash}}", "", "f6a1c3e\nc4e8a2f\n"},
+		{"", file, "f6a1c3e normal\nc4e8a2f merge\n"},
+	} {
+		tmpl, err := ParseTemplate(test.format, test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		it, err := repo.Stream(context.Background(), git.CommitOptions{Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := NewRenderer(&buf, 80).Stream(it, Options{Template: tmpl}); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("template %q%s printed %q, want %q", test.format, test.file, got, test.want)
+		}
+	}
+
+	if tmpl, err := ParseTemplate("compact", ""); tmpl != nil || err != nil {
+		t.Errorf("ParseTemplate of a built-in format = %v, %v", tmpl, err)
+	}
+	for _, bad := range [][2]string{
+		{TemplatePrefix + "{{.ShortHash", ""},
+		{TemplatePrefix + "{{nope .Hash}}", ""},
+		{"compact", file},
+		{"", filepath.Join(t.TempDir(), "missing.tpl")},
+	} {
+		if _, err := ParseTemplate(bad[0], bad[1]); err == nil {
+			t.Errorf("ParseTemplate(%q, %q) succeeded", bad[0], bad[1])
+		}
+	}
+
+	tmpl, err := ParseTemplate(TemplatePrefix+"{{.Nope}}", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := repo.Stream(context.Background(), git.CommitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewRenderer(&bytes.Buffer{}, 80).Stream(it, Options{Template: tmpl}); err == nil || !strings.Contains(err.Error(), "failed to render template") {
+		t.Errorf("a template naming no field: err = %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package formatter
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ansiEscape matches the colour sequences the formatters emit.
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// displayWidth is the number of columns s takes on a terminal, ignoring
+// colours: combining marks take none, wide East Asian characters and emoji
+// two.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range ansiEscape.ReplaceAllString(s, "") {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Me, r), r == '\u200b':
+		return 0
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// truncateEnd shortens plain text to max columns, ending it with "…".
+// A max of zero or less means no limit.
+func truncateEnd(s string, max int) string {
+	if max <= 0 || displayWidth(s) <= max {
+		return s
+	}
+	return fitPrefix(s, max-1) + "…"
+}
+
+// fitPrefix is the longest start of s that fits in max columns.
+func fitPrefix(s string, max int) string {
+	width := 0
+	for i, r := range s {
+		if width+runeWidth(r) > max {
+			return s[:i]
+		}
+		width += runeWidth(r)
+	}
+	return s
+}
+
+// truncateStart shortens plain text to max columns, starting it with "…".
+func truncateStart(s string, max int) string {
+	if max <= 0 || displayWidth(s) <= max {
+		return s
+	}
+	width := 0
+	for i := len(s); i > 0; {
+		r, size := utf8.DecodeLastRuneInString(s[:i])
+		if width+runeWidth(r) > max-1 {
+			return "…" + s[i:]
+		}
+		width += runeWidth(r)
+		i -= size
+	}
+	return s
+}
+
+// truncatePath shortens a file path to max columns by eliding directories
+// in the middle, so the file name stays readable: internal/…/native.go.
+func truncatePath(path string, max int) string {
+	if max <= 0 || displayWidth(path) <= max {
+		return path
+	}
+	slash := strings.LastIndex(path, "/")
+	name := path[slash+1:]
+	if slash < 0 || displayWidth(name)+2 >= max {
+		return truncateStart(path, max)
+	}
+	dir := truncateEnd(path[:slash], max-displayWidth(name)-1)
+	return dir + "/" + name
+}
+
+// wrapText breaks plain text into lines of at most width columns at
+// spaces, splitting words that are longer than a line. A width of zero or
+// less leaves the text on one line.
+func wrapText(text string, width int) []string {
+	if width <= 0 || displayWidth(text) <= width {
+		return []string{text}
+	}
+	var lines []string
+	line, lineWidth := "", 0
+	for _, word := range strings.Fields(text) {
+		wordWidth := displayWidth(word)
+		if lineWidth > 0 && lineWidth+1+wordWidth <= width {
+			line += " " + word
+			lineWidth += 1 + wordWidth
+			continue
+		}
+		if lineWidth > 0 {
+			lines = append(lines, line)
+		}
+		for wordWidth > width {
+			head := fitPrefix(word, width)
+			if head == "" {
+				_, size := utf8.DecodeRuneInString(word)
+				head = word[:size]
+			}
+			lines = append(lines, head)
+			word = word[len(head):]
+			wordWidth = displayWidth(word)
+		}
+		line, lineWidth = word, wordWidth
+	}
+	if lineWidth > 0 || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// DisplayWidth is displayWidth for code laying out formatter output beside
+// other text, as the terminal UI does in its panes.
+func DisplayWidth(s string) int {
+	return displayWidth(s)
+}
+
+// StripColor removes the colour sequences from s.
+func StripColor(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// Clip cuts s, which may be coloured, to at most width columns. Colours
+// cut short are reset so they do not run into what follows.
+func Clip(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+	var out strings.Builder
+	used := 0
+	for len(s) > 0 {
+		if loc := ansiEscape.FindStringIndex(s); loc != nil && loc[0] == 0 {
+			out.WriteString(s[:loc[1]])
+			s = s[loc[1]:]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		if used+runeWidth(r) > width {
+			break
+		}
+		out.WriteString(s[:size])
+		used += runeWidth(r)
+		s = s[size:]
+	}
+	if strings.Contains(out.String(), "\x1b[") {
+		out.WriteString("\x1b[0m")
+	}
+	return out.String()
+}
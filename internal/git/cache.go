@@ -0,0 +1,285 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CommitCache keeps parsed commits between runs. Commits are immutable, so
+// an entry stays valid forever as long as it was produced with the same
+// diff options; ref names are the exception and are never cached.
+type CommitCache interface {
+	Get(hash, diffKey string, withFiles bool) (Commit, bool)
+	Put(commit Commit, diffKey string, withFiles bool)
+}
+
+// SetCache enables cache on backends that support one and reports whether
+// repo accepted it.
+func SetCache(repo Repository, cache CommitCache) bool {
+	switch r := repo.(type) {
+	case *ExecRepository:
+		r.Cache = cache
+	case *NativeRepository:
+		r.Cache = cache
+	default:
+		return false
+	}
+	return true
+}
+
+// CommonDir returns the git directory shared by all worktrees of the
+// repository containing dir.
+func CommonDir(dir string) (string, error) {
+	gitDir, err := findGitDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		return resolveGitPath(gitDir, strings.TrimSpace(string(common))), nil
+	}
+	return gitDir, nil
+}
+
+// diffKey identifies the options that shape Stats and FileChanges, so cached
+// commits are only reused for runs that would compute the same values.
+func diffKey(options CommitOptions) string {
+	key := strings.Join(renameArgs(options), " ")
+	if options.FirstParent {
+		key += " --first-parent" // Merges get a diff
+	}
+	return key
+}
+
+// cacheBatchSize is how many uncached commits are fetched per git process.
+const cacheBatchSize = 256
+
+// listedStream lists the selected commits cheaply, then fetches those
+// missing from cache (all of them when cache is nil), a batch at a time, as
+// the caller reaches them. The listing is read as git prints it, so only a
+// batch of it is held at once. --author is matched against the listing,
+// co-authors included, so only the commits it keeps, up to the limit, are
+// diffed.
+func (r *ExecRepository) listedStream(ctx context.Context, options CommitOptions, cache CommitCache) (CommitIterator, error) {
+	if err := r.checkOptions(options); err != nil {
+		return nil, err
+	}
+	author, _ := authorPattern(options) // Checked by checkOptions
+	format := "%x1e%H%x00%D"
+	if author != nil {
+		format += "%x00%an%x00%ae%x00%b"
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	args := append([]string{"log", "--pretty=format:" + format, "-z"}, r.filterArgs(options)...)
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.Dir
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to execute git log: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to execute git log: %v", err)
+	}
+
+	it := &listedIterator{
+		repo:    r,
+		cache:   cache,
+		ctx:     ctx,
+		cancel:  cancel,
+		cmd:     cmd,
+		listing: bufio.NewReader(stdout),
+		options: options,
+		key:     diffKey(options),
+		author:  author,
+		fetched: make(map[string]Commit),
+	}
+	if author != nil {
+		it.limit = options.Limit // git applies it otherwise
+	}
+	return it, nil
+}
+
+// listedCommit is a commit git log listed, with its ref names.
+type listedCommit struct {
+	hash string
+	refs []string
+}
+
+// listedIterator yields the listed commits, from the cache when it has
+// them, fetching the others in batches.
+type listedIterator struct {
+	repo    *ExecRepository
+	cache   CommitCache // Nil to fetch every commit
+	ctx     context.Context
+	cancel  context.CancelFunc
+	cmd     *exec.Cmd
+	listing *bufio.Reader
+	options CommitOptions
+	key     string
+	author  *regexp.Regexp
+	limit   int
+
+	pending []listedCommit // Listed but not yet yielded
+	yielded int
+	eof     bool
+	waited  bool
+	fetched map[string]Commit
+
+	current Commit
+	err     error
+}
+
+func (it *listedIterator) Next() bool {
+	if it.err != nil || it.limit > 0 && it.yielded >= it.limit {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	if it.err = it.readListing(1); it.err != nil || len(it.pending) == 0 {
+		it.wait()
+		return false
+	}
+
+	listed := it.pending[0]
+	commit, ok := it.fetched[listed.hash]
+	if ok {
+		delete(it.fetched, listed.hash)
+	} else if commit, ok = it.cached(listed.hash); !ok {
+		if it.err = it.fetch(); it.err != nil {
+			return false
+		}
+		if commit, ok = it.fetched[listed.hash]; !ok {
+			it.err = fmt.Errorf("git log did not return commit %s", listed.hash)
+			return false
+		}
+		delete(it.fetched, listed.hash)
+	}
+
+	commit.RefNames = listed.refs
+	it.current = commit
+	it.pending = it.pending[1:]
+	it.yielded++
+	return true
+}
+
+func (it *listedIterator) cached(hash string) (Commit, bool) {
+	if it.cache == nil {
+		return Commit{}, false
+	}
+	return it.cache.Get(hash, it.key, it.options.ShowFileChanges)
+}
+
+// readListing reads the listing until n commits are pending, the limit is
+// reached or git is done.
+func (it *listedIterator) readListing(n int) error {
+	if it.limit > 0 {
+		n = min(n, it.limit-it.yielded)
+	}
+	for len(it.pending) < n && !it.eof {
+		record, err := it.listing.ReadString(recordSeparator[0])
+		if err == io.EOF {
+			it.eof = true
+		} else if err != nil {
+			return fmt.Errorf("failed to read git log output: %v", err)
+		}
+
+		fields := strings.Split(strings.TrimSuffix(record, recordSeparator), fieldSeparator)
+		if len(fields) < 2 {
+			continue
+		}
+		if it.author != nil {
+			if len(fields) < 5 {
+				return fmt.Errorf("malformed git log record for %s", fields[0])
+			}
+			commit := Commit{AuthorName: fields[2], AuthorEmail: fields[3], Trailers: ParseTrailers(strings.TrimSpace(fields[4]))}
+			if !matchesAuthor(it.author, commit) {
+				continue
+			}
+		}
+		it.pending = append(it.pending, listedCommit{hash: fields[0], refs: parseRefNames(strings.Trim(fields[1], "\n"))})
+	}
+	return nil
+}
+
+// fetch parses the next batch of uncached commits with a single git log
+// --no-walk run and stores them in the cache.
+func (it *listedIterator) fetch() error {
+	if err := it.readListing(cacheBatchSize); err != nil {
+		return err
+	}
+	var batch []string
+	for _, listed := range it.pending {
+		if _, ok := it.cached(listed.hash); !ok {
+			batch = append(batch, listed.hash)
+		}
+	}
+
+	args := []string{"log", "--no-walk=unsorted", "--stdin", "--pretty=format:" + logFormat, "--date=iso-strict", "--numstat", "-z"}
+	args = append(args, it.repo.diffArgs(it.options)...)
+	if hasPaths(it.options) {
+		// Path limits narrow each diff as they did the listing.
+		args = append(append(args, "--"), pathspecArgs(it.options)...)
+	}
+	cmd := it.repo.command(args...)
+	cmd.Stdin = strings.NewReader(strings.Join(batch, "\n") + "\n")
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to execute git log: %v", err)
+	}
+
+	commits, err := parseGitLog(string(output), it.options.ShowFileChanges)
+	if err != nil {
+		return err
+	}
+	if it.options.ShowFileChanges {
+		it.repo.fillMergeChanges(commits, it.options)
+	}
+	for _, commit := range commits {
+		if it.cache != nil {
+			it.cache.Put(commit, it.key, it.options.ShowFileChanges)
+		}
+		it.fetched[commit.Hash] = commit
+	}
+	return nil
+}
+
+func (it *listedIterator) Commit() Commit { return it.current }
+
+func (it *listedIterator) Err() error { return it.err }
+
+// Close stops the listing if the caller did not read to the end.
+func (it *listedIterator) Close() error {
+	it.cancel()
+	it.wait()
+	return nil
+}
+
+func (it *listedIterator) wait() {
+	if it.waited {
+		return
+	}
+	it.waited = true
+	err := it.cmd.Wait()
+	switch {
+	case it.err != nil:
+	case it.ctx.Err() != nil:
+		it.err = it.ctx.Err()
+	case err != nil:
+		it.err = fmt.Errorf("failed to execute git log: %v", err)
+	}
+	it.cancel()
+}
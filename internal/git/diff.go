@@ -0,0 +1,436 @@
+package git
+
+import (
+	"bytes"
+	"math"
+	"strings"
+)
+
+// binaryProbeSize matches git: a blob with a NUL in its first 8000 bytes
+// is treated as binary and gets no line counts.
+const binaryProbeSize = 8000
+
+func isBinary(data []byte) bool {
+	if len(data) > binaryProbeSize {
+		data = data[:binaryProbeSize]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// splitLines splits content the way diff sees it: a final line without a
+// trailing newline still counts as a line.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	text := string(data)
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// countLineChanges returns the insertions and deletions of the line diff
+// from a to b, as --numstat reports them.
+func countLineChanges(a, b []string) (insertions, deletions int) {
+	deleted, inserted := changedLines(a, b)
+	for _, changed := range deleted {
+		if changed {
+			deletions++
+		}
+	}
+	for _, changed := range inserted {
+		if changed {
+			insertions++
+		}
+	}
+	return insertions, deletions
+}
+
+// diffOp is one step of a line edit script.
+type diffOp int
+
+const (
+	opEqual diffOp = iota
+	opInsert
+	opDelete
+)
+
+// lineEdits returns an edit script turning a into b, one op per line of
+// the longer path through both inputs, each change's deletions before its
+// insertions.
+func lineEdits(a, b []string) []diffOp {
+	deleted, inserted := changedLines(a, b)
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && deleted[i]:
+			ops = append(ops, opDelete)
+			i++
+		case j < len(b) && inserted[j]:
+			ops = append(ops, opInsert)
+			j++
+		default:
+			ops = append(ops, opEqual)
+			i, j = i+1, j+1
+		}
+	}
+	return ops
+}
+
+// Limits of git's xdiff (xdiff/xdiffi.c and xprepare.c), which decide
+// where its diff gives up on being minimal.
+const (
+	xdlMaxEqLimit    = 1024 // Lines matching more often than this are never kept
+	xdlSimscanWindow = 100  // Lines scanned around a line with many matches
+	xdlKpdisRun      = 4    // Share of such lines that gets them discarded
+	xdlMaxCostMin    = 256  // Least edit cost before the search settles
+	xdlHeurMinCost   = 256  // Least edit cost before snakes are sampled
+	xdlSnakeCnt      = 20   // Length of a snake worth splitting at
+	xdlKHeur         = 4
+	xdlLineMax       = math.MaxInt
+)
+
+// changedLines marks the lines of a that a diff to b deletes and the lines
+// of b it inserts. It picks them the way git's default diff does rather
+// than minimally: lines without a match on the other side, and runs of
+// lines with too many matches, are left out before the Myers search, and
+// a costly search settles for a good enough split. Line counts then agree
+// with git log --numstat.
+func changedLines(a, b []string) (deleted, inserted []bool) {
+	deleted, inserted = make([]bool, len(a)), make([]bool, len(b))
+
+	// Equal lines share a class; counts say how often each class occurs
+	// in either file.
+	classes := make(map[string]int)
+	classify := func(lines []string) []int {
+		ids := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := classes[line]
+			if !ok {
+				id = len(classes)
+				classes[line] = id
+			}
+			ids[i] = id
+		}
+		return ids
+	}
+	ids1, ids2 := classify(a), classify(b)
+	count1, count2 := make([]int, len(classes)), make([]int, len(classes))
+	for _, id := range ids1 {
+		count1[id]++
+	}
+	for _, id := range ids2 {
+		count2[id]++
+	}
+
+	// The common prefix and suffix are unchanged.
+	start := 0
+	for start < len(a) && start < len(b) && ids1[start] == ids2[start] {
+		start++
+	}
+	end1, end2 := len(a), len(b)
+	for end1 > start && end2 > start && ids1[end1-1] == ids2[end2-1] {
+		end1, end2 = end1-1, end2-1
+	}
+
+	x := &xdiff{}
+	x.ids1, x.index1 = discardLines(ids1, count2, start, end1, deleted)
+	x.ids2, x.index2 = discardLines(ids2, count1, start, end2, inserted)
+	x.changed1, x.changed2 = deleted, inserted
+
+	diags := len(x.ids1) + len(x.ids2) + 3
+	x.forward, x.backward = make([]int, diags), make([]int, diags)
+	x.offset = len(x.ids2) + 1
+	x.maxCost = max(bogoSqrt(diags), xdlMaxCostMin)
+	x.compare(0, len(x.ids1), 0, len(x.ids2), false)
+	return deleted, inserted
+}
+
+// discardLines returns the lines of ids[start:end] the Myers search gets
+// to match, with their places in ids, and marks the others changed: lines
+// the other file does not have, and lines with many matches there that sit
+// among such lines. otherCounts counts each class in the other file.
+func discardLines(ids, otherCounts []int, start, end int, changed []bool) (kept, index []int) {
+	limit := min(bogoSqrt(len(ids)), xdlMaxEqLimit)
+	// 0: no match, 1: some matches, 2: too many.
+	discard := make([]int, len(ids))
+	for i := start; i < end; i++ {
+		switch n := otherCounts[ids[i]]; {
+		case n == 0:
+			discard[i] = 0
+		case n >= limit:
+			discard[i] = 2
+		default:
+			discard[i] = 1
+		}
+	}
+
+	for i := start; i < end; i++ {
+		if discard[i] == 1 || (discard[i] == 2 && !amongUnmatched(discard, i, start, end-1)) {
+			kept = append(kept, ids[i])
+			index = append(index, i)
+		} else {
+			changed[i] = true
+		}
+	}
+	return kept, index
+}
+
+// amongUnmatched reports whether the line at i, one with many matches,
+// sits in a run of lines without a match or with many, with enough of
+// them unmatched on both sides of it (git's xdl_clean_mmatch). s and e
+// are the first and last lines that count.
+func amongUnmatched(discard []int, i, s, e int) bool {
+	s = max(s, i-xdlSimscanWindow)
+	e = min(e, i+xdlSimscanWindow)
+
+	unmatchedBefore, manyBefore := 0, 1
+	for r := 1; i-r >= s; r++ {
+		if discard[i-r] == 0 {
+			unmatchedBefore++
+		} else if discard[i-r] == 2 {
+			manyBefore++
+		} else {
+			break
+		}
+	}
+	if unmatchedBefore == 0 {
+		return false
+	}
+	unmatchedAfter, manyAfter := 0, 1
+	for r := 1; i+r <= e; r++ {
+		if discard[i+r] == 0 {
+			unmatchedAfter++
+		} else if discard[i+r] == 2 {
+			manyAfter++
+		} else {
+			break
+		}
+	}
+	if unmatchedAfter == 0 {
+		return false
+	}
+	many := manyBefore + manyAfter
+	return many*xdlKpdisRun < many+unmatchedBefore+unmatchedAfter
+}
+
+// bogoSqrt is xdiff's rough square root: the power of two with about half
+// the bits of n.
+func bogoSqrt(n int) int {
+	i := 1
+	for ; n > 0; n >>= 2 {
+		i <<= 1
+	}
+	return i
+}
+
+// xdiff is the state of git's divide-and-conquer Myers search over the
+// lines left after discardLines.
+type xdiff struct {
+	ids1, ids2         []int  // Classes of the lines searched
+	index1, index2     []int  // Their places in the files
+	changed1, changed2 []bool // Marks per line of each file
+	// forward and backward hold the furthest line of the first file
+	// reached on each diagonal, offset so diagonals can be negative.
+	forward, backward []int
+	offset            int
+	maxCost           int
+}
+
+// compare marks the changed lines between off and lim of each side
+// (xdl_recs_cmp).
+func (x *xdiff) compare(off1, lim1, off2, lim2 int, needMin bool) {
+	for off1 < lim1 && off2 < lim2 && x.ids1[off1] == x.ids2[off2] {
+		off1, off2 = off1+1, off2+1
+	}
+	for off1 < lim1 && off2 < lim2 && x.ids1[lim1-1] == x.ids2[lim2-1] {
+		lim1, lim2 = lim1-1, lim2-1
+	}
+
+	switch {
+	case off1 == lim1:
+		for ; off2 < lim2; off2++ {
+			x.changed2[x.index2[off2]] = true
+		}
+	case off2 == lim2:
+		for ; off1 < lim1; off1++ {
+			x.changed1[x.index1[off1]] = true
+		}
+	default:
+		i1, i2, minLo, minHi := x.split(off1, lim1, off2, lim2, needMin)
+		x.compare(off1, i1, off2, i2, minLo)
+		x.compare(i1, lim1, i2, lim2, minHi)
+	}
+}
+
+// split finds where to divide the box between off and lim: the middle
+// snake of a minimal path, or, unless needMin is set and once the search
+// has cost enough, a promising or the furthest reaching point. minLo and
+// minHi say whether each half must then be searched minimally
+// (xdl_split).
+func (x *xdiff) split(off1, lim1, off2, lim2 int, needMin bool) (i1, i2 int, minLo, minHi bool) {
+	ids1, ids2 := x.ids1, x.ids2
+	kf, kb, o := x.forward, x.backward, x.offset
+	dmin, dmax := off1-lim2, lim1-off2
+	fmid, bmid := off1-off2, lim1-lim2
+	odd := (fmid-bmid)&1 != 0
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+
+	kf[o+fmid] = off1
+	kb[o+bmid] = lim1
+
+	for ec := 1; ; ec++ {
+		gotSnake := false
+
+		// Widen the diagonals searched by one on each side, or move them
+		// away from the edge of the box.
+		if fmin > dmin {
+			fmin--
+			kf[o+fmin-1] = -1
+		} else {
+			fmin++
+		}
+		if fmax < dmax {
+			fmax++
+			kf[o+fmax+1] = -1
+		} else {
+			fmax--
+		}
+		for d := fmax; d >= fmin; d -= 2 {
+			if kf[o+d-1] >= kf[o+d+1] {
+				i1 = kf[o+d-1] + 1
+			} else {
+				i1 = kf[o+d+1]
+			}
+			prev := i1
+			i2 = i1 - d
+			for i1 < lim1 && i2 < lim2 && ids1[i1] == ids2[i2] {
+				i1, i2 = i1+1, i2+1
+			}
+			if i1-prev > xdlSnakeCnt {
+				gotSnake = true
+			}
+			kf[o+d] = i1
+			if odd && bmin <= d && d <= bmax && kb[o+d] <= i1 {
+				return i1, i2, true, true
+			}
+		}
+
+		if bmin > dmin {
+			bmin--
+			kb[o+bmin-1] = xdlLineMax
+		} else {
+			bmin++
+		}
+		if bmax < dmax {
+			bmax++
+			kb[o+bmax+1] = xdlLineMax
+		} else {
+			bmax--
+		}
+		for d := bmax; d >= bmin; d -= 2 {
+			if kb[o+d-1] < kb[o+d+1] {
+				i1 = kb[o+d-1]
+			} else {
+				i1 = kb[o+d+1] - 1
+			}
+			prev := i1
+			i2 = i1 - d
+			for i1 > off1 && i2 > off2 && ids1[i1-1] == ids2[i2-1] {
+				i1, i2 = i1-1, i2-1
+			}
+			if prev-i1 > xdlSnakeCnt {
+				gotSnake = true
+			}
+			kb[o+d] = i1
+			if !odd && fmin <= d && d <= fmax && i1 <= kf[o+d] {
+				return i1, i2, true, true
+			}
+		}
+
+		if needMin {
+			continue
+		}
+
+		// Past a certain cost, split at a diagonal that has come far
+		// for its distance from the middle and ends in a long snake.
+		if gotSnake && ec > xdlHeurMinCost {
+			best := 0
+			for d := fmax; d >= fmin; d -= 2 {
+				f1 := kf[o+d]
+				f2 := f1 - d
+				v := (f1 - off1) + (f2 - off2) - abs(d-fmid)
+				if v > xdlKHeur*ec && v > best &&
+					off1+xdlSnakeCnt <= f1 && f1 < lim1 && off2+xdlSnakeCnt <= f2 && f2 < lim2 {
+					for k := 1; ids1[f1-k] == ids2[f2-k]; k++ {
+						if k == xdlSnakeCnt {
+							best, i1, i2 = v, f1, f2
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				return i1, i2, true, false
+			}
+
+			for d := bmax; d >= bmin; d -= 2 {
+				b1 := kb[o+d]
+				b2 := b1 - d
+				v := (lim1 - b1) + (lim2 - b2) - abs(d-bmid)
+				if v > xdlKHeur*ec && v > best &&
+					off1 < b1 && b1 <= lim1-xdlSnakeCnt && off2 < b2 && b2 <= lim2-xdlSnakeCnt {
+					for k := 0; ids1[b1+k] == ids2[b2+k]; k++ {
+						if k == xdlSnakeCnt-1 {
+							best, i1, i2 = v, b1, b2
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				return i1, i2, false, true
+			}
+		}
+
+		// Too costly: take the furthest reaching point of either search.
+		if ec >= x.maxCost {
+			fbest, fbest1 := -1, -1
+			for d := fmax; d >= fmin; d -= 2 {
+				f1 := min(kf[o+d], lim1)
+				f2 := f1 - d
+				if lim2 < f2 {
+					f1, f2 = lim2+d, lim2
+				}
+				if fbest < f1+f2 {
+					fbest, fbest1 = f1+f2, f1
+				}
+			}
+			bbest, bbest1 := xdlLineMax, xdlLineMax
+			for d := bmax; d >= bmin; d -= 2 {
+				b1 := max(off1, kb[o+d])
+				b2 := b1 - d
+				if b2 < off2 {
+					b1, b2 = off2+d, off2
+				}
+				if b1+b2 < bbest {
+					bbest, bbest1 = b1+b2, b1
+				}
+			}
+			if (lim1+lim2)-bbest < fbest-(off1+off2) {
+				return fbest1, fbest - fbest1, true, false
+			}
+			return bbest1, bbest - bbest1, false, true
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
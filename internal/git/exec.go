@@ -0,0 +1,581 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExecRepository reads history by running the git binary.
+type ExecRepository struct {
+	Dir   string      // Working directory for git; empty means the current one
+	Cache CommitCache // Optional; when set only uncached commits are parsed
+
+	perlOnce sync.Once
+	perl     bool // git matches --grep as Perl regexps
+}
+
+func NewExecRepository(dir string) *ExecRepository {
+	return &ExecRepository{Dir: dir}
+}
+
+func (r *ExecRepository) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	return cmd
+}
+
+func (r *ExecRepository) logArgs(options CommitOptions) []string {
+	args := []string{
+		"log",
+		"--pretty=format:" + logFormat,
+		"--date=iso-strict",
+		"--numstat",
+		"-z",
+	}
+	args = append(args, r.diffArgs(options)...)
+	return append(args, r.filterArgs(options)...)
+}
+
+// diffArgs are the options that shape each commit's Stats and FileChanges.
+func (r *ExecRepository) diffArgs(options CommitOptions) []string {
+	args := renameArgs(options)
+	if options.ShowFileChanges {
+		args = append(args, "--raw") // Show file status alongside numstat
+	}
+	if options.FirstParent {
+		args = append(args, "--diff-merges=first-parent")
+	}
+	if hasPickaxe(options) {
+		args = append(args, "--pickaxe-all") // List every file, not only matches
+	}
+	return args
+}
+
+// renameArgs turn the rename and copy thresholds into -M/-C flags. Renames
+// are always asked for explicitly because diff-tree does not detect them
+// by default, unlike log.
+func renameArgs(options CommitOptions) []string {
+	if options.NoRenames {
+		return []string{"--no-renames"}
+	}
+	args := []string{"-M"}
+	if options.RenameThreshold > 0 {
+		args[0] = fmt.Sprintf("-M%d%%", options.RenameThreshold)
+	}
+	if options.CopyThreshold > 0 {
+		args = append(args, fmt.Sprintf("-C%d%%", options.CopyThreshold))
+	}
+	return args
+}
+
+// filterArgs are the options that select which commits are listed.
+func (r *ExecRepository) filterArgs(options CommitOptions) []string {
+	var args []string
+	// git's --author only reads the author header, so --author, and with
+	// it the limit, are applied by listedStream to count co-authors.
+	if options.Limit > 0 && options.Author == "" {
+		args = append(args, fmt.Sprintf("--max-count=%d", options.Limit))
+	}
+	if options.Since != "" {
+		args = append(args, fmt.Sprintf("--since=%s", options.Since))
+	}
+	if options.Until != "" {
+		args = append(args, fmt.Sprintf("--until=%s", options.Until))
+	}
+	if options.MergesOnly {
+		args = append(args, "--merges")
+	}
+	if options.NoMerges {
+		args = append(args, "--no-merges")
+	}
+	if options.Follow {
+		args = append(args, "--follow")
+	}
+	if options.FirstParent {
+		args = append(args, "--first-parent")
+	}
+	args = append(args, orderArgs(options)...)
+	args = append(args, searchArgs(options, len(options.Grep) > 0 && r.perlRegexps())...)
+	// Revisions come last and "--" keeps git from taking one for a file.
+	args = append(args, revisionExprs(options)...)
+	args = append(args, "--")
+	return append(args, pathspecArgs(options)...)
+}
+
+// checkOptions rejects options git would fail on, with readable errors,
+// before any git log runs.
+func (r *ExecRepository) checkOptions(options CommitOptions) error {
+	if err := validatePaths(options); err != nil {
+		return err
+	}
+	if err := checkOrder(options); err != nil {
+		return err
+	}
+	if _, err := GrepPattern(options); err != nil {
+		return err
+	}
+	if _, err := authorPattern(options); err != nil {
+		return err
+	}
+	if _, err := newPickaxe(options); err != nil {
+		return err
+	}
+	revs, err := parseRevisions(revisionExprs(options))
+	if err != nil {
+		return err
+	}
+
+	names := revs.endpoints()
+	var input strings.Builder
+	for _, name := range names {
+		input.WriteString(name + "^{commit}\n")
+	}
+	cmd := r.command("cat-file", "--batch-check=%(objectname)")
+	cmd.Stdin = strings.NewReader(input.String())
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to execute git cat-file: %v", err)
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(string(output), "\n"), "\n") {
+		if i >= len(names) {
+			break
+		}
+		switch {
+		case strings.HasSuffix(line, " missing"):
+			return fmt.Errorf("unknown revision %s", names[i])
+		case strings.HasSuffix(line, " ambiguous"):
+			return fmt.Errorf("ambiguous revision %s", names[i])
+		}
+	}
+	return nil
+}
+
+func (r *ExecRepository) Log(options CommitOptions) ([]Commit, error) {
+	commits, err := r.log(options)
+	if err != nil || !options.ShowFileChanges || !hasPickaxe(options) {
+		return commits, err
+	}
+	matches, err := r.pickaxeMatches(options)
+	if err != nil {
+		return nil, err
+	}
+	for i := range commits {
+		markPickaxe(&commits[i], matches)
+	}
+	return commits, nil
+}
+
+// listedCache is the cache a listed stream reads and fills, and whether
+// options are read that way at all: to reuse cached commits, or with
+// --author, to diff only the commits it keeps. Path limits narrow each
+// commit's diff as well, so those runs neither read nor fill the cache.
+// --follow has git track renames as it walks, which needs the single pass.
+func (r *ExecRepository) listedCache(options CommitOptions) (CommitCache, bool) {
+	switch {
+	case options.Follow:
+		return nil, false
+	case r.Cache != nil && !hasPaths(options):
+		return r.Cache, true
+	default:
+		return nil, options.Author != ""
+	}
+}
+
+func (r *ExecRepository) log(options CommitOptions) ([]Commit, error) {
+	if cache, ok := r.listedCache(options); ok {
+		it, err := r.listedStream(context.Background(), options, cache)
+		if err != nil {
+			return nil, err
+		}
+		return Collect(it)
+	}
+
+	it, err := r.stream(context.Background(), options, false)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := Collect(withAuthorFilter(it, options))
+	if err != nil {
+		return nil, err
+	}
+
+	// git log prints no diff for merges, so their changes against the
+	// first parent need a second pass.
+	if options.ShowFileChanges {
+		r.fillMergeChanges(commits, options)
+	}
+
+	return commits, nil
+}
+
+// Stream runs git log and parses each commit as soon as git prints it.
+// Merge commits are diffed inline, one at a time, to keep the order.
+func (r *ExecRepository) Stream(ctx context.Context, options CommitOptions) (CommitIterator, error) {
+	var it CommitIterator
+	var err error
+	if cache, ok := r.listedCache(options); ok {
+		it, err = r.listedStream(ctx, options, cache)
+	} else if it, err = r.stream(ctx, options, options.ShowFileChanges); err == nil {
+		it = withAuthorFilter(it, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !options.ShowFileChanges || !hasPickaxe(options) {
+		return it, nil
+	}
+
+	matches, err := r.pickaxeMatches(options)
+	if err != nil {
+		it.Close()
+		return nil, err
+	}
+	return &pickaxeIterator{CommitIterator: it, matches: matches}, nil
+}
+
+// withAuthorFilter applies --author and --limit to commits git listed
+// without them, matching co-authors as well as the author. Only --follow
+// runs need it; listedStream filters the others before diffing.
+func withAuthorFilter(it CommitIterator, options CommitOptions) CommitIterator {
+	pattern, _ := authorPattern(options) // Checked by checkOptions
+	if pattern == nil {
+		return it
+	}
+	return &filterIterator{
+		CommitIterator: it,
+		keep:           func(commit Commit) bool { return matchesAuthor(pattern, commit) },
+		limit:          options.Limit,
+	}
+}
+
+// pickaxeMatches runs the -S or -G search again without --pickaxe-all to
+// learn which files of each commit matched.
+func (r *ExecRepository) pickaxeMatches(options CommitOptions) (map[string]map[string]bool, error) {
+	args := append([]string{"log", "--pretty=format:%x1e%H", "--name-only", "-z"}, renameArgs(options)...)
+	cmd := r.command(append(args, r.filterArgs(options)...)...)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute git log: %v", err)
+	}
+
+	matches := make(map[string]map[string]bool)
+	for _, record := range strings.Split(string(output), recordSeparator) {
+		// The hash ends with a newline, each file name with a NUL.
+		hash, names, _ := strings.Cut(record, "\n")
+		if hash = strings.TrimSpace(hash); hash == "" {
+			continue
+		}
+		files := make(map[string]bool)
+		for _, name := range strings.Split(names, fieldSeparator) {
+			if name = strings.Trim(name, "\n"); name != "" {
+				files[name] = true
+			}
+		}
+		matches[hash] = files
+	}
+	return matches, nil
+}
+
+func (r *ExecRepository) stream(ctx context.Context, options CommitOptions, diffMerges bool) (*execIterator, error) {
+	if err := r.checkOptions(options); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	cmd := exec.CommandContext(ctx, "git", r.logArgs(options)...)
+	cmd.Dir = r.Dir
+	cmd.Stderr = os.Stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to execute git log: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to execute git log: %v", err)
+	}
+
+	return &execIterator{
+		repo:            r,
+		ctx:             ctx,
+		cancel:          cancel,
+		cmd:             cmd,
+		reader:          bufio.NewReaderSize(stdout, 64*1024),
+		options:         options,
+		showFileChanges: options.ShowFileChanges,
+		diffMerges:      diffMerges,
+	}, nil
+}
+
+// execIterator reads one record-separated commit at a time from git log.
+type execIterator struct {
+	repo            *ExecRepository
+	ctx             context.Context
+	cancel          context.CancelFunc
+	cmd             *exec.Cmd
+	reader          *bufio.Reader
+	options         CommitOptions
+	showFileChanges bool
+	diffMerges      bool
+
+	current Commit
+	err     error
+	eof     bool
+	waited  bool
+}
+
+func (it *execIterator) Next() bool {
+	for !it.eof && it.err == nil {
+		record, err := it.reader.ReadString(recordSeparator[0])
+		if err == io.EOF {
+			it.eof = true
+		} else if err != nil {
+			it.err = fmt.Errorf("failed to read git log output: %v", err)
+			break
+		}
+
+		record = strings.TrimSuffix(record, recordSeparator)
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+
+		commit, err := parseCommitRecord(record, it.showFileChanges)
+		if err != nil {
+			it.err = err
+			break
+		}
+		if it.diffMerges && !it.options.FirstParent && len(commit.ParentHashes) > 1 {
+			if changes, err := it.repo.diffTree(commit.ParentHashes[0], commit.Hash, it.options); err == nil {
+				commit.FileChanges = changes
+			}
+		}
+		it.current = commit
+		return true
+	}
+
+	it.wait()
+	return false
+}
+
+func (it *execIterator) Commit() Commit { return it.current }
+
+func (it *execIterator) Err() error { return it.err }
+
+// Close stops git if the caller did not read to the end.
+func (it *execIterator) Close() error {
+	it.cancel()
+	it.wait()
+	return nil
+}
+
+func (it *execIterator) wait() {
+	if it.waited {
+		return
+	}
+	it.waited = true
+	err := it.cmd.Wait()
+	switch {
+	case it.err != nil:
+	case it.ctx.Err() != nil:
+		it.err = it.ctx.Err()
+	case err != nil:
+		it.err = fmt.Errorf("failed to execute git log: %v", err)
+	}
+	it.cancel()
+}
+
+func (r *ExecRepository) Show(rev string) (*Commit, error) {
+	cmd := r.command("show", "--pretty=format:"+logFormat, "--date=iso-strict", "--numstat", "--raw", "-z", rev, "--")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute git show %s: %v", rev, err)
+	}
+
+	commits, err := parseGitLog(string(output), true)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("no commit found for %s", rev)
+	}
+	return &commits[0], nil
+}
+
+func (r *ExecRepository) Refs() ([]Ref, error) {
+	cmd := r.command("for-each-ref", "--format=%(refname)%00%(objectname)%00%(*objectname)")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute git for-each-ref: %v", err)
+	}
+
+	var refs []Ref
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, fieldSeparator)
+		if len(fields) < 3 {
+			continue
+		}
+		refs = append(refs, Ref{Name: fields[0], Hash: fields[1], Target: fields[2]})
+	}
+	return refs, nil
+}
+
+func (r *ExecRepository) Tags() ([]Tag, error) {
+	// Separators are written as for-each-ref escapes; arguments cannot
+	// hold NUL.
+	format := strings.Join([]string{
+		"%(refname:short)", "%(objecttype)", "%(objectname)", "%(*objectname)",
+		"%(creatordate:iso-strict)", "%(*objecttype)", "%(contents)",
+	}, "%00") + "%1e"
+	output, err := r.command("for-each-ref", "--format="+format, "refs/tags").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute git for-each-ref: %v", err)
+	}
+
+	var tags []Tag
+	for _, record := range strings.Split(string(output), recordSeparator) {
+		fields := strings.Split(strings.TrimPrefix(record, "\n"), fieldSeparator)
+		if len(fields) < 7 {
+			continue
+		}
+		tag := Tag{Name: fields[0], Commit: fields[2]}
+		switch {
+		case fields[1] == "tag" && fields[5] == "commit":
+			tag.Commit = fields[3]
+			tag.Annotated = true
+			tag.Message = tagMessage(fields[6])
+		case fields[1] != "commit":
+			continue // Tags of trees, blobs or other tags
+		}
+		tag.Date, _ = time.Parse(time.RFC3339, fields[4])
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// tagMessage is an annotated tag's message without its signature.
+func tagMessage(message string) string {
+	if i := strings.Index(message, "-----BEGIN "); i >= 0 {
+		message = message[:i]
+	}
+	return strings.TrimSpace(message)
+}
+
+func (r *ExecRepository) Diff(from, to string) ([]FileChange, error) {
+	cmd := r.command("diff", "-M", "--raw", "--numstat", "-z", from, to, "--")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute git diff %s %s: %v", from, to, err)
+	}
+	changes, _ := parseDiffSection(string(output))
+	return changes, nil
+}
+
+func (r *ExecRepository) Blame(path, rev string) ([]BlameLine, error) {
+	args := []string{"blame", "--line-porcelain"}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	args = append(args, "--", path)
+
+	output, err := r.command(args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute git blame %s: %v", path, err)
+	}
+	return parseBlamePorcelain(string(output)), nil
+}
+
+// parseBlamePorcelain reads `git blame --line-porcelain` output, where every
+// line is preceded by a full header and the content line starts with a tab.
+func parseBlamePorcelain(output string) []BlameLine {
+	var lines []BlameLine
+	var current BlameLine
+	var authorTime int64
+	var authorTZ string
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			current.Content = line[1:]
+			current.AuthorDate = parseUnixWithZone(authorTime, authorTZ)
+			lines = append(lines, current)
+			current = BlameLine{}
+		case strings.HasPrefix(line, "author "):
+			current.AuthorName = strings.TrimPrefix(line, "author ")
+		case strings.HasPrefix(line, "author-mail "):
+			current.AuthorEmail = strings.Trim(strings.TrimPrefix(line, "author-mail "), "<>")
+		case strings.HasPrefix(line, "author-time "):
+			authorTime, _ = strconv.ParseInt(strings.TrimPrefix(line, "author-time "), 10, 64)
+		case strings.HasPrefix(line, "author-tz "):
+			authorTZ = strings.TrimPrefix(line, "author-tz ")
+		case current.Hash == "":
+			// Header line: <hash> <original line> <final line> [<group size>]
+			fields := strings.Fields(line)
+			if len(fields) >= 3 {
+				current.Hash = fields[0]
+				current.LineNumber, _ = strconv.Atoi(fields[2])
+			}
+		}
+	}
+	return lines
+}
+
+// diffWorkers bounds the number of git processes started by a second pass.
+var diffWorkers = runtime.NumCPU()
+
+// fillMergeChanges diffs every merge commit against its first parent using
+// a bounded pool of git diff-tree processes. Commits whose diff fails keep
+// no file changes, as before.
+func (r *ExecRepository) fillMergeChanges(commits []Commit, options CommitOptions) {
+	if options.FirstParent {
+		return // git log already diffed them against their first parent
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < diffWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				changes, err := r.diffTree(commits[i].ParentHashes[0], commits[i].Hash, options)
+				if err == nil {
+					commits[i].FileChanges = changes
+				}
+			}
+		}()
+	}
+
+	for i := range commits {
+		if len(commits[i].ParentHashes) > 1 {
+			jobs <- i
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (r *ExecRepository) diffTree(from, to string, options CommitOptions) ([]FileChange, error) {
+	args := append([]string{"diff-tree", "-r", "--raw", "--numstat", "-z"}, renameArgs(options)...)
+	args = append(args, from, to, "--")
+	cmd := r.command(append(args, pathspecArgs(options)...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute git diff-tree %s %s: %v", from, to, err)
+	}
+	changes, _ := parseDiffSection(string(output))
+	return changes, nil
+}
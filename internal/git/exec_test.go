@@ -0,0 +1,188 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// mapCache is a CommitCache held in memory.
+type mapCache map[string]Commit
+
+func (c mapCache) Get(hash, diffKey string, withFiles bool) (Commit, bool) {
+	commit, ok := c[hash+diffKey+fmt.Sprint(withFiles)]
+	return commit, ok
+}
+
+func (c mapCache) Put(commit Commit, diffKey string, withFiles bool) {
+	c[commit.Hash+diffKey+fmt.Sprint(withFiles)] = commit
+}
+
+func TestCachedLogMatchesLog(t *testing.T) {
+	r := newTestRepo(t)
+	buildHistory(r)
+	plain := NewExecRepository(r.dir)
+	cached := NewExecRepository(r.dir)
+	cached.Cache = mapCache{}
+
+	for _, options := range backendOptions {
+		if hasPaths(options) {
+			continue // Never cached
+		}
+		want, err := plain.Log(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The first run fills the cache, the second reads from it.
+		for _, run := range []string{"cold", "warm"} {
+			got, err := cached.Log(options)
+			if err != nil {
+				t.Fatalf("%s Log(%+v): %v", run, options, err)
+			}
+			if diff := commitsDiff(t, got, want); diff != "" {
+				t.Errorf("%s Log(%+v): %s", run, options, diff)
+			}
+		}
+	}
+
+	it, err := cached.Stream(context.Background(), CommitOptions{Revisions: []string{"nowhere"}})
+	if err == nil {
+		for it.Next() {
+		}
+		err = it.Err()
+		it.Close()
+	}
+	if err == nil {
+		t.Error("Stream of an unknown revision succeeded")
+	}
+
+	// Closing part way stops git.
+	it, err = cached.Stream(context.Background(), CommitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	it.Close()
+}
+
+func TestGrepWithoutPCRE(t *testing.T) {
+	r := newTestRepo(t)
+	buildHistory(r)
+	native, err := NewNativeRepository(r.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// As if git had been built without PCRE.
+	repo := NewExecRepository(r.dir)
+	repo.perlOnce.Do(func() {})
+
+	// Without PCRE git reads the patterns as POSIX extended expressions,
+	// which agree with Go's syntax short of escapes like \d.
+	options := CommitOptions{Grep: []string{`^(Add|Edit) [a-z]+$`, `part (one|two)`}, IgnoreCase: true}
+	args := strings.Join(repo.filterArgs(options), " ")
+	if !strings.Contains(args, "--extended-regexp") || strings.Contains(args, "--perl-regexp") {
+		t.Errorf("filterArgs = %s, want --extended-regexp", args)
+	}
+	want, err := native.Log(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.Log(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 3 {
+		t.Errorf("native Log(%+v) found %d commits, want 3", options, len(want))
+	}
+	if diff := commitsDiff(t, got, want); diff != "" {
+		t.Errorf("Log(%+v): %s", options, diff)
+	}
+}
+
+// benchmarkRepo is buildHistory followed by n commits that each edit two
+// files, with a merge every tenth commit.
+func benchmarkRepo(b *testing.B, n int) *ExecRepository {
+	b.Helper()
+	r := newTestRepo(b)
+	buildHistory(r)
+
+	rng := rand.New(rand.NewSource(3))
+	lines := source(rng, 300)
+	for i := 0; i < n; i++ {
+		lines = edit(rng, lines, 0.05)
+		if i%10 == 9 {
+			r.git("checkout", "-q", "-b", fmt.Sprintf("topic%d", i))
+			r.commit(fmt.Sprintf("Topic %d", i), map[string]string{fmt.Sprintf("topic/%d.txt", i): "topic\n"})
+			r.git("checkout", "-q", "main")
+			r.git("merge", "-q", "--no-ff", "-m", fmt.Sprintf("Merge topic %d", i), fmt.Sprintf("topic%d", i))
+			continue
+		}
+		r.commit(fmt.Sprintf("Change %d", i), map[string]string{
+			"bench.go":                   joinLines(lines),
+			fmt.Sprintf("log/%d.txt", i): "entry\n",
+		})
+	}
+	return NewExecRepository(r.dir)
+}
+
+// BenchmarkLogSinglePass reads file changes the way Log does: one git log
+// --raw --numstat pass, then diff-tree for the merges only.
+func BenchmarkLogSinglePass(b *testing.B) {
+	repo := benchmarkRepo(b, 100)
+	options := CommitOptions{ShowFileChanges: true}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		commits, err := repo.Log(options)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(commits) < 100 {
+			b.Fatalf("read %d commits", len(commits))
+		}
+	}
+}
+
+// BenchmarkLogShowPerCommit reads the same history by listing the hashes
+// and running git show for each commit, as Log did before the single pass.
+func BenchmarkLogShowPerCommit(b *testing.B) {
+	repo := benchmarkRepo(b, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		output, err := repo.command("rev-list", "HEAD").Output()
+		if err != nil {
+			b.Fatal(err)
+		}
+		hashes := strings.Fields(string(output))
+		for _, hash := range hashes {
+			if _, err := repo.Show(hash); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if len(hashes) < 100 {
+			b.Fatalf("read %d commits", len(hashes))
+		}
+	}
+}
+
+// BenchmarkFillMergeChanges measures the diff-tree pass over the merges
+// on its own.
+func BenchmarkFillMergeChanges(b *testing.B) {
+	repo := benchmarkRepo(b, 100)
+	options := CommitOptions{ShowFileChanges: true}
+	it, err := repo.stream(context.Background(), options, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	commits, err := Collect(it)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		repo.fillMergeChanges(commits, options)
+	}
+}
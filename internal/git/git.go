@@ -1,36 +1,49 @@
 package git
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type Commit struct {
-	Hash         string
-	ShortHash    string
-	AuthorName   string
-	AuthorEmail  string
-	AuthorDate   time.Time
-	Committer    string
-	CommitDate   time.Time
-	Message      string
-	Body         string
-	ParentHashes []string
-	RefNames     []string
-	Stats        *CommitStats
-	FileChanges  []FileChange // New field for detailed file changes
+	Hash           string
+	ShortHash      string
+	AuthorName     string
+	AuthorEmail    string
+	AuthorDate     time.Time // In the author's time zone
+	Committer      string
+	CommitterEmail string
+	CommitDate     time.Time
+	Message        string
+	Body           string
+	Trailers       []Trailer // Parsed from the end of Body
+	ParentHashes   []string
+	RefNames       []string
+	Stats          *CommitStats
+	FileChanges    []FileChange // New field for detailed file changes
+}
+
+// Recommitted reports whether the commit was committed by someone other
+// than its author, as when they rebase, cherry-pick or apply another
+// person's work. The dates are not compared: an author amending their own
+// commit keeps its author date too.
+func (c Commit) Recommitted() bool {
+	return c.Committer != c.AuthorName || !strings.EqualFold(c.CommitterEmail, c.AuthorEmail)
 }
 
 type FileChange struct {
-	Status   string // Added, Modified, Deleted, Renamed, Copied
-	FilePath string
-	OldPath  string // For renames/copies
+	Status     string // Added, Modified, Deleted, Renamed, Copied
+	FilePath   string
+	OldPath    string // For renames/copies
 	Insertions int
 	Deletions  int
+	Similarity int  // Percentage for renames and copies, 100 for exact ones
+	Binary     bool // Line counts are not meaningful for binary files
+	// PickaxeMatch marks the files a -S or -G search matched. It is
+	// never cached.
+	PickaxeMatch bool
 }
 
 type CommitStats struct {
@@ -40,213 +53,199 @@ type CommitStats struct {
 }
 
 type CommitOptions struct {
-	Limit          int
-	Author         string
-	Since          string
-	Until          string
-	Branch         string
-	MergesOnly     bool
-	NoMerges       bool
+	Limit           int
+	Author          string
+	Since           string
+	Until           string
+	Branch          string
+	MergesOnly      bool
+	NoMerges        bool
 	ShowFileChanges bool // New option
+	// Rename and copy detection, as git's -M<n>% and -C<n>%. Zero leaves
+	// renames to git's default and copies off; NoRenames turns both off.
+	RenameThreshold int
+	CopyThreshold   int
+	NoRenames       bool
+	// Paths limits history to commits touching these pathspecs, minus
+	// ExcludePaths. Follow tracks a single file across renames.
+	Paths        []string
+	ExcludePaths []string
+	Follow       bool
+	// Revisions are revision expressions as git log takes them: refs,
+	// hashes, A..B, A...B and ^A. With none and no Branch, HEAD is shown.
+	Revisions   []string
+	FirstParent bool // Follow only the first parent of merges
+	// Grep keeps commits whose message matches any of the patterns, or
+	// with InvertGrep, none of them. IgnoreCase applies to Grep, Author
+	// and the pickaxe. Pickaxe (-S) keeps commits that change how often
+	// a string occurs; PickaxeRegex (-G) those whose added or removed
+	// lines match a pattern.
+	Grep         []string
+	InvertGrep   bool
+	IgnoreCase   bool
+	Pickaxe      string
+	PickaxeRegex string
+	// Order is empty, or one of the Order constants to list no commit
+	// before its children.
+	Order string
 }
 
-func GetCommits(options CommitOptions) ([]Commit, error) {
-	args := []string{
-		"log",
-		"--pretty=format:%H|%h|%an|%ae|%ad|%cn|%cd|%s|%b|%P|%D",
-		"--date=iso-strict",
-		"--stat",
-	}
+// logFields are the pretty-format placeholders emitted for every commit, in
+// the order parseCommitRecord expects them.
+var logFields = []string{"%H", "%h", "%an", "%ae", "%ad", "%cn", "%ce", "%cd", "%s", "%b", "%P", "%D"}
 
-	if options.ShowFileChanges {
-		args = append(args, "--name-status") // Show detailed file changes
-	}
+const (
+	recordSeparator = "\x1e"
+	fieldSeparator  = "\x00"
+)
 
-	if options.Limit > 0 {
-		args = append(args, fmt.Sprintf("--max-count=%d", options.Limit))
-	}
-	if options.Author != "" {
-		args = append(args, fmt.Sprintf("--author=%s", options.Author))
-	}
-	if options.Since != "" {
-		args = append(args, fmt.Sprintf("--since=%s", options.Since))
-	}
-	if options.Until != "" {
-		args = append(args, fmt.Sprintf("--until=%s", options.Until))
-	}
-	if options.Branch != "" {
-		args = append(args, options.Branch)
-	}
-	if options.MergesOnly {
-		args = append(args, "--merges")
-	}
-	if options.NoMerges {
-		args = append(args, "--no-merges")
+// logFormat starts each commit with a record separator and terminates every
+// header field with a NUL, so subjects and bodies may contain any text
+// (pipes, blank lines, unicode) without confusing the parser. Whatever git
+// prints after the last field (--raw and --numstat output) belongs to the
+// same record.
+var logFormat = "%x1e" + strings.Join(logFields, "%x00") + "%x00"
+
+func parseGitLog(output string, showFileChanges bool) ([]Commit, error) {
+	var commits []Commit
+
+	for _, record := range strings.Split(output, recordSeparator) {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+
+		commit, err := parseCommitRecord(record, showFileChanges)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
 	}
 
-	cmd := exec.Command("git", args...)
-	cmd.Stderr = os.Stderr
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute git log: %v", err)
+	return commits, nil
+}
+
+// parseCommitRecord parses a single record produced by logFormat: the header
+// fields, the free-form body, and the trailing --raw/--numstat section.
+func parseCommitRecord(record string, showFileChanges bool) (Commit, error) {
+	parts := strings.SplitN(record, fieldSeparator, len(logFields)+1)
+	if len(parts) <= len(logFields) {
+		return Commit{}, fmt.Errorf("malformed git log record: expected %d fields, got %d", len(logFields), len(parts)-1)
 	}
 
-	commits, err := parseGitLog(string(output), options.ShowFileChanges)
-	if err != nil {
-		return nil, err
+	authorDate, _ := time.Parse(time.RFC3339, parts[4])
+	commitDate, _ := time.Parse(time.RFC3339, parts[7])
+
+	commit := Commit{
+		Hash:           parts[0],
+		ShortHash:      parts[1],
+		AuthorName:     parts[2],
+		AuthorEmail:    parts[3],
+		AuthorDate:     authorDate,
+		Committer:      parts[5],
+		CommitterEmail: parts[6],
+		CommitDate:     commitDate,
+		Message:        strings.TrimSpace(parts[8]),
+		Body:           strings.TrimSpace(parts[9]),
+		ParentHashes:   strings.Fields(parts[10]),
+		RefNames:       parseRefNames(parts[11]),
 	}
+	commit.Trailers = ParseTrailers(commit.Body)
 
-	// Get detailed diff stats for each commit if requested
-	if options.ShowFileChanges {
-		for i := range commits {
-			detailedStats, err := getDetailedDiffStats(commits[i].Hash)
-			if err == nil {
-				commits[i].FileChanges = detailedStats
-			}
-		}
+	changes, stats := parseDiffSection(parts[12])
+	commit.Stats = stats
+	if showFileChanges {
+		commit.FileChanges = changes
 	}
 
-	return commits, nil
+	return commit, nil
 }
 
-func parseGitLog(output string, showFileChanges bool) ([]Commit, error) {
-	var commits []Commit
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	
-	var currentCommit *Commit
-	var statLines []string
-	var nameStatusLines []string
-	var inStats bool
-	var inNameStatus bool
-	var bodyLines []string
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		
-		// Check if this is a commit header line
-		if strings.Count(line, "|") >= 10 {
-			// Save previous commit if exists
-			if currentCommit != nil {
-				// Process stats for previous commit
-				if len(statLines) > 0 {
-					stats := parseStats(statLines)
-					currentCommit.Stats = stats
-				}
-				// Parse name-status lines
-				if len(nameStatusLines) > 0 && showFileChanges {
-					currentCommit.FileChanges = parseNameStatus(nameStatusLines)
-				}
-				// Join body lines
-				if len(bodyLines) > 0 {
-					currentCommit.Body = strings.Join(bodyLines, "\n")
-				}
-				commits = append(commits, *currentCommit)
-			}
-			
-			// Parse new commit
-			parts := strings.SplitN(line, "|", 11)
-			
-			authorDate, _ := time.Parse(time.RFC3339, parts[4])
-			commitDate, _ := time.Parse(time.RFC3339, parts[6])
-			
-			currentCommit = &Commit{
-				Hash:         parts[0],
-				ShortHash:    parts[1],
-				AuthorName:   parts[2],
-				AuthorEmail:  parts[3],
-				AuthorDate:   authorDate,
-				Committer:    parts[5],
-				CommitDate:   commitDate,
-				Message:      strings.TrimSpace(parts[7]),
-				Body:         "",
-				ParentHashes: strings.Fields(parts[9]),
-				RefNames:     parseRefNames(parts[10]),
-			}
-			
-			// Reset for new commit
-			bodyLines = []string{}
-			statLines = []string{}
-			nameStatusLines = []string{}
-			inStats = false
-			inNameStatus = false
-			
-			// Add initial body if present
-			if parts[8] != "" {
-				bodyLines = append(bodyLines, strings.TrimSpace(parts[8]))
-			}
-			
-		} else if line == "" {
-			// Empty line could be end of body or start of stats/name-status
-			if !inStats && len(bodyLines) > 0 {
-				// This empty line might separate body from stats
-				inStats = true
-				if showFileChanges {
-					inNameStatus = true
-				}
-			}
-		} else if inNameStatus && !strings.Contains(line, "|") && 
-		          (strings.Contains(line, "files changed") || 
-		           strings.Contains(line, "insertion") || 
-		           strings.Contains(line, "deletion")) {
-			// This is a stat summary line, not a name-status line
-			statLines = append(statLines, line)
-			inNameStatus = false
-		} else if inNameStatus && len(strings.Fields(line)) >= 2 {
-			// Collect name-status lines (format: "M\tfile.go" or "R100\told.txt\tnew.txt")
-			nameStatusLines = append(nameStatusLines, line)
-		} else if inStats {
-			// Collect stat lines
-			statLines = append(statLines, line)
-		} else if currentCommit != nil {
-			// This is part of the commit body
-			bodyLines = append(bodyLines, line)
+// numStat is one --numstat entry.
+type numStat struct {
+	path       string
+	insertions int
+	deletions  int
+	binary     bool
+}
+
+// parseDiffSection reads the -z output of --raw --numstat that follows a
+// commit header. Raw entries (":100644 100644 abc def R087\0old\0new\0")
+// carry the file status; numstat entries ("3\t1\tpath\0", or
+// "3\t1\t\0old\0new\0" for renames and copies) carry the line counts. Both
+// list files in the same order.
+func parseDiffSection(section string) ([]FileChange, *CommitStats) {
+	tokens := strings.Split(section, fieldSeparator)
+	next := func(i *int) string {
+		if *i >= len(tokens) {
+			return ""
 		}
+		token := tokens[*i]
+		*i++
+		return token
 	}
-	
-	// Don't forget the last commit
-	if currentCommit != nil {
-		if len(statLines) > 0 {
-			stats := parseStats(statLines)
-			currentCommit.Stats = stats
+
+	var changes []FileChange
+	var counts []numStat
+	for i := 0; i < len(tokens); {
+		token := strings.TrimLeft(next(&i), "\n")
+		if token == "" {
+			continue
+		}
+
+		if token[0] == ':' {
+			meta := strings.Fields(token)
+			status := meta[len(meta)-1]
+			change := FileChange{Status: getStatusSymbol(status)}
+			if status[0] == 'R' || status[0] == 'C' {
+				change.Similarity, _ = strconv.Atoi(status[1:])
+				change.OldPath = next(&i)
+			}
+			change.FilePath = next(&i)
+			changes = append(changes, change)
+			continue
 		}
-		if len(nameStatusLines) > 0 && showFileChanges {
-			currentCommit.FileChanges = parseNameStatus(nameStatusLines)
+
+		fields := strings.SplitN(token, "\t", 3)
+		if len(fields) < 3 {
+			continue
 		}
-		if len(bodyLines) > 0 {
-			currentCommit.Body = strings.Join(bodyLines, "\n")
+		count := numStat{path: fields[2], binary: fields[0] == "-"}
+		count.insertions, _ = strconv.Atoi(fields[0]) // "-" for binary files
+		count.deletions, _ = strconv.Atoi(fields[1])
+		if count.path == "" {
+			next(&i) // Source path of a rename or copy
+			count.path = next(&i)
 		}
-		commits = append(commits, *currentCommit)
+		counts = append(counts, count)
 	}
-	
-	return commits, scanner.Err()
-}
 
-func parseNameStatus(lines []string) []FileChange {
-	var changes []FileChange
-	
-	for _, line := range lines {
-		fields := strings.Fields(line)
-		if len(fields) < 2 {
-			continue
+	stats := &CommitStats{FilesChanged: len(counts)}
+	for _, count := range counts {
+		stats.Insertions += count.insertions
+		stats.Deletions += count.deletions
+	}
+
+	// Pair counts with statuses by position, falling back to the path if
+	// the two lists ever disagree.
+	aligned := len(counts) == len(changes)
+	byPath := make(map[string]numStat, len(counts))
+	for i, count := range counts {
+		if aligned && counts[i].path != changes[i].FilePath {
+			aligned = false
 		}
-		
-		status := fields[0]
-		change := FileChange{Status: getStatusSymbol(status)}
-		
-		switch {
-		case status[0] == 'R' || status[0] == 'C': // Renamed or Copied
-			if len(fields) >= 3 {
-				change.OldPath = fields[1]
-				change.FilePath = fields[2]
-			}
-		default: // Added, Modified, Deleted
-			change.FilePath = fields[1]
+		byPath[count.path] = count
+	}
+	for i := range changes {
+		count := byPath[changes[i].FilePath]
+		if aligned {
+			count = counts[i]
 		}
-		
-		changes = append(changes, change)
+		changes[i].Insertions = count.insertions
+		changes[i].Deletions = count.deletions
+		changes[i].Binary = count.binary
 	}
-	
-	return changes
+
+	return changes, stats
 }
 
 func getStatusSymbol(status string) string {
@@ -268,61 +267,12 @@ func getStatusSymbol(status string) string {
 	}
 }
 
-func getDetailedDiffStats(hash string) ([]FileChange, error) {
-	// Use git show with --numstat for detailed per-file stats
-	cmd := exec.Command("git", "show", "--numstat", "--pretty=format:", hash)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
-	
-	return parseNumStatOutput(string(output)), nil
-}
-
-func parseNumStatOutput(output string) []FileChange {
-	var changes []FileChange
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-		
-		fields := strings.Fields(line)
-		if len(fields) >= 3 {
-			var insertions, deletions int
-			fmt.Sscanf(fields[0], "%d", &insertions)
-			fmt.Sscanf(fields[1], "%d", &deletions)
-			
-			// Determine status based on insertions/deletions
-			status := "Modified"
-			if insertions > 0 && deletions == 0 {
-				status = "Added"
-			} else if insertions == 0 && deletions > 0 {
-				status = "Deleted"
-			}
-			
-			change := FileChange{
-				Status:     status,
-				FilePath:   fields[2],
-				Insertions: insertions,
-				Deletions:  deletions,
-			}
-			
-			changes = append(changes, change)
-		}
-	}
-	
-	return changes
-}
-
 func parseRefNames(refStr string) []string {
 	var refs []string
 	if refStr == "" {
 		return refs
 	}
-	
+
 	// Split by comma and trim spaces
 	for _, ref := range strings.Split(refStr, ",") {
 		ref = strings.TrimSpace(ref)
@@ -333,30 +283,59 @@ func parseRefNames(refStr string) []string {
 	return refs
 }
 
-func parseStats(statLines []string) *CommitStats {
-	stats := &CommitStats{}
-	
-	// Look for the summary line
-	for _, line := range statLines {
-		if strings.Contains(line, "files changed") || 
-		   strings.Contains(line, "insertion") || 
-		   strings.Contains(line, "deletion") {
-			// Parse summary line like: "2 files changed, 15 insertions(+), 3 deletions(-)"
-			var files, insertions, deletions int
-			n, _ := fmt.Sscanf(line, "%d files changed, %d insertions(+), %d deletions(-)", 
-				&files, &insertions, &deletions)
-			if n >= 1 {
-				stats.FilesChanged = files
-			}
-			if n >= 2 {
-				stats.Insertions = insertions
-			}
-			if n >= 3 {
-				stats.Deletions = deletions
-			}
-			break
+// relativeDateUnits are the units parseDateOption accepts in "N units ago".
+var relativeDateUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"month":  30 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// parseDateOption interprets --since/--until values for backends that do
+// not hand them to git: absolute dates and the common "2 weeks ago" form.
+func parseDateOption(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
 		}
 	}
-	
-	return stats
-}
\ No newline at end of file
+
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		unit, ok := relativeDateUnits[strings.TrimSuffix(fields[1], "s")]
+		if err == nil && ok {
+			return time.Now().Add(-time.Duration(n) * unit), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse date %q", value)
+}
+
+// parseUnixWithZone turns a timestamp and a "+hhmm" offset, as written in
+// commit objects, into a time in that zone. Zones are built the same way
+// time.Parse builds them for --date=iso-strict output.
+func parseUnixWithZone(seconds int64, tz string) time.Time {
+	t := time.Unix(seconds, 0)
+	if len(tz) != 5 {
+		return t
+	}
+	hours, err1 := strconv.Atoi(tz[1:3])
+	minutes, err2 := strconv.Atoi(tz[3:5])
+	if err1 != nil || err2 != nil {
+		return t
+	}
+	offset := hours*3600 + minutes*60
+	if tz[0] == '-' {
+		offset = -offset
+	}
+	if offset == 0 {
+		return t.UTC()
+	}
+	return t.In(time.FixedZone("", offset))
+}
@@ -0,0 +1,199 @@
+package git
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logRecord builds a record the way logFormat has git print it, without the
+// leading record separator, followed by diff as --raw --numstat -z print it.
+func logRecord(author, subject, body, diff string) string {
+	return strings.Join([]string{
+		"0123456789abcdef0123456789abcdef01234567", "0123456",
+		author, "author@example.com", "2024-03-01T10:00:00+01:00",
+		"Committer", "committer@example.com", "2024-03-02T09:30:00Z",
+		subject, body,
+		"1111111111111111111111111111111111111111 2222222222222222222222222222222222222222",
+		"HEAD -> main, tag: v1.0.0",
+	}, fieldSeparator) + fieldSeparator + diff
+}
+
+func TestParseCommitRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		record  string
+		author  string
+		message string
+		body    string
+		changes []FileChange
+		stats   CommitStats
+	}{
+		{
+			name:    "pipes in subject and body",
+			record:  logRecord("Ann", "fix: a | b || c", "cmd | grep x\n|leading pipe|", ""),
+			author:  "Ann",
+			message: "fix: a | b || c",
+			body:    "cmd | grep x\n|leading pipe|",
+		},
+		{
+			name:    "blank lines in body",
+			record:  logRecord("Ann", "Subject", "\nfirst paragraph\n\n\nsecond paragraph\n\n", ""),
+			author:  "Ann",
+			message: "Subject",
+			body:    "first paragraph\n\n\nsecond paragraph",
+		},
+		{
+			name:    "body that looks like a header",
+			record:  logRecord("Ann", "Subject", "abc\x01def\n:100644 100644 abc def M\tfile", ""),
+			author:  "Ann",
+			message: "Subject",
+			body:    "abc\x01def\n:100644 100644 abc def M\tfile",
+		},
+		{
+			name:    "unicode",
+			record:  logRecord("Zoë Ünïcødé 山田", "修复 bug 🐛 — naïve café", "Ωμέγα\n\nçà et là", ""),
+			author:  "Zoë Ünïcødé 山田",
+			message: "修复 bug 🐛 — naïve café",
+			body:    "Ωμέγα\n\nçà et là",
+		},
+		{
+			name:    "empty subject and body",
+			record:  logRecord("Ann", "", "", ""),
+			author:  "Ann",
+			message: "",
+			body:    "",
+		},
+		{
+			name: "renames and copies with spaces",
+			record: logRecord("Ann", "Move", "",
+				"\n:100644 100644 bdc955b 4710bcb R087\x00my old file.txt\x00my new file.txt\x00"+
+					":100644 100644 9405325 9405325 C100\x00src/a b.go\x00src/c d.go\x00"+
+					"3\t1\t\x00my old file.txt\x00my new file.txt\x00"+
+					"0\t0\t\x00src/a b.go\x00src/c d.go\x00"),
+			author:  "Ann",
+			message: "Move",
+			changes: []FileChange{
+				{Status: "Renamed", FilePath: "my new file.txt", OldPath: "my old file.txt", Similarity: 87, Insertions: 3, Deletions: 1},
+				{Status: "Copied", FilePath: "src/c d.go", OldPath: "src/a b.go", Similarity: 100},
+			},
+			stats: CommitStats{FilesChanged: 2, Insertions: 3, Deletions: 1},
+		},
+		{
+			name: "binary numstat",
+			record: logRecord("Ann", "Images", "",
+				"\n:000000 100644 0000000 4710bcb A\x00logo.png\x00"+
+					":100644 100644 bdc955b 4710bcb M\x00main.go\x00"+
+					"-\t-\tlogo.png\x00"+
+					"12\t4\tmain.go\x00"),
+			author:  "Ann",
+			message: "Images",
+			changes: []FileChange{
+				{Status: "Added", FilePath: "logo.png", Binary: true},
+				{Status: "Modified", FilePath: "main.go", Insertions: 12, Deletions: 4},
+			},
+			stats: CommitStats{FilesChanged: 2, Insertions: 12, Deletions: 4},
+		},
+		{
+			name: "paths with tabs and newlines",
+			record: logRecord("Ann", "Odd names", "",
+				"\n:100644 100644 bdc955b 4710bcb M\x00tab\there\x00"+
+					":000000 100644 0000000 4710bcb A\x00new\nline\x00"+
+					"1\t1\ttab\there\x00"+
+					"2\t0\tnew\nline\x00"),
+			author:  "Ann",
+			message: "Odd names",
+			changes: []FileChange{
+				{Status: "Modified", FilePath: "tab\there", Insertions: 1, Deletions: 1},
+				{Status: "Added", FilePath: "new\nline", Insertions: 2},
+			},
+			stats: CommitStats{FilesChanged: 2, Insertions: 3, Deletions: 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			commit, err := parseCommitRecord(test.record, true)
+			if err != nil {
+				t.Fatalf("parseCommitRecord: %v", err)
+			}
+			if commit.AuthorName != test.author {
+				t.Errorf("AuthorName = %q, want %q", commit.AuthorName, test.author)
+			}
+			if commit.Message != test.message {
+				t.Errorf("Message = %q, want %q", commit.Message, test.message)
+			}
+			if commit.Body != test.body {
+				t.Errorf("Body = %q, want %q", commit.Body, test.body)
+			}
+			if !reflect.DeepEqual(commit.FileChanges, test.changes) {
+				t.Errorf("FileChanges = %+v, want %+v", commit.FileChanges, test.changes)
+			}
+			if commit.Stats == nil || *commit.Stats != test.stats {
+				t.Errorf("Stats = %+v, want %+v", commit.Stats, test.stats)
+			}
+
+			// The header fields around the free-form ones stay in place.
+			if commit.Hash != "0123456789abcdef0123456789abcdef01234567" || commit.ShortHash != "0123456" {
+				t.Errorf("Hash, ShortHash = %q, %q", commit.Hash, commit.ShortHash)
+			}
+			if len(commit.ParentHashes) != 2 {
+				t.Errorf("ParentHashes = %q, want 2", commit.ParentHashes)
+			}
+			if want := []string{"HEAD -> main", "tag: v1.0.0"}; !reflect.DeepEqual(commit.RefNames, want) {
+				t.Errorf("RefNames = %q, want %q", commit.RefNames, want)
+			}
+			if want := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC); !commit.AuthorDate.Equal(want) {
+				t.Errorf("AuthorDate = %v, want %v", commit.AuthorDate, want)
+			}
+			if _, offset := commit.AuthorDate.Zone(); offset != 3600 {
+				t.Errorf("AuthorDate offset = %d, want 3600", offset)
+			}
+		})
+	}
+}
+
+func TestParseCommitRecordMalformed(t *testing.T) {
+	full := logRecord("Ann", "Subject", "Body", "")
+	// Drop the last header field and its NUL, as a truncated record would.
+	truncated := full[:strings.LastIndex(strings.TrimSuffix(full, fieldSeparator), fieldSeparator)+1]
+
+	for name, record := range map[string]string{
+		"missing field": truncated,
+		"no separators": "0123456789abcdef0123456789abcdef01234567 just text",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseCommitRecord(record, true)
+			if err == nil || !strings.Contains(err.Error(), "malformed git log record") {
+				t.Fatalf("err = %v, want a malformed git log record error", err)
+			}
+		})
+	}
+}
+
+func TestParseGitLog(t *testing.T) {
+	output := recordSeparator + logRecord("Ann", "First | one", "\n\nbody\n\n", "\n5\t2\ta.txt\x00") +
+		recordSeparator + logRecord("Bob", "Second", "", "") + "\n"
+
+	commits, err := parseGitLog(output, false)
+	if err != nil {
+		t.Fatalf("parseGitLog: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("got %d commits, want 2", len(commits))
+	}
+	if commits[0].Message != "First | one" || commits[0].Body != "body" || commits[1].AuthorName != "Bob" {
+		t.Errorf("commits = %+v", commits)
+	}
+	if want := (CommitStats{FilesChanged: 1, Insertions: 5, Deletions: 2}); *commits[0].Stats != want {
+		t.Errorf("Stats = %+v, want %+v", *commits[0].Stats, want)
+	}
+	if commits[0].FileChanges != nil {
+		t.Errorf("FileChanges = %+v without showFileChanges", commits[0].FileChanges)
+	}
+
+	if _, err := parseGitLog(recordSeparator+"broken\x00record", false); err == nil {
+		t.Error("parseGitLog accepted a malformed record")
+	}
+}
@@ -0,0 +1,281 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// IdentityMap rewrites the names and emails people committed under to
+// canonical ones. It is read from a .mailmap file or an identity map.
+type IdentityMap struct {
+	byNameEmail map[string]Person
+	byEmail     map[string]Person
+	byName      map[string]Person
+}
+
+func newIdentityMap() *IdentityMap {
+	return &IdentityMap{
+		byNameEmail: make(map[string]Person),
+		byEmail:     make(map[string]Person),
+		byName:      make(map[string]Person),
+	}
+}
+
+// Canonical returns who p is, looked up by name and email, then by email
+// alone, then by name alone. A mapping may replace only the name or only
+// the email; unknown people are returned unchanged.
+func (m *IdentityMap) Canonical(p Person) Person {
+	target, ok := m.byNameEmail[identityKey(p.Name)+"\x00"+identityKey(p.Email)]
+	if !ok {
+		target, ok = m.byEmail[identityKey(p.Email)]
+	}
+	if !ok && p.Name != "" {
+		target, ok = m.byName[identityKey(p.Name)]
+	}
+	if !ok {
+		return p
+	}
+	if target.Name != "" {
+		p.Name = target.Name
+	}
+	if target.Email != "" {
+		p.Email = target.Email
+	}
+	return p
+}
+
+// Names and emails are matched without regard to case, as git does.
+func identityKey(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// ParseMailmap reads git's .mailmap format. Each line maps the identity
+// on its right to the one on its left:
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+func ParseMailmap(r io.Reader) (*IdentityMap, error) {
+	m := newIdentityMap()
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		people, err := splitPeople(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		switch len(people) {
+		case 0:
+		case 1:
+			m.byEmail[identityKey(people[0].Email)] = Person{Name: people[0].Name}
+		case 2:
+			proper, commit := people[0], people[1]
+			if commit.Name != "" {
+				m.byNameEmail[identityKey(commit.Name)+"\x00"+identityKey(commit.Email)] = proper
+			} else {
+				m.byEmail[identityKey(commit.Email)] = proper
+			}
+		default:
+			return nil, fmt.Errorf("line %d: more than two emails", line)
+		}
+	}
+	return m, scanner.Err()
+}
+
+// splitPeople reads "Name <email>" pairs, each name optional.
+func splitPeople(text string) ([]Person, error) {
+	var people []Person
+	for {
+		open := strings.IndexByte(text, '<')
+		if open < 0 {
+			if strings.TrimSpace(text) != "" {
+				return nil, fmt.Errorf("%q has no email", strings.TrimSpace(text))
+			}
+			return people, nil
+		}
+		closing := strings.IndexByte(text[open:], '>')
+		if closing < 0 {
+			return nil, fmt.Errorf("unterminated email in %q", strings.TrimSpace(text))
+		}
+		people = append(people, Person{
+			Name:  strings.TrimSpace(text[:open]),
+			Email: strings.TrimSpace(text[open+1 : open+closing]),
+		})
+		text = text[open+closing+1:]
+	}
+}
+
+// ParseIdentityMap reads an identity map: one person per line, their
+// canonical identity, "=", and a comma-separated list of aliases. An
+// alias is "<email>", "Name <email>" or a bare name, so people can be
+// merged even across emails no .mailmap line names.
+//
+//	Jane Doe <jane@example.com> = <jane@work.example>, jdoe, J. Doe <jd@old.example>
+func ParseIdentityMap(r io.Reader) (*IdentityMap, error) {
+	m := newIdentityMap()
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		left, right, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"Name <email> = alias, ...\"", line)
+		}
+		canonical := ParsePerson(left)
+		if canonical.Name == "" {
+			return nil, fmt.Errorf("line %d: canonical identity has no name", line)
+		}
+		for _, alias := range strings.Split(right, ",") {
+			person := ParsePerson(alias)
+			switch {
+			case person.Email != "" && person.Name != "":
+				m.byNameEmail[identityKey(person.Name)+"\x00"+identityKey(person.Email)] = canonical
+			case person.Email != "":
+				m.byEmail[identityKey(person.Email)] = canonical
+			case person.Name != "":
+				m.byName[identityKey(person.Name)] = canonical
+			}
+		}
+	}
+	return m, scanner.Err()
+}
+
+// LoadIdentityMap reads a file with parse, or returns nil when the file
+// does not exist.
+func LoadIdentityMap(path string, parse func(io.Reader) (*IdentityMap, error)) (*IdentityMap, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	m, err := parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return m, nil
+}
+
+// Identities applies identity maps one after the other, so an identity
+// map can merge the canonical identities a .mailmap produces.
+type Identities []*IdentityMap
+
+// Canonical returns who p is after every map.
+func (ids Identities) Canonical(p Person) Person {
+	for _, m := range ids {
+		p = m.Canonical(p)
+	}
+	return p
+}
+
+// Apply rewrites the commit's author, committer and Co-authored-by
+// trailers to their canonical identities.
+func (ids Identities) Apply(commit *Commit) {
+	author := ids.Canonical(Person{Name: commit.AuthorName, Email: commit.AuthorEmail})
+	commit.AuthorName, commit.AuthorEmail = author.Name, author.Email
+	committer := ids.Canonical(Person{Name: commit.Committer, Email: commit.CommitterEmail})
+	commit.Committer, commit.CommitterEmail = committer.Name, committer.Email
+
+	if len(commit.Trailers) == 0 {
+		return
+	}
+	trailers := make([]Trailer, len(commit.Trailers))
+	for i, trailer := range commit.Trailers {
+		if strings.EqualFold(trailer.Key, CoAuthorKey) {
+			trailer.Value = ids.Canonical(ParsePerson(trailer.Value)).String()
+		}
+		trailers[i] = trailer
+	}
+	commit.Trailers = trailers
+}
+
+// WithIdentities returns repo with every author rewritten by ids. The
+// --author filter then matches canonical identities, so it is applied
+// here rather than by the backend, together with the limit, on a stream
+// that stops once enough commits matched.
+func WithIdentities(repo Repository, ids Identities) Repository {
+	if len(ids) == 0 {
+		return repo
+	}
+	return &identityRepository{Repository: repo, ids: ids}
+}
+
+type identityRepository struct {
+	Repository
+	ids Identities
+}
+
+func (r *identityRepository) Log(options CommitOptions) ([]Commit, error) {
+	if options.Author != "" {
+		// Streamed, so history is read only until the limit is met.
+		it, err := r.Stream(context.Background(), options)
+		if err != nil {
+			return nil, err
+		}
+		return Collect(it)
+	}
+	commits, err := r.Repository.Log(options)
+	for i := range commits {
+		r.ids.Apply(&commits[i])
+	}
+	return commits, err
+}
+
+func (r *identityRepository) Stream(ctx context.Context, options CommitOptions) (CommitIterator, error) {
+	pattern, err := authorPattern(options)
+	if err != nil {
+		return nil, err
+	}
+	inner := options
+	if pattern != nil {
+		inner.Author = ""
+		inner.Limit = 0
+	}
+	it, err := r.Repository.Stream(ctx, inner)
+	if err != nil {
+		return nil, err
+	}
+	return &filterIterator{
+		CommitIterator: &identityIterator{CommitIterator: it, ids: r.ids},
+		keep:           func(commit Commit) bool { return pattern == nil || matchesAuthor(pattern, commit) },
+		limit:          options.Limit,
+	}, nil
+}
+
+func (r *identityRepository) Show(rev string) (*Commit, error) {
+	commit, err := r.Repository.Show(rev)
+	if err == nil {
+		r.ids.Apply(commit)
+	}
+	return commit, err
+}
+
+func (r *identityRepository) Blame(path, rev string) ([]BlameLine, error) {
+	lines, err := r.Repository.Blame(path, rev)
+	for i, line := range lines {
+		author := r.ids.Canonical(Person{Name: line.AuthorName, Email: line.AuthorEmail})
+		lines[i].AuthorName, lines[i].AuthorEmail = author.Name, author.Email
+	}
+	return lines, err
+}
+
+// identityIterator rewrites the authors of the commits it yields.
+type identityIterator struct {
+	CommitIterator
+	ids Identities
+}
+
+func (it *identityIterator) Commit() Commit {
+	commit := it.CommitIterator.Commit()
+	it.ids.Apply(&commit)
+	return commit
+}
@@ -0,0 +1,158 @@
+package git
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMailmap = `# People and the identities they committed under
+Jane Doe <jane@example.com>
+<bob@example.com> <bob@laptop.local>
+Cy Young <cy@example.com> <cyoung@old.example>
+Cy Young <cy@example.com> cy <CY@Build.Example>
+`
+
+func TestParseMailmap(t *testing.T) {
+	m, err := ParseMailmap(strings.NewReader(testMailmap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in, want Person
+	}{
+		// A name for an email.
+		{Person{"jdoe", "jane@example.com"}, Person{"Jane Doe", "jane@example.com"}},
+		// An email for an email, keeping the name.
+		{Person{"Bob", "bob@laptop.local"}, Person{"Bob", "bob@example.com"}},
+		{Person{"Cy", "cyoung@old.example"}, Person{"Cy Young", "cy@example.com"}},
+		// Name and email together, either without regard to case.
+		{Person{"CY", "cy@build.example"}, Person{"Cy Young", "cy@example.com"}},
+		{Person{"Someone else", "cy@build.example"}, Person{"Someone else", "cy@build.example"}},
+		{Person{"Ann", "ann@example.com"}, Person{"Ann", "ann@example.com"}},
+	}
+	for _, test := range tests {
+		if got := m.Canonical(test.in); got != test.want {
+			t.Errorf("Canonical(%v) = %v, want %v", test.in, got, test.want)
+		}
+	}
+
+	for _, bad := range []string{"Jane Doe\n", "Jane <jane@example.com\n", "<a@x> <b@x> <c@x>\n"} {
+		if _, err := ParseMailmap(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseMailmap(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	mailmap, err := ParseMailmap(strings.NewReader(testMailmap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The identity map merges what the mailmap leaves apart.
+	aliases, err := ParseIdentityMap(strings.NewReader("Jane Doe <jane@example.com> = <jane@work.example>, J. Doe\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := Identities{mailmap, aliases}
+
+	commit := Commit{
+		AuthorName: "jd", AuthorEmail: "jane@work.example",
+		Committer: "Cy", CommitterEmail: "cyoung@old.example",
+		Trailers: []Trailer{
+			{Key: "co-authored-by", Value: "J. Doe <j@home.example>"},
+			{Key: "Signed-off-by", Value: "jd <jane@work.example>"},
+		},
+	}
+	ids.Apply(&commit)
+	if commit.AuthorName != "Jane Doe" || commit.AuthorEmail != "jane@example.com" {
+		t.Errorf("author = %s <%s>", commit.AuthorName, commit.AuthorEmail)
+	}
+	if commit.Committer != "Cy Young" || commit.CommitterEmail != "cy@example.com" {
+		t.Errorf("committer = %s <%s>", commit.Committer, commit.CommitterEmail)
+	}
+	want := []Trailer{
+		{Key: "co-authored-by", Value: "Jane Doe <jane@example.com>"},
+		{Key: "Signed-off-by", Value: "jd <jane@work.example>"},
+	}
+	if !reflect.DeepEqual(commit.Trailers, want) {
+		t.Errorf("Trailers = %+v, want %+v", commit.Trailers, want)
+	}
+
+	if _, err := ParseIdentityMap(strings.NewReader("<jane@example.com> = jd\n")); err == nil {
+		t.Error("ParseIdentityMap accepted a canonical identity without a name")
+	}
+}
+
+// countingRepository counts the commits read from its streams.
+type countingRepository struct {
+	Repository
+	read int
+}
+
+func (r *countingRepository) Stream(ctx context.Context, options CommitOptions) (CommitIterator, error) {
+	it, err := r.Repository.Stream(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	return &filterIterator{CommitIterator: it, keep: func(Commit) bool { r.read++; return true }}, nil
+}
+
+func TestWithIdentities(t *testing.T) {
+	mailmap, err := ParseMailmap(strings.NewReader(testMailmap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var commits []Commit
+	for i, email := range []string{"jane@example.com", "bob@laptop.local", "jane@example.com", "cyoung@old.example", "jane@example.com", "bob@example.com"} {
+		commits = append(commits, Commit{Hash: string(rune('a' + i)), AuthorName: "jdoe", AuthorEmail: email})
+	}
+	for i := range commits[:len(commits)-1] {
+		commits[i].ParentHashes = []string{commits[i+1].Hash}
+	}
+	inner := &countingRepository{Repository: NewMemoryRepository(commits, nil)}
+	repo := WithIdentities(inner, Identities{mailmap})
+
+	tests := []struct {
+		options CommitOptions
+		want    []string
+		read    int
+	}{
+		// --author matches the canonical name, which no commit has.
+		{CommitOptions{Author: "^Jane Doe <"}, []string{"a", "c", "e"}, 6},
+		{CommitOptions{Author: "cy@example"}, []string{"d"}, 6},
+		// The stream stops at the limit's last match.
+		{CommitOptions{Author: "Jane Doe", Limit: 2}, []string{"a", "c"}, 3},
+		{CommitOptions{Author: "jane doe", IgnoreCase: true, Limit: 1}, []string{"a"}, 1},
+	}
+	for _, test := range tests {
+		inner.read = 0
+		got, err := repo.Log(test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hashes []string
+		for _, commit := range got {
+			hashes = append(hashes, commit.Hash)
+			if commit.AuthorName == "jdoe" {
+				t.Errorf("Log(%+v) left %s's author as %s", test.options, commit.Hash, commit.AuthorName)
+			}
+		}
+		if !reflect.DeepEqual(hashes, test.want) {
+			t.Errorf("Log(%+v) = %q, want %q", test.options, hashes, test.want)
+		}
+		if inner.read != test.read {
+			t.Errorf("Log(%+v) read %d commits, want %d", test.options, inner.read, test.read)
+		}
+	}
+
+	// Without --author the backend's own Log is used, authors rewritten.
+	all, err := repo.Log(CommitOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all[0].AuthorName != "Jane Doe" || all[1].AuthorEmail != "bob@example.com" {
+		t.Errorf("Log without --author = %+v", all)
+	}
+}
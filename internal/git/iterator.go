@@ -0,0 +1,74 @@
+package git
+
+// CommitIterator yields commits one at a time, newest first, as a backend
+// reads them. Callers loop on Next, read Commit, check Err once Next returns
+// false, and always Close the iterator.
+//
+//	it, err := repo.Stream(ctx, options)
+//	if err != nil { ... }
+//	defer it.Close()
+//	for it.Next() {
+//		print(it.Commit())
+//	}
+//	if err := it.Err(); err != nil { ... }
+type CommitIterator interface {
+	Next() bool
+	Commit() Commit
+	Err() error
+	Close() error
+}
+
+// Collect drains an iterator into a slice and closes it.
+func Collect(it CommitIterator) ([]Commit, error) {
+	defer it.Close()
+
+	var commits []Commit
+	for it.Next() {
+		commits = append(commits, it.Commit())
+	}
+	return commits, it.Err()
+}
+
+// sliceIterator walks commits that are already in memory.
+type sliceIterator struct {
+	commits []Commit
+	pos     int
+}
+
+func newSliceIterator(commits []Commit) *sliceIterator {
+	return &sliceIterator{commits: commits, pos: -1}
+}
+
+func (it *sliceIterator) Next() bool {
+	if it.pos+1 >= len(it.commits) {
+		return false
+	}
+	it.pos++
+	return true
+}
+
+func (it *sliceIterator) Commit() Commit { return it.commits[it.pos] }
+func (it *sliceIterator) Err() error     { return nil }
+func (it *sliceIterator) Close() error   { return nil }
+
+// filterIterator yields the commits keep accepts, at most limit of them
+// when limit is positive.
+type filterIterator struct {
+	CommitIterator
+	keep    func(Commit) bool
+	limit   int
+	yielded int
+}
+
+func (it *filterIterator) Next() bool {
+	if it.limit > 0 && it.yielded >= it.limit {
+		return false
+	}
+	for it.CommitIterator.Next() {
+		if it.keep(it.CommitIterator.Commit()) {
+			it.yielded++
+			return true
+		}
+	}
+	return false
+}
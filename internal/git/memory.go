@@ -0,0 +1,346 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// MemoryRepository serves history from commits held in memory. It lets the
+// formatters and the web renderer be exercised without a real repository.
+type MemoryRepository struct {
+	commits []Commit
+	byHash  map[string]int
+	refs    []Ref
+	blame   map[string][]BlameLine
+}
+
+// Fixture is the on-disk form of a MemoryRepository.
+type Fixture struct {
+	Commits []Commit               `json:"commits"`
+	Refs    []Ref                  `json:"refs,omitempty"`
+	Blame   map[string][]BlameLine `json:"blame,omitempty"`
+}
+
+// NewMemoryRepository builds a repository from commits ordered newest first.
+func NewMemoryRepository(commits []Commit, refs []Ref) *MemoryRepository {
+	r := &MemoryRepository{
+		commits: commits,
+		byHash:  make(map[string]int, len(commits)),
+		refs:    refs,
+		blame:   make(map[string][]BlameLine),
+	}
+	for i, commit := range commits {
+		r.byHash[commit.Hash] = i
+		if commit.Trailers == nil {
+			r.commits[i].Trailers = ParseTrailers(commit.Body)
+		}
+	}
+	return r
+}
+
+// LoadFixture reads a JSON fixture written in the Fixture format.
+func LoadFixture(path string) (*MemoryRepository, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture %s: %v", path, err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(content, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %v", path, err)
+	}
+
+	r := NewMemoryRepository(fixture.Commits, fixture.Refs)
+	for path, lines := range fixture.Blame {
+		r.SetBlame(path, lines)
+	}
+	return r, nil
+}
+
+// SetBlame records the blame returned for path, regardless of revision.
+func (r *MemoryRepository) SetBlame(path string, lines []BlameLine) {
+	r.blame[path] = lines
+}
+
+func (r *MemoryRepository) Log(options CommitOptions) ([]Commit, error) {
+	author, err := authorPattern(options)
+	if err != nil {
+		return nil, err
+	}
+	grep, err := GrepPattern(options)
+	if err != nil {
+		return nil, err
+	}
+	if hasPickaxe(options) {
+		return nil, fmt.Errorf("pickaxe search needs file contents, which fixtures do not have")
+	}
+	if err := checkOrder(options); err != nil {
+		return nil, err
+	}
+
+	since, err := parseDateOption(options.Since)
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseDateOption(options.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validatePaths(options); err != nil {
+		return nil, err
+	}
+	var spec *pathspec
+	if hasPaths(options) {
+		if spec, err = newPathspec("", options); err != nil {
+			return nil, err
+		}
+	}
+
+	var reachable map[string]bool
+	if exprs := revisionExprs(options); len(exprs) > 0 || options.FirstParent {
+		if reachable, err = r.selectRange(exprs, options.FirstParent); err != nil {
+			return nil, err
+		}
+	}
+
+	var commits []Commit
+	for _, commit := range r.commits {
+		if options.Limit > 0 && len(commits) >= options.Limit && options.Order == "" {
+			break
+		}
+		if reachable != nil && !reachable[commit.Hash] {
+			continue
+		}
+		if author != nil && !matchesAuthor(author, commit) {
+			continue
+		}
+		if !since.IsZero() && commit.CommitDate.Before(since) {
+			continue
+		}
+		if !until.IsZero() && commit.CommitDate.After(until) {
+			continue
+		}
+		if options.MergesOnly && len(commit.ParentHashes) < 2 {
+			continue
+		}
+		if options.NoMerges && len(commit.ParentHashes) > 1 {
+			continue
+		}
+		if !matchesGrep(grep, commit.Message+"\n\n"+commit.Body, options.InvertGrep) {
+			continue
+		}
+		if spec != nil {
+			// Fixtures carry no trees, so paths are matched against the
+			// recorded file changes.
+			var touched []FileChange
+			for _, change := range commit.FileChanges {
+				if spec.matches(change.FilePath) || change.OldPath != "" && spec.matches(change.OldPath) {
+					touched = append(touched, change)
+				}
+			}
+			if len(touched) == 0 {
+				continue
+			}
+			commit.FileChanges = touched
+		}
+
+		if !options.ShowFileChanges {
+			commit.FileChanges = nil
+		}
+		commits = append(commits, commit)
+	}
+	return limitCommits(sortCommits(commits, options.Order), options.Limit), nil
+}
+
+func (r *MemoryRepository) Stream(ctx context.Context, options CommitOptions) (CommitIterator, error) {
+	commits, err := r.Log(options)
+	if err != nil {
+		return nil, err
+	}
+	return newSliceIterator(commits), nil
+}
+
+func (r *MemoryRepository) Show(rev string) (*Commit, error) {
+	hash, err := r.resolve(rev)
+	if err != nil {
+		return nil, err
+	}
+	commit := r.commits[r.byHash[hash]]
+	return &commit, nil
+}
+
+func (r *MemoryRepository) Refs() ([]Ref, error) {
+	refs := append([]Ref(nil), r.refs...)
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	return refs, nil
+}
+
+// Tags are all lightweight, dated by their commits.
+func (r *MemoryRepository) Tags() ([]Tag, error) {
+	refs, _ := r.Refs()
+	var tags []Tag
+	for _, ref := range refs {
+		name, ok := strings.CutPrefix(ref.Name, "refs/tags/")
+		if !ok {
+			continue
+		}
+		hash, err := r.resolve(ref.Name)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := r.byHash[hash]
+		if !ok {
+			continue
+		}
+		tags = append(tags, Tag{Name: name, Commit: hash, Date: r.commits[i].CommitDate})
+	}
+	return tags, nil
+}
+
+// Diff only knows the changes recorded on each commit, so it can answer for
+// a commit against its first parent.
+func (r *MemoryRepository) Diff(from, to string) ([]FileChange, error) {
+	toHash, err := r.resolve(to)
+	if err != nil {
+		return nil, err
+	}
+	fromHash, err := r.resolve(from)
+	if err != nil {
+		return nil, err
+	}
+
+	commit := r.commits[r.byHash[toHash]]
+	if len(commit.ParentHashes) == 0 || commit.ParentHashes[0] != fromHash {
+		return nil, fmt.Errorf("memory backend can only diff a commit against its first parent")
+	}
+	return commit.FileChanges, nil
+}
+
+func (r *MemoryRepository) Blame(path, rev string) ([]BlameLine, error) {
+	lines, ok := r.blame[path]
+	if !ok {
+		return nil, fmt.Errorf("no blame recorded for %s", path)
+	}
+	return lines, nil
+}
+
+// resolve accepts full or abbreviated hashes, full ref names and short
+// branch or tag names.
+func (r *MemoryRepository) resolve(rev string) (string, error) {
+	if rev == "" || rev == "HEAD" {
+		if len(r.commits) == 0 {
+			return "", fmt.Errorf("repository has no commits")
+		}
+		return r.commits[0].Hash, nil
+	}
+
+	for _, ref := range r.refs {
+		if ref.Name == rev || ref.Name == "refs/heads/"+rev || ref.Name == "refs/tags/"+rev || ref.Name == "refs/remotes/"+rev {
+			if ref.Target != "" {
+				return ref.Target, nil
+			}
+			return ref.Hash, nil
+		}
+	}
+
+	var match string
+	for _, commit := range r.commits {
+		if strings.HasPrefix(commit.Hash, rev) {
+			if match != "" {
+				return "", fmt.Errorf("ambiguous revision %s", rev)
+			}
+			match = commit.Hash
+		}
+	}
+	if match == "" {
+		return "", fmt.Errorf("unknown revision %s", rev)
+	}
+	return match, nil
+}
+
+// selectRange returns the commits revision expressions select.
+func (r *MemoryRepository) selectRange(exprs []string, firstParent bool) (map[string]bool, error) {
+	revs, err := parseRevisions(exprs)
+	if err != nil {
+		return nil, err
+	}
+	reach := func(name string, onlyFirst bool) (map[string]bool, error) {
+		hash, err := r.resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		return r.ancestors(hash, onlyFirst), nil
+	}
+
+	selected := make(map[string]bool)
+	excluded := make(map[string]bool)
+	for _, name := range revs.include {
+		set, err := reach(name, firstParent)
+		if err != nil {
+			return nil, err
+		}
+		for hash := range set {
+			selected[hash] = true
+		}
+	}
+	for _, name := range revs.exclude {
+		set, err := reach(name, false)
+		if err != nil {
+			return nil, err
+		}
+		for hash := range set {
+			excluded[hash] = true
+		}
+	}
+	// A...B: everything reachable from one side only.
+	for _, pair := range revs.symmetric {
+		left, err := reach(pair[0], firstParent)
+		if err != nil {
+			return nil, err
+		}
+		right, err := reach(pair[1], firstParent)
+		if err != nil {
+			return nil, err
+		}
+		for hash := range left {
+			if right[hash] {
+				excluded[hash] = true
+			}
+			selected[hash] = true
+		}
+		for hash := range right {
+			selected[hash] = true
+		}
+	}
+
+	for hash := range excluded {
+		delete(selected, hash)
+	}
+	return selected, nil
+}
+
+func (r *MemoryRepository) ancestors(start string, firstParent bool) map[string]bool {
+	seen := make(map[string]bool)
+	queue := []string{start}
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		if i, ok := r.byHash[hash]; ok {
+			parents := r.commits[i].ParentHashes
+			if firstParent && len(parents) > 1 {
+				parents = parents[:1]
+			}
+			queue = append(queue, parents...)
+		}
+	}
+	return seen
+}
@@ -0,0 +1,84 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func loadHistory(t *testing.T) *MemoryRepository {
+	t.Helper()
+	repo, err := LoadFixture("testdata/history.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func shortHashes(commits []Commit) []string {
+	hashes := make([]string, len(commits))
+	for i, commit := range commits {
+		hashes[i] = commit.ShortHash
+	}
+	return hashes
+}
+
+func TestMemoryRepositoryLog(t *testing.T) {
+	repo := loadHistory(t)
+
+	tests := []struct {
+		name    string
+		options CommitOptions
+		want    []string
+	}{
+		{"all", CommitOptions{}, []string{"f6a1c3e", "c4e8a2f", "7e1a3c5", "9b2d4f6", "2a4c6e8"}},
+		{"limit", CommitOptions{Limit: 2}, []string{"f6a1c3e", "c4e8a2f"}},
+		{"range", CommitOptions{Revisions: []string{"v1.0.0..main"}}, []string{"f6a1c3e", "c4e8a2f", "7e1a3c5"}},
+		{"first parent", CommitOptions{FirstParent: true}, []string{"f6a1c3e", "c4e8a2f", "9b2d4f6", "2a4c6e8"}},
+		{"branch", CommitOptions{Branch: "fix/parser"}, []string{"7e1a3c5", "9b2d4f6", "2a4c6e8"}},
+		// Sam co-authored the newest commit.
+		{"author with co-authors", CommitOptions{Author: "Sam"}, []string{"f6a1c3e", "c4e8a2f", "7e1a3c5"}},
+		{"no merges", CommitOptions{NoMerges: true, Limit: 2}, []string{"f6a1c3e", "7e1a3c5"}},
+		{"merges", CommitOptions{MergesOnly: true}, []string{"c4e8a2f"}},
+		{"grep", CommitOptions{Grep: []string{"^docs"}}, []string{"9b2d4f6"}},
+		{"path", CommitOptions{Paths: []string{"internal/git"}}, []string{"c4e8a2f", "7e1a3c5", "2a4c6e8"}},
+		{"since", CommitOptions{Since: "2024-03-05"}, []string{"f6a1c3e", "c4e8a2f", "7e1a3c5"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			commits, err := repo.Log(test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := shortHashes(commits); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Log = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMemoryRepositoryFixture(t *testing.T) {
+	repo := loadHistory(t)
+
+	commit, err := repo.Show("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commit.Trailers) != 2 || commit.Trailers[0].Key != "Co-authored-by" {
+		t.Errorf("Trailers = %+v, want Co-authored-by and Signed-off-by", commit.Trailers)
+	}
+	if commit.FileChanges[2].OldPath != "internal/web/diff.go" || !commit.FileChanges[1].Binary {
+		t.Errorf("FileChanges = %+v", commit.FileChanges)
+	}
+
+	tags, err := repo.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0].Name != "v1.0.0" || tags[0].Commit != "9b2d4f6a8c0e4137b5d9f1a3c5e7b9d1f3a5c7e9" {
+		t.Errorf("Tags = %+v", tags)
+	}
+
+	if _, err := repo.Show("nope"); err == nil {
+		t.Error("Show found an unknown revision")
+	}
+}
@@ -0,0 +1,1691 @@
+package git
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NativeRepository reads history straight from the object database, without
+// a git binary. It understands loose objects, packfiles, alternates and
+// packed-refs.
+type NativeRepository struct {
+	gitDir    string // Per-worktree directory holding HEAD
+	commonDir string // Directory holding objects and refs
+	odb       *objectDB
+
+	head       string // Branch HEAD points at, empty when detached
+	headHash   string
+	refs       []Ref
+	decoration map[string][]string // Commit hash to %D style ref names
+
+	commits map[string]*rawCommit
+	shallow map[string]bool // Boundary commits of a shallow clone
+
+	prefix string               // Current directory relative to the worktree, for pathspecs
+	specs  map[string]*pathspec // Compiled pathspecs by their options
+
+	Cache CommitCache // Optional; when set cached commits are not re-diffed
+}
+
+// rawCommit is a parsed commit object.
+type rawCommit struct {
+	hash           string
+	tree           string
+	parents        []string
+	authorName     string
+	authorEmail    string
+	authorDate     time.Time
+	committerName  string
+	committerEmail string
+	commitDate     time.Time
+	message        string
+}
+
+// NewNativeRepository opens the repository containing dir.
+func NewNativeRepository(dir string) (*NativeRepository, error) {
+	gitDir, err := findGitDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &NativeRepository{
+		gitDir:    gitDir,
+		commonDir: gitDir,
+		commits:   make(map[string]*rawCommit),
+		prefix:    worktreePrefix(dir),
+		specs:     make(map[string]*pathspec),
+	}
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		r.commonDir = resolveGitPath(gitDir, strings.TrimSpace(string(common)))
+	}
+	if err := checkObjectFormat(r.commonDir); err != nil {
+		return nil, err
+	}
+	if r.shallow, err = readShallow(r.commonDir); err != nil {
+		return nil, err
+	}
+
+	if r.odb, err = openObjectDB(filepath.Join(r.commonDir, "objects")); err != nil {
+		return nil, err
+	}
+	if err := r.loadRefs(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// findGitDir walks up from dir looking for a .git directory or gitfile, or
+// for a bare repository.
+func findGitDir(dir string) (string, error) {
+	if dir == "" {
+		dir = "."
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return dotGit, nil
+			}
+			content, err := os.ReadFile(dotGit)
+			if err != nil {
+				return "", err
+			}
+			if target, ok := strings.CutPrefix(strings.TrimSpace(string(content)), "gitdir: "); ok {
+				return resolveGitPath(dir, target), nil
+			}
+			return "", fmt.Errorf("invalid gitfile %s", dotGit)
+		}
+		if isBareRepository(dir) {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("not a git repository (or any of the parent directories)")
+		}
+		dir = parent
+	}
+}
+
+// worktreePrefix returns dir relative to the top of its worktree, in the
+// slash-separated form used for paths in trees.
+func worktreePrefix(dir string) string {
+	if dir == "" {
+		dir = "."
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for top := dir; ; {
+		if _, err := os.Stat(filepath.Join(top, ".git")); err == nil {
+			rel, err := filepath.Rel(top, dir)
+			if err != nil || rel == "." {
+				return ""
+			}
+			return filepath.ToSlash(rel)
+		}
+		parent := filepath.Dir(top)
+		if parent == top {
+			return ""
+		}
+		top = parent
+	}
+}
+
+func isBareRepository(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func resolveGitPath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+// checkObjectFormat rejects repositories whose config sets
+// extensions.objectFormat to anything but sha1: their objects are named by
+// sha256 hashes, which the object database does not read.
+func checkObjectFormat(commonDir string) error {
+	content, err := os.ReadFile(filepath.Join(commonDir, "config"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	section := ""
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[] \t"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != "extensions" || !strings.EqualFold(strings.TrimSpace(key), "objectformat") {
+			continue
+		}
+		if format := strings.ToLower(strings.TrimSpace(value)); format != "sha1" {
+			return fmt.Errorf("the native backend does not support %s repositories; use --backend exec", format)
+		}
+	}
+	return nil
+}
+
+// readShallow lists the commits in .git/shallow. A shallow clone has their
+// objects but not their parents', so the walk treats them as roots, as git
+// does.
+func readShallow(commonDir string) (map[string]bool, error) {
+	content, err := os.ReadFile(filepath.Join(commonDir, "shallow"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shallow commits: %v", err)
+	}
+	shallow := make(map[string]bool)
+	for _, hash := range strings.Fields(string(content)) {
+		shallow[hash] = true
+	}
+	return shallow, nil
+}
+
+// loadRefs reads packed-refs, then loose refs (which take precedence), then
+// HEAD, and builds the decoration shown next to commits.
+func (r *NativeRepository) loadRefs() error {
+	refs := make(map[string]Ref)
+
+	if packed, err := os.ReadFile(filepath.Join(r.commonDir, "packed-refs")); err == nil {
+		var last string
+		for _, line := range strings.Split(string(packed), "\n") {
+			switch {
+			case line == "" || strings.HasPrefix(line, "#"):
+				continue
+			case strings.HasPrefix(line, "^"):
+				// Peeled value of the preceding annotated tag
+				if ref, ok := refs[last]; ok {
+					ref.Target = line[1:]
+					refs[last] = ref
+				}
+			default:
+				fields := strings.SplitN(line, " ", 2)
+				if len(fields) == 2 {
+					refs[fields[1]] = Ref{Name: fields[1], Hash: fields[0]}
+					last = fields[1]
+				}
+			}
+		}
+	}
+
+	symbolic := make(map[string]string)
+	refsDir := filepath.Join(r.commonDir, "refs")
+	err := filepath.Walk(refsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		value := strings.TrimSpace(string(content))
+		rel, _ := filepath.Rel(r.commonDir, path)
+		name := filepath.ToSlash(rel)
+		if target, ok := strings.CutPrefix(value, "ref: "); ok {
+			symbolic[name] = target // Such as refs/remotes/origin/HEAD
+		} else if len(value) == 40 {
+			refs[name] = Ref{Name: name, Hash: value}
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read refs: %v", err)
+	}
+	// for-each-ref lists symbolic refs under their own name, with the
+	// value of the ref they point to.
+	for name, target := range symbolic {
+		for depth := 0; depth < 5; depth++ {
+			next, ok := symbolic[target]
+			if !ok {
+				break
+			}
+			target = next
+		}
+		if ref, ok := refs[target]; ok {
+			refs[name] = Ref{Name: name, Hash: ref.Hash, Target: ref.Target}
+		}
+	}
+
+	for name, ref := range refs {
+		if ref.Target == "" && strings.HasPrefix(name, "refs/tags/") {
+			if peeled, err := r.peel(ref.Hash); err == nil && peeled != ref.Hash {
+				ref.Target = peeled
+				refs[name] = ref
+			}
+		}
+		r.refs = append(r.refs, ref)
+	}
+	sort.Slice(r.refs, func(i, j int) bool { return r.refs[i].Name < r.refs[j].Name })
+
+	head, err := os.ReadFile(filepath.Join(r.gitDir, "HEAD"))
+	if err != nil {
+		return fmt.Errorf("failed to read HEAD: %v", err)
+	}
+	value := strings.TrimSpace(string(head))
+	if target, ok := strings.CutPrefix(value, "ref: "); ok {
+		r.head = target
+		if ref, ok := refs[target]; ok {
+			r.headHash = ref.Hash
+		}
+	} else {
+		r.headHash = value
+	}
+
+	r.buildDecoration()
+	return nil
+}
+
+// buildDecoration mirrors git's %D: "HEAD -> main", then the other refs in
+// reverse name order, tags before remote and local branches.
+func (r *NativeRepository) buildDecoration() {
+	r.decoration = make(map[string][]string)
+
+	// Shallow boundaries come first, marked the way git marks grafts.
+	for hash := range r.shallow {
+		r.decoration[hash] = []string{"grafted"}
+	}
+	if r.headHash != "" {
+		if r.head != "" {
+			r.decoration[r.headHash] = append(r.decoration[r.headHash], "HEAD -> "+strings.TrimPrefix(r.head, "refs/heads/"))
+		} else {
+			r.decoration[r.headHash] = append(r.decoration[r.headHash], "HEAD")
+		}
+	}
+
+	for i := len(r.refs) - 1; i >= 0; i-- {
+		ref := r.refs[i]
+		var name string
+		switch {
+		case ref.Name == r.head:
+			continue // Already shown as "HEAD -> branch"
+		case strings.HasPrefix(ref.Name, "refs/heads/"):
+			name = strings.TrimPrefix(ref.Name, "refs/heads/")
+		case strings.HasPrefix(ref.Name, "refs/remotes/"):
+			name = strings.TrimPrefix(ref.Name, "refs/remotes/")
+		case strings.HasPrefix(ref.Name, "refs/tags/"):
+			name = "tag: " + strings.TrimPrefix(ref.Name, "refs/tags/")
+		default:
+			continue
+		}
+		hash := ref.Hash
+		if ref.Target != "" {
+			hash = ref.Target
+		}
+		r.decoration[hash] = append(r.decoration[hash], name)
+	}
+}
+
+// peel follows annotated tags until it reaches a non-tag object.
+func (r *NativeRepository) peel(hash string) (string, error) {
+	for depth := 0; depth < 16; depth++ {
+		obj, err := r.odb.read(hash)
+		if err != nil {
+			return "", err
+		}
+		if obj.kind != objTag {
+			return hash, nil
+		}
+		target, ok := headerValue(obj.data, "object")
+		if !ok {
+			return "", fmt.Errorf("tag %s has no object", hash)
+		}
+		hash = target
+	}
+	return "", fmt.Errorf("tag chain too deep at %s", hash)
+}
+
+func headerValue(data []byte, key string) (string, bool) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, key+" "); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+var revSuffix = regexp.MustCompile(`([~^])(\d*)$`)
+
+// resolve turns a revision into a commit hash. It understands HEAD, full and
+// short ref names, full and abbreviated hashes, and ~n / ^n suffixes.
+func (r *NativeRepository) resolve(rev string) (string, error) {
+	if m := revSuffix.FindStringSubmatchIndex(rev); m != nil && m[0] > 0 {
+		base, err := r.resolve(rev[:m[0]])
+		if err != nil {
+			return "", err
+		}
+		n := 1
+		if digits := rev[m[4]:m[5]]; digits != "" {
+			n, _ = strconv.Atoi(digits)
+		}
+		return r.walkSuffix(base, rev[m[2]:m[3]], n, rev)
+	}
+
+	if rev == "" || rev == "HEAD" {
+		if r.headHash == "" {
+			return "", fmt.Errorf("HEAD does not point at a commit yet")
+		}
+		return r.headHash, nil
+	}
+
+	for _, candidate := range []string{rev, "refs/" + rev, "refs/tags/" + rev, "refs/heads/" + rev, "refs/remotes/" + rev} {
+		for _, ref := range r.refs {
+			if ref.Name == candidate {
+				return r.peel(ref.Hash)
+			}
+		}
+	}
+
+	hash, err := r.odb.expand(rev)
+	if err != nil {
+		if errors.Is(err, errObjectNotFound) {
+			return "", fmt.Errorf("unknown revision %s", rev)
+		}
+		return "", err
+	}
+	return r.peel(hash)
+}
+
+func (r *NativeRepository) walkSuffix(hash, op string, n int, rev string) (string, error) {
+	if op == "^" {
+		if n == 0 {
+			return hash, nil
+		}
+		commit, err := r.readCommit(hash)
+		if err != nil {
+			return "", err
+		}
+		if n > len(commit.parents) {
+			return "", fmt.Errorf("unknown revision %s", rev)
+		}
+		return commit.parents[n-1], nil
+	}
+
+	for i := 0; i < n; i++ {
+		commit, err := r.readCommit(hash)
+		if err != nil {
+			return "", err
+		}
+		if len(commit.parents) == 0 {
+			return "", fmt.Errorf("unknown revision %s", rev)
+		}
+		hash = commit.parents[0]
+	}
+	return hash, nil
+}
+
+func (r *NativeRepository) readCommit(hash string) (*rawCommit, error) {
+	if commit, ok := r.commits[hash]; ok {
+		return commit, nil
+	}
+	data, err := r.odb.readTyped(hash, objCommit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %v", hash, err)
+	}
+	commit := r.parseCommit(hash, data)
+	r.commits[hash] = commit
+	return commit, nil
+}
+
+// parseCommit parses a commit object read from the repository, cutting
+// off the parents of shallow boundary commits.
+func (r *NativeRepository) parseCommit(hash string, data []byte) *rawCommit {
+	commit := parseCommitObject(hash, data)
+	if r.shallow[hash] {
+		commit.parents = []string{}
+	}
+	return commit
+}
+
+// parseCommitObject reads the headers git writes (tree, parent, author,
+// committer, plus multi-line ones like gpgsig) and the message after them.
+func parseCommitObject(hash string, data []byte) *rawCommit {
+	// Root commits have no parents, which the exec backend reads as an
+	// empty list too.
+	commit := &rawCommit{hash: hash, parents: []string{}}
+
+	headers, message, _ := bytes.Cut(data, []byte("\n\n"))
+	commit.message = string(message)
+
+	for _, line := range strings.Split(string(headers), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			commit.tree = value
+		case "parent":
+			commit.parents = append(commit.parents, value)
+		case "author":
+			commit.authorName, commit.authorEmail, commit.authorDate = parseSignature(value)
+		case "committer":
+			commit.committerName, commit.committerEmail, commit.commitDate = parseSignature(value)
+		}
+	}
+	return commit
+}
+
+// parseSignature splits "Name <email> 1700000000 +0100".
+func parseSignature(value string) (name, email string, when time.Time) {
+	open := strings.LastIndexByte(value, '<')
+	closing := strings.LastIndexByte(value, '>')
+	if open < 0 || closing < open {
+		return strings.TrimSpace(value), "", time.Time{}
+	}
+	name = strings.TrimSpace(value[:open])
+	email = value[open+1 : closing]
+
+	fields := strings.Fields(value[closing+1:])
+	if len(fields) >= 1 {
+		seconds, _ := strconv.ParseInt(fields[0], 10, 64)
+		tz := ""
+		if len(fields) >= 2 {
+			tz = fields[1]
+		}
+		when = parseUnixWithZone(seconds, tz)
+	}
+	return name, email, when
+}
+
+// splitMessage separates the subject (%s: the first paragraph folded onto
+// one line) from the body (%b: everything after it).
+func splitMessage(message string) (subject, body string) {
+	message = strings.TrimLeft(message, "\n")
+	paragraph, rest, _ := strings.Cut(message, "\n\n")
+
+	lines := strings.Split(paragraph, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.TrimSpace(strings.Join(lines, " ")), strings.TrimSpace(rest)
+}
+
+func (r *NativeRepository) toCommit(raw *rawCommit, changes []treeChange, withFiles bool) Commit {
+	subject, body := splitMessage(raw.message)
+	commit := Commit{
+		Hash:           raw.hash,
+		ShortHash:      raw.hash[:7],
+		AuthorName:     raw.authorName,
+		AuthorEmail:    raw.authorEmail,
+		AuthorDate:     raw.authorDate,
+		Committer:      raw.committerName,
+		CommitterEmail: raw.committerEmail,
+		CommitDate:     raw.commitDate,
+		Message:        subject,
+		Body:           body,
+		ParentHashes:   raw.parents,
+		RefNames:       r.decoration[raw.hash],
+		Stats:          &CommitStats{},
+	}
+	commit.Trailers = ParseTrailers(body)
+
+	for _, change := range changes {
+		commit.Stats.FilesChanged++
+		commit.Stats.Insertions += change.insertions
+		commit.Stats.Deletions += change.deletions
+		if withFiles {
+			commit.FileChanges = append(commit.FileChanges, change.fileChange())
+		}
+	}
+	return commit
+}
+
+// commitQueue orders commits newest first by commit date, falling back to
+// insertion order the way git's revision walk does.
+type commitQueue []queuedCommit
+
+type queuedCommit struct {
+	commit *rawCommit
+	seq    int
+}
+
+func (q commitQueue) Len() int { return len(q) }
+func (q commitQueue) Less(i, j int) bool {
+	if !q[i].commit.commitDate.Equal(q[j].commit.commitDate) {
+		return q[i].commit.commitDate.After(q[j].commit.commitDate)
+	}
+	return q[i].seq < q[j].seq
+}
+func (q commitQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x interface{}) { *q = append(*q, x.(queuedCommit)) }
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+func (r *NativeRepository) Log(options CommitOptions) ([]Commit, error) {
+	it, err := r.Stream(context.Background(), options)
+	if err != nil {
+		return nil, err
+	}
+	return Collect(it)
+}
+
+// Stream walks history lazily, reading and diffing one commit per Next.
+// Commits are not kept once yielded, so memory stays bounded by the walk
+// frontier and the object cache.
+func (r *NativeRepository) Stream(ctx context.Context, options CommitOptions) (CommitIterator, error) {
+	if err := validatePaths(options); err != nil {
+		return nil, err
+	}
+	if err := checkOrder(options); err != nil {
+		return nil, err
+	}
+	if options.Order != "" {
+		return r.sortedStream(ctx, options)
+	}
+	return r.walk(ctx, options)
+}
+
+// walk starts a revision walk in commit date order.
+func (r *NativeRepository) walk(ctx context.Context, options CommitOptions) (*nativeIterator, error) {
+	it := &nativeIterator{repo: r, ctx: ctx, options: options, queue: &commitQueue{}}
+
+	var err error
+	if it.author, err = authorPattern(options); err != nil {
+		return nil, err
+	}
+	if it.grep, err = GrepPattern(options); err != nil {
+		return nil, err
+	}
+	if it.pickaxe, err = newPickaxe(options); err != nil {
+		return nil, err
+	}
+	if it.since, err = parseDateOption(options.Since); err != nil {
+		return nil, err
+	}
+	if it.until, err = parseDateOption(options.Until); err != nil {
+		return nil, err
+	}
+
+	include, exclude, err := r.resolveRange(revisionExprs(options))
+	if err != nil {
+		return nil, err
+	}
+	it.seen = make(map[string]bool)
+	it.uninteresting = make(map[string]bool)
+	for _, hash := range exclude {
+		it.uninteresting[hash] = true
+		if err := it.add(hash); err != nil {
+			return nil, err
+		}
+	}
+	for _, hash := range include {
+		if err := it.add(hash); err != nil {
+			return nil, err
+		}
+	}
+	return it, nil
+}
+
+// sortedStream lists commits in options.Order. Holding parents back until
+// their children are listed takes the whole walk, as it does for git
+// without a commit-graph, but the walk reads only commit headers: just the
+// commits left after the limit are diffed.
+func (r *NativeRepository) sortedStream(ctx context.Context, options CommitOptions) (CommitIterator, error) {
+	walk := options
+	walk.Order, walk.Limit = "", 0
+	it, err := r.walk(ctx, walk)
+	if err != nil {
+		return nil, err
+	}
+	// --follow needs each diff to know the name to follow next.
+	it.headersOnly = !options.Follow
+	it.picked = make(map[string]map[string]bool)
+	commits, err := Collect(it)
+	if err != nil {
+		return nil, err
+	}
+	commits = limitCommits(sortCommits(commits, options.Order), options.Limit)
+	if options.Follow {
+		return newSliceIterator(commits), nil
+	}
+	return &diffIterator{repo: r, ctx: ctx, options: options, commits: commits, picked: it.picked}, nil
+}
+
+// resolveRange resolves revision expressions to the commits the walk starts
+// from and the commits whose history it leaves out.
+func (r *NativeRepository) resolveRange(exprs []string) (include, exclude []string, err error) {
+	revs, err := parseRevisions(exprs)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolveAll := func(names []string) ([]string, error) {
+		hashes := make([]string, 0, len(names))
+		for _, name := range names {
+			hash, err := r.resolve(name)
+			if err != nil {
+				return nil, err
+			}
+			hashes = append(hashes, hash)
+		}
+		return hashes, nil
+	}
+	if include, err = resolveAll(revs.include); err != nil {
+		return nil, nil, err
+	}
+	if exclude, err = resolveAll(revs.exclude); err != nil {
+		return nil, nil, err
+	}
+	for _, pair := range revs.symmetric {
+		ends, err := resolveAll(pair[:])
+		if err != nil {
+			return nil, nil, err
+		}
+		bases, err := r.mergeBases(ends[0], ends[1])
+		if err != nil {
+			return nil, nil, err
+		}
+		include = append(include, ends...)
+		exclude = append(exclude, bases...)
+	}
+	return include, exclude, nil
+}
+
+// mergeBases finds the best common ancestors of two commits the way git
+// merge-base --all does: both histories are painted in date order until
+// only commits below a common ancestor are left.
+func (r *NativeRepository) mergeBases(a, b string) ([]string, error) {
+	if a == b {
+		return []string{a}, nil
+	}
+	const (
+		fromA = 1 << iota
+		fromB
+		stale
+	)
+	flags := map[string]int{a: fromA, b: fromB}
+	queue := &commitQueue{}
+	seq := 0
+	push := func(hash string) error {
+		data, err := r.odb.readTyped(hash, objCommit)
+		if err != nil {
+			return fmt.Errorf("failed to read commit %s: %v", hash, err)
+		}
+		heap.Push(queue, queuedCommit{commit: r.parseCommit(hash, data), seq: seq})
+		seq++
+		return nil
+	}
+	if err := push(a); err != nil {
+		return nil, err
+	}
+	if err := push(b); err != nil {
+		return nil, err
+	}
+
+	var bases []string
+	for queue.Len() > 0 {
+		active := false
+		for _, queued := range *queue {
+			if flags[queued.commit.hash]&stale == 0 {
+				active = true
+				break
+			}
+		}
+		if !active {
+			break
+		}
+
+		raw := heap.Pop(queue).(queuedCommit).commit
+		paint := flags[raw.hash] & (fromA | fromB | stale)
+		if paint == fromA|fromB {
+			bases = append(bases, raw.hash)
+			paint |= stale
+		}
+		for _, parent := range raw.parents {
+			if flags[parent]&paint == paint {
+				continue
+			}
+			flags[parent] |= paint
+			if err := push(parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// A base reached from another base is not a best one.
+	best := bases[:0]
+	for _, hash := range bases {
+		if flags[hash]&stale == 0 {
+			best = append(best, hash)
+		}
+	}
+	return best, nil
+}
+
+// nativeIterator is a revision walk in commit date order.
+type nativeIterator struct {
+	repo    *NativeRepository
+	ctx     context.Context
+	options CommitOptions
+	author  *regexp.Regexp
+	grep    *regexp.Regexp
+	pickaxe *pickaxe
+	since   time.Time
+	until   time.Time
+
+	queue         *commitQueue
+	seen          map[string]bool
+	uninteresting map[string]bool // Excluded by the range, with their history
+	seq           int
+	yielded       int
+
+	headersOnly bool                       // Yield commits without diffing them
+	picked      map[string]map[string]bool // Files pickaxe matched, by commit, when headersOnly
+
+	current Commit
+	err     error
+}
+
+// add queues a commit unless the walk has already met it.
+func (it *nativeIterator) add(hash string) error {
+	if it.seen[hash] {
+		return nil
+	}
+	it.seen[hash] = true
+	return it.push(hash)
+}
+
+// done reports whether only excluded commits are left to walk.
+func (it *nativeIterator) done() bool {
+	if len(it.uninteresting) == 0 {
+		return false
+	}
+	for _, queued := range *it.queue {
+		if !it.uninteresting[queued.commit.hash] {
+			return false
+		}
+	}
+	return true
+}
+
+func (it *nativeIterator) push(hash string) error {
+	data, err := it.repo.odb.readTyped(hash, objCommit)
+	if err != nil {
+		return fmt.Errorf("failed to read commit %s: %v", hash, err)
+	}
+	heap.Push(it.queue, queuedCommit{commit: it.repo.parseCommit(hash, data), seq: it.seq})
+	it.seq++
+	return nil
+}
+
+func (it *nativeIterator) Next() bool {
+	for it.err == nil && it.queue.Len() > 0 {
+		if it.options.Limit > 0 && it.yielded >= it.options.Limit {
+			return false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		if it.done() {
+			return false
+		}
+
+		raw := heap.Pop(it.queue).(queuedCommit).commit
+		if it.uninteresting[raw.hash] {
+			for _, parent := range raw.parents {
+				it.uninteresting[parent] = true
+				if it.err = it.add(parent); it.err != nil {
+					return false
+				}
+			}
+			continue
+		}
+
+		parents, shown := raw.parents, true
+		if it.options.FirstParent && len(parents) > 1 {
+			parents = parents[:1]
+		}
+		if hasPaths(it.options) {
+			var simplified []string
+			if simplified, shown, it.err = it.simplify(raw, parents); it.err != nil {
+				return false
+			}
+			// --follow turns simplification off, as in git: the walk
+			// goes on through every parent.
+			if !it.options.Follow {
+				parents = simplified
+			}
+		}
+		for _, parent := range parents {
+			if it.err = it.add(parent); it.err != nil {
+				return false
+			}
+		}
+
+		if !shown || !it.matches(raw) {
+			continue
+		}
+		var picked map[string]bool
+		if it.pickaxe != nil {
+			if picked, it.err = it.repo.pickaxeFiles(raw, it.options, it.pickaxe); it.err != nil {
+				return false
+			}
+			if len(picked) == 0 {
+				continue
+			}
+		}
+
+		if it.headersOnly {
+			it.current = it.repo.toCommit(raw, nil, false)
+			it.picked[raw.hash] = picked
+			it.yielded++
+			return true
+		}
+		commit, err := it.commit(raw)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		markPickaxe(&commit, map[string]map[string]bool{raw.hash: picked})
+		it.current = commit
+		it.yielded++
+		return true
+	}
+	return false
+}
+
+// commit diffs a commit that is being shown. With --follow it also picks
+// up the name the followed file had before a rename or copy.
+func (it *nativeIterator) commit(raw *rawCommit) (Commit, error) {
+	if !it.options.Follow {
+		return it.repo.cachedCommit(raw, it.options)
+	}
+
+	options := it.options
+	options.ShowFileChanges = true
+	commit, err := it.repo.commitWithChanges(raw, options)
+	if err != nil {
+		return Commit{}, err
+	}
+	for _, change := range commit.FileChanges {
+		if change.OldPath != "" {
+			it.options.Paths = []string{":/" + change.OldPath}
+		}
+	}
+	if !it.options.ShowFileChanges {
+		commit.FileChanges = nil
+	}
+	return commit, nil
+}
+
+// simplify applies git's default history simplification to path-limited
+// walks. A commit is shown only when it changes the selected paths compared
+// to every parent; the walk continues through just the first parent that
+// has the same content for them, if there is one.
+func (it *nativeIterator) simplify(raw *rawCommit, parents []string) ([]string, bool, error) {
+	if len(parents) == 0 {
+		touched, err := it.repo.touchesPaths("", raw.tree, it.options)
+		return nil, touched, err
+	}
+	for _, parent := range parents {
+		data, err := it.repo.odb.readTyped(parent, objCommit)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read commit %s: %v", parent, err)
+		}
+		touched, err := it.repo.touchesPaths(parseCommitObject(parent, data).tree, raw.tree, it.options)
+		if err != nil {
+			return nil, false, err
+		}
+		if !touched {
+			return []string{parent}, false, nil
+		}
+	}
+	return parents, true, nil
+}
+
+func (it *nativeIterator) matches(raw *rawCommit) bool {
+	if it.author != nil {
+		_, body := splitMessage(raw.message)
+		commit := Commit{AuthorName: raw.authorName, AuthorEmail: raw.authorEmail, Trailers: ParseTrailers(body)}
+		if !matchesAuthor(it.author, commit) {
+			return false
+		}
+	}
+	if !it.since.IsZero() && raw.commitDate.Before(it.since) {
+		return false
+	}
+	if !it.until.IsZero() && raw.commitDate.After(it.until) {
+		return false
+	}
+	if it.options.MergesOnly && len(raw.parents) < 2 {
+		return false
+	}
+	if it.options.NoMerges && len(raw.parents) > 1 {
+		return false
+	}
+	return matchesGrep(it.grep, raw.message, it.options.InvertGrep)
+}
+
+// pickaxeFiles returns the files of a commit's diff that a -S or -G search
+// matches. Merges have no diff to search unless --first-parent gives them
+// one.
+func (r *NativeRepository) pickaxeFiles(raw *rawCommit, options CommitOptions, p *pickaxe) (map[string]bool, error) {
+	if len(raw.parents) > 1 && !options.FirstParent {
+		return nil, nil
+	}
+	parentTree := ""
+	if len(raw.parents) > 0 {
+		data, err := r.odb.readTyped(raw.parents[0], objCommit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit %s: %v", raw.parents[0], err)
+		}
+		parentTree = parseCommitObject(raw.parents[0], data).tree
+	}
+	changes, err := r.diffTrees(parentTree, raw.tree, options)
+	if err != nil {
+		return nil, err
+	}
+
+	picked := make(map[string]bool)
+	for _, change := range changes {
+		oldContent, err := r.blobContent(change.oldHash, change.oldMode)
+		if err != nil {
+			return nil, err
+		}
+		newContent, err := r.blobContent(change.newHash, change.newMode)
+		if err != nil {
+			return nil, err
+		}
+		if p.matches(oldContent, newContent) {
+			picked[change.path] = true
+		}
+	}
+	return picked, nil
+}
+
+func (it *nativeIterator) Commit() Commit { return it.current }
+func (it *nativeIterator) Err() error     { return it.err }
+func (it *nativeIterator) Close() error   { return nil }
+
+// diffIterator diffs commits a walk listed without their changes, one per
+// Next.
+type diffIterator struct {
+	repo    *NativeRepository
+	ctx     context.Context
+	options CommitOptions
+	commits []Commit
+	picked  map[string]map[string]bool
+
+	current Commit
+	err     error
+}
+
+func (it *diffIterator) Next() bool {
+	if it.err != nil || len(it.commits) == 0 {
+		return false
+	}
+	if it.err = it.ctx.Err(); it.err != nil {
+		return false
+	}
+	raw, err := it.repo.readCommit(it.commits[0].Hash)
+	if err == nil {
+		it.current, err = it.repo.cachedCommit(raw, it.options)
+	}
+	if err != nil {
+		it.err = err
+		return false
+	}
+	markPickaxe(&it.current, it.picked)
+	it.commits = it.commits[1:]
+	return true
+}
+
+func (it *diffIterator) Commit() Commit { return it.current }
+func (it *diffIterator) Err() error     { return it.err }
+func (it *diffIterator) Close() error   { return nil }
+
+// cachedCommit returns the commit from the cache when possible, and diffs
+// and caches it otherwise.
+func (r *NativeRepository) cachedCommit(raw *rawCommit, options CommitOptions) (Commit, error) {
+	// Path limits narrow the diff too, so those commits bypass the cache.
+	if r.Cache == nil || hasPaths(options) {
+		return r.commitWithChanges(raw, options)
+	}
+
+	key := diffKey(options)
+	if commit, ok := r.Cache.Get(raw.hash, key, options.ShowFileChanges); ok {
+		commit.RefNames = r.decoration[raw.hash]
+		return commit, nil
+	}
+	commit, err := r.commitWithChanges(raw, options)
+	if err != nil {
+		return Commit{}, err
+	}
+	r.Cache.Put(commit, key, options.ShowFileChanges)
+	return commit, nil
+}
+
+// commitWithChanges converts a walked commit, diffing it the way git log
+// does: merges get no diff (except against the first parent with
+// --first-parent) and root commits are diffed against the empty tree.
+func (r *NativeRepository) commitWithChanges(raw *rawCommit, options CommitOptions) (Commit, error) {
+	withFiles := options.ShowFileChanges
+	var changes []treeChange
+	if len(raw.parents) <= 1 || options.FirstParent {
+		parentTree := ""
+		if len(raw.parents) > 0 {
+			parent, err := r.odb.readTyped(raw.parents[0], objCommit)
+			if err != nil {
+				return Commit{}, fmt.Errorf("failed to read commit %s: %v", raw.parents[0], err)
+			}
+			parentTree = parseCommitObject(raw.parents[0], parent).tree
+		}
+		var err error
+		if changes, err = r.diffTrees(parentTree, raw.tree, options); err != nil {
+			return Commit{}, err
+		}
+	}
+
+	commit := r.toCommit(raw, changes, withFiles)
+	if withFiles && len(raw.parents) > 1 && !options.FirstParent {
+		// Same as the exec backend: list what the merge brought in
+		// relative to its first parent.
+		var err error
+		if commit.FileChanges, err = r.diff(raw.parents[0], raw.hash, options); err != nil {
+			return Commit{}, err
+		}
+	}
+	return commit, nil
+}
+
+func (r *NativeRepository) Show(rev string) (*Commit, error) {
+	hash, err := r.resolve(rev)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := r.readCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	parentTree := ""
+	if len(raw.parents) > 0 {
+		parent, err := r.readCommit(raw.parents[0])
+		if err != nil {
+			return nil, err
+		}
+		parentTree = parent.tree
+	}
+	changes, err := r.diffTrees(parentTree, raw.tree, CommitOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	commit := r.toCommit(raw, changes, true)
+	return &commit, nil
+}
+
+func (r *NativeRepository) Refs() ([]Ref, error) {
+	return append([]Ref(nil), r.refs...), nil
+}
+
+func (r *NativeRepository) Tags() ([]Tag, error) {
+	var tags []Tag
+	for _, ref := range r.refs {
+		name, ok := strings.CutPrefix(ref.Name, "refs/tags/")
+		if !ok {
+			continue
+		}
+		obj, err := r.odb.read(ref.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tag %s: %v", name, err)
+		}
+
+		tag := Tag{Name: name, Commit: ref.Hash}
+		if obj.kind == objTag {
+			tag.Commit = ref.Target
+			tag.Annotated = true
+			if tagger, ok := headerValue(obj.data, "tagger"); ok {
+				_, _, tag.Date = parseSignature(tagger)
+			}
+			if _, message, ok := strings.Cut(string(obj.data), "\n\n"); ok {
+				tag.Message = tagMessage(message)
+			}
+		} else if obj.kind != objCommit {
+			continue
+		}
+		commit, err := r.readCommit(tag.Commit)
+		if err != nil {
+			continue // Tags of trees and blobs
+		}
+		if !tag.Annotated {
+			tag.Date = commit.commitDate
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (r *NativeRepository) Diff(from, to string) ([]FileChange, error) {
+	return r.diff(from, to, CommitOptions{})
+}
+
+func (r *NativeRepository) diff(from, to string, options CommitOptions) ([]FileChange, error) {
+	fromTree, err := r.treeOf(from)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := r.treeOf(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := r.diffTrees(fromTree, toTree, options)
+	if err != nil {
+		return nil, err
+	}
+	fileChanges := make([]FileChange, 0, len(changes))
+	for _, change := range changes {
+		fileChanges = append(fileChanges, change.fileChange())
+	}
+	return fileChanges, nil
+}
+
+func (r *NativeRepository) treeOf(rev string) (string, error) {
+	hash, err := r.resolve(rev)
+	if err != nil {
+		return "", err
+	}
+	commit, err := r.readCommit(hash)
+	if err != nil {
+		return "", err
+	}
+	return commit.tree, nil
+}
+
+// Blame follows the file back through first parents (or the parent it was
+// taken from unchanged, for merges) and attributes each line to the commit
+// that introduced it. Renames are not followed.
+func (r *NativeRepository) Blame(path, rev string) ([]BlameLine, error) {
+	hash, err := r.resolve(rev)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := r.readCommit(hash)
+	if err != nil {
+		return nil, err
+	}
+	blobHash, err := r.lookupPath(commit.tree, path)
+	if err != nil {
+		return nil, err
+	}
+	if blobHash == "" {
+		return nil, fmt.Errorf("no such path %s in %s", path, rev)
+	}
+	content, err := r.odb.readTyped(blobHash, objBlob)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := splitLines(content)
+	result := make([]BlameLine, len(lines))
+	for i, line := range lines {
+		result[i] = BlameLine{LineNumber: i + 1, Content: strings.TrimSuffix(line, "\n")}
+	}
+
+	// pending maps a line index in the current version to the final line.
+	pending := make(map[int]int, len(lines))
+	for i := range lines {
+		pending[i] = i
+	}
+	assign := func(commit *rawCommit, final int) {
+		result[final].Hash = commit.hash
+		result[final].AuthorName = commit.authorName
+		result[final].AuthorEmail = commit.authorEmail
+		result[final].AuthorDate = commit.authorDate
+	}
+
+	for len(pending) > 0 {
+		var parent *rawCommit
+		var parentBlob string
+		for i, parentHash := range commit.parents {
+			candidate, err := r.readCommit(parentHash)
+			if err != nil {
+				return nil, err
+			}
+			candidateBlob, err := r.lookupPath(candidate.tree, path)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 || candidateBlob == blobHash {
+				parent, parentBlob = candidate, candidateBlob
+			}
+			if candidateBlob == blobHash {
+				break
+			}
+		}
+
+		if parent == nil || parentBlob == "" {
+			for _, final := range pending {
+				assign(commit, final)
+			}
+			break
+		}
+
+		if parentBlob != blobHash {
+			parentContent, err := r.odb.readTyped(parentBlob, objBlob)
+			if err != nil {
+				return nil, err
+			}
+			parentLines := splitLines(parentContent)
+
+			next := make(map[int]int, len(pending))
+			x, y := 0, 0
+			for _, op := range lineEdits(parentLines, lines) {
+				switch op {
+				case opEqual:
+					if final, ok := pending[y]; ok {
+						next[x] = final
+					}
+					x++
+					y++
+				case opInsert:
+					if final, ok := pending[y]; ok {
+						assign(commit, final)
+					}
+					y++
+				case opDelete:
+					x++
+				}
+			}
+			pending = next
+			lines = parentLines
+		}
+
+		commit, blobHash = parent, parentBlob
+	}
+	return result, nil
+}
+
+// treeEntry is one line of a tree object.
+type treeEntry struct {
+	mode string
+	name string
+	hash string
+}
+
+func (e treeEntry) isTree() bool { return e.mode == "40000" }
+
+func (r *NativeRepository) readTree(hash string) ([]treeEntry, error) {
+	if hash == "" {
+		return nil, nil
+	}
+	data, err := r.odb.readTyped(hash, objTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree %s: %v", hash, err)
+	}
+
+	var entries []treeEntry
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || nul+21 > len(data) {
+			return nil, fmt.Errorf("corrupt tree %s", hash)
+		}
+		entries = append(entries, treeEntry{
+			mode: string(data[:space]),
+			name: string(data[space+1 : nul]),
+			hash: hex.EncodeToString(data[nul+1 : nul+21]),
+		})
+		data = data[nul+21:]
+	}
+	return entries, nil
+}
+
+// lookupPath returns the blob hash at path inside tree, or "" if absent.
+func (r *NativeRepository) lookupPath(tree, path string) (string, error) {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		entries, err := r.readTree(tree)
+		if err != nil {
+			return "", err
+		}
+		tree = ""
+		for _, entry := range entries {
+			if entry.name == part {
+				if i == len(parts)-1 {
+					if entry.isTree() {
+						return "", nil
+					}
+					return entry.hash, nil
+				}
+				if entry.isTree() {
+					tree = entry.hash
+				}
+				break
+			}
+		}
+		if tree == "" {
+			return "", nil
+		}
+	}
+	return "", nil
+}
+
+// treeChange is one changed path between two trees.
+type treeChange struct {
+	status     byte // 'A', 'D', 'M', 'T', 'R' or 'C'
+	path       string
+	oldPath    string
+	similarity int
+	oldHash    string
+	newHash    string
+	oldMode    string
+	newMode    string
+	insertions int
+	deletions  int
+	binary     bool
+}
+
+func (c treeChange) fileChange() FileChange {
+	change := FileChange{
+		Status:     getStatusSymbol(string(c.status)),
+		FilePath:   c.path,
+		Insertions: c.insertions,
+		Deletions:  c.deletions,
+		Binary:     c.binary,
+	}
+	if c.status == 'R' || c.status == 'C' {
+		change.OldPath = c.oldPath
+		change.Similarity = c.similarity
+	}
+	return change
+}
+
+// diffTrees compares two trees recursively, pairs up renames and copies as
+// options ask and counts changed lines per file.
+func (r *NativeRepository) diffTrees(oldTree, newTree string, options CommitOptions) ([]treeChange, error) {
+	var changes []treeChange
+	if err := r.walkTreeDiff(oldTree, newTree, "", &changes); err != nil {
+		return nil, err
+	}
+
+	// Like git, a pathspec limits the diff before renames are looked for.
+	var err error
+	switch {
+	case hasPaths(options) && options.Follow:
+		changes, err = r.followChanges(oldTree, changes, options)
+	case hasPaths(options):
+		var spec *pathspec
+		if spec, err = r.pathspec(options); err != nil {
+			return nil, err
+		}
+		changes, err = r.detectRenames(filterChanges(changes, spec), options)
+	default:
+		changes, err = r.detectRenames(changes, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range changes {
+		if err := r.countChange(&changes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// followChanges narrows a --follow diff to the followed file. When the
+// file first appears, every file of the old tree is a possible source, as
+// with git's --find-copies-harder, so the walk can go on under its old name.
+func (r *NativeRepository) followChanges(oldTree string, changes []treeChange, options CommitOptions) ([]treeChange, error) {
+	spec, err := r.pathspec(options)
+	if err != nil {
+		return nil, err
+	}
+	deleted := make(map[string]bool)
+	for _, change := range changes {
+		if change.status == 'D' {
+			deleted[change.path] = true
+		}
+	}
+	followed := filterChanges(changes, spec)
+	if len(followed) != 1 || followed[0].status != 'A' || oldTree == "" {
+		return followed, nil
+	}
+
+	var sources []treeChange
+	if err := r.walkTreeDiff(oldTree, "", "", &sources); err != nil {
+		return nil, err
+	}
+	for i := range sources {
+		if !deleted[sources[i].path] {
+			sources[i].status = 'M' // Still there, so only a copy source
+		}
+	}
+	if options.CopyThreshold = options.RenameThreshold; options.CopyThreshold <= 0 {
+		options.CopyThreshold = defaultRenameScore
+	}
+	matched, err := r.detectRenames(append(sources, followed[0]), options)
+	if err != nil {
+		return nil, err
+	}
+	return matched[len(matched)-1:], nil
+}
+
+// touchesPaths reports whether two trees differ on the paths options select.
+func (r *NativeRepository) touchesPaths(oldTree, newTree string, options CommitOptions) (bool, error) {
+	spec, err := r.pathspec(options)
+	if err != nil {
+		return false, err
+	}
+	var changes []treeChange
+	if err := r.walkTreeDiff(oldTree, newTree, "", &changes); err != nil {
+		return false, err
+	}
+	return len(filterChanges(changes, spec)) > 0, nil
+}
+
+// pathspec compiles the path limits in options once per repository.
+func (r *NativeRepository) pathspec(options CommitOptions) (*pathspec, error) {
+	key := strings.Join(options.Paths, "\x00") + "\x01" + strings.Join(options.ExcludePaths, "\x00")
+	if spec, ok := r.specs[key]; ok {
+		return spec, nil
+	}
+	spec, err := newPathspec(r.prefix, options)
+	if err != nil {
+		return nil, err
+	}
+	r.specs[key] = spec
+	return spec, nil
+}
+
+func filterChanges(changes []treeChange, spec *pathspec) []treeChange {
+	kept := changes[:0]
+	for _, change := range changes {
+		if spec.matches(change.path) {
+			kept = append(kept, change)
+		}
+	}
+	return kept
+}
+
+func (r *NativeRepository) walkTreeDiff(oldTree, newTree, prefix string, changes *[]treeChange) error {
+	if oldTree == newTree {
+		return nil
+	}
+	oldEntries, err := r.readTree(oldTree)
+	if err != nil {
+		return err
+	}
+	newEntries, err := r.readTree(newTree)
+	if err != nil {
+		return err
+	}
+
+	oldByName := make(map[string]treeEntry, len(oldEntries))
+	for _, entry := range oldEntries {
+		oldByName[entry.name] = entry
+	}
+	newByName := make(map[string]treeEntry, len(newEntries))
+	for _, entry := range newEntries {
+		newByName[entry.name] = entry
+	}
+	names := make([]string, 0, len(oldEntries)+len(newEntries))
+	for name := range oldByName {
+		names = append(names, name)
+	}
+	for name := range newByName {
+		if _, ok := oldByName[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	// git orders paths with directories compared as "name/".
+	sortKey := func(name string) string {
+		if entry, ok := newByName[name]; ok && entry.isTree() {
+			return name + "/"
+		}
+		if entry, ok := oldByName[name]; ok && entry.isTree() {
+			return name + "/"
+		}
+		return name
+	}
+	sort.Slice(names, func(i, j int) bool { return sortKey(names[i]) < sortKey(names[j]) })
+
+	for _, name := range names {
+		path := prefix + name
+		oldEntry, inOld := oldByName[name]
+		newEntry, inNew := newByName[name]
+
+		switch {
+		case inOld && inNew && oldEntry.hash == newEntry.hash && oldEntry.mode == newEntry.mode:
+			continue
+		case inOld && inNew && oldEntry.isTree() && newEntry.isTree():
+			if err := r.walkTreeDiff(oldEntry.hash, newEntry.hash, path+"/", changes); err != nil {
+				return err
+			}
+		case inOld && inNew && !oldEntry.isTree() && !newEntry.isTree():
+			status := byte('M')
+			if fileKind(oldEntry.mode) != fileKind(newEntry.mode) {
+				status = 'T'
+			}
+			*changes = append(*changes, treeChange{status: status, path: path,
+				oldHash: oldEntry.hash, newHash: newEntry.hash, oldMode: oldEntry.mode, newMode: newEntry.mode})
+		default:
+			// A file sorts before the directory that replaced it (or that
+			// it replaced): "dir" < "dir/file".
+			sides := []struct {
+				entry  treeEntry
+				ok     bool
+				status byte
+			}{{oldEntry, inOld, 'D'}, {newEntry, inNew, 'A'}}
+			if inOld && oldEntry.isTree() {
+				sides[0], sides[1] = sides[1], sides[0]
+			}
+			for _, side := range sides {
+				if !side.ok {
+					continue
+				}
+				if err := r.addWholeTree(side.entry, path, side.status, changes); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fileKind groups modes that git treats as the same type of file.
+func fileKind(mode string) string {
+	switch mode {
+	case "120000":
+		return "symlink"
+	case "160000":
+		return "gitlink"
+	default:
+		return "file"
+	}
+}
+
+// addWholeTree records entry (and everything below it, for trees) as added
+// or deleted.
+func (r *NativeRepository) addWholeTree(entry treeEntry, path string, status byte, changes *[]treeChange) error {
+	if !entry.isTree() {
+		change := treeChange{status: status, path: path}
+		if status == 'A' {
+			change.newHash, change.newMode = entry.hash, entry.mode
+		} else {
+			change.oldHash, change.oldMode = entry.hash, entry.mode
+		}
+		*changes = append(*changes, change)
+		return nil
+	}
+
+	entries, err := r.readTree(entry.hash)
+	if err != nil {
+		return err
+	}
+	for _, child := range entries {
+		if err := r.addWholeTree(child, path+"/"+child.name, status, changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countChange fills in the --numstat line counts for one change.
+func (r *NativeRepository) countChange(change *treeChange) error {
+	oldContent, err := r.blobContent(change.oldHash, change.oldMode)
+	if err != nil {
+		return err
+	}
+	newContent, err := r.blobContent(change.newHash, change.newMode)
+	if err != nil {
+		return err
+	}
+	if isBinary(oldContent) || isBinary(newContent) {
+		change.binary = true
+		return nil
+	}
+	change.insertions, change.deletions = countLineChanges(splitLines(oldContent), splitLines(newContent))
+	return nil
+}
+
+// blobContent returns what diff compares for an entry. Submodules have no
+// blob; git diffs them as a one-line "Subproject commit" text.
+func (r *NativeRepository) blobContent(hash, mode string) ([]byte, error) {
+	if hash == "" {
+		return nil, nil
+	}
+	if mode == "160000" {
+		return []byte("Subproject commit " + hash + "\n"), nil
+	}
+	return r.odb.readTyped(hash, objBlob)
+}
@@ -0,0 +1,357 @@
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testRepo builds a repository with the git binary, one commit an hour.
+type testRepo struct {
+	t    testing.TB
+	dir  string
+	tick int
+}
+
+func newTestRepo(t testing.TB) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	// Keep the user's configuration, diff.algorithm say, out of both
+	// backends.
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	r := &testRepo{t: t, dir: t.TempDir()}
+	r.git("init", "-q", "-b", "main")
+	r.git("config", "pack.indexVersion", "2")
+	return r
+}
+
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	date := fmt.Sprintf("%d +0200", 1700000000+r.tick*3600)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Ann Example", "GIT_AUTHOR_EMAIL=ann@example.com", "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME=Bob Example", "GIT_COMMITTER_EMAIL=bob@example.com", "GIT_COMMITTER_DATE="+date,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return string(output)
+}
+
+// commit writes files, removing those with empty content, and commits
+// everything.
+func (r *testRepo) commit(message string, files map[string]string) {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.dir, name)
+		if content == "" {
+			if err := os.Remove(path); err != nil {
+				r.t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.git("add", "-A")
+	r.git("commit", "-q", "--allow-empty", "-m", message)
+	r.tick++
+}
+
+// source is a file of n lines drawn from a few dozen, repeated as braces
+// and blank lines are in code, so diffs of it take git's heuristics.
+func source(rng *rand.Rand, n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		switch k := rng.Intn(10); {
+		case k < 3:
+			lines[i] = "}"
+		case k < 5:
+			lines[i] = ""
+		default:
+			lines[i] = fmt.Sprintf("\tstatement(%d)", rng.Intn(40))
+		}
+	}
+	return lines
+}
+
+// edit changes a share of lines, inserting and deleting some.
+func edit(rng *rand.Rand, lines []string, share float64) []string {
+	var out []string
+	for _, line := range lines {
+		switch {
+		case rng.Float64() >= share:
+			out = append(out, line)
+		case rng.Intn(3) == 0:
+			// Deleted.
+		case rng.Intn(2) == 0:
+			out = append(out, line, fmt.Sprintf("\tinserted(%d)", rng.Intn(1000)))
+		default:
+			out = append(out, fmt.Sprintf("\treplaced(%d)", rng.Intn(60)))
+		}
+	}
+	return out
+}
+
+func joinLines(lines []string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// buildHistory makes branches, a merge, renames, a copy, binary files,
+// paths with spaces, a file without a final newline, large rewrites and
+// both kinds of tag.
+func buildHistory(r *testRepo) {
+	rng := rand.New(rand.NewSource(1))
+	main := source(rng, 400)
+	data := source(rng, 6000)
+	notes := "first note\nsecond note\nthird note\nfourth note\n"
+	util := joinLines(source(rng, 60))
+
+	r.commit("Initial commit", map[string]string{
+		"README.md":    "# Test\n",
+		"main.go":      joinLines(main),
+		"data.txt":     joinLines(data),
+		"my notes.txt": notes,
+		"logo.bin":     "\x89PNG\x00\x01\x02",
+	})
+	main = edit(rng, main, 0.2)
+	r.commit("feat: rework main | part one\n\nWith a body.\n\nCo-authored-by: Cy <cy@example.com>", map[string]string{
+		"main.go":           joinLines(main),
+		"my notes.txt":      "",
+		"docs/my notes.txt": notes + "fifth note\n",
+		"logo.bin":          "\x89PNG\x00\x01\x03",
+	})
+	r.git("tag", "-a", "v1.0", "-m", "Release 1.0")
+
+	r.git("checkout", "-q", "-b", "feature")
+	r.commit("Add util", map[string]string{"util.go": util})
+	r.commit("Édit README 🎉", map[string]string{"README.md": "# Test\n\nMore.\n"})
+
+	r.git("checkout", "-q", "main")
+	main = edit(rng, main, 0.1)
+	r.commit("Edit main", map[string]string{"main.go": joinLines(main), "no newline.txt": "a\nb"})
+	r.git("tag", "light")
+	r.git("merge", "-q", "--no-ff", "-m", "Merge branch 'feature'", "feature")
+
+	r.commit("Rewrite data", map[string]string{"data.txt": joinLines(edit(rng, data, 0.6))})
+	r.commit("Copy util, drop logo", map[string]string{
+		"util copy.go":   util + "// copied\n",
+		"logo.bin":       "",
+		"no newline.txt": "a\nc",
+	})
+}
+
+// backendOptions are the Log options both backends are compared under.
+var backendOptions = []CommitOptions{
+	{},
+	{ShowFileChanges: true},
+	{ShowFileChanges: true, FirstParent: true},
+	{ShowFileChanges: true, CopyThreshold: 50},
+	{ShowFileChanges: true, RenameThreshold: 90},
+	{ShowFileChanges: true, NoRenames: true},
+	{ShowFileChanges: true, Revisions: []string{"v1.0..main"}},
+	{ShowFileChanges: true, Branch: "feature"},
+	{ShowFileChanges: true, Paths: []string{"docs"}},
+	{ShowFileChanges: true, Paths: []string{"docs/my notes.txt"}, Follow: true},
+	{NoMerges: true, Limit: 3},
+	{MergesOnly: true, ShowFileChanges: true},
+	{Author: "Cy", ShowFileChanges: true},
+	{Author: "ann|cy", IgnoreCase: true, Limit: 2, ShowFileChanges: true},
+	{Author: "Cy", Paths: []string{"docs"}, ShowFileChanges: true},
+	{Author: "Ann", Paths: []string{"README.md", "main.go"}, ShowFileChanges: true},
+	{Author: "Ann", Paths: []string{"docs/my notes.txt"}, Follow: true},
+	{Grep: []string{`\bpart \w+`, `^Add \S+$`}},
+	{Grep: []string{`(?i)^edit MAIN`, `\d\.\d`}, IgnoreCase: true},
+	{Grep: []string{`^[A-Z]\w+ \w+$`}, InvertGrep: true},
+}
+
+// compareBackends checks that both backends read the same history from
+// dir, date zones included.
+func compareBackends(t *testing.T, dir string) {
+	t.Helper()
+	execRepo := NewExecRepository(dir)
+	native, err := NewNativeRepository(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, options := range backendOptions {
+		want, err := execRepo.Log(options)
+		if err != nil {
+			t.Fatalf("exec Log(%+v): %v", options, err)
+		}
+		got, err := native.Log(options)
+		if err != nil {
+			t.Fatalf("native Log(%+v): %v", options, err)
+		}
+		if len(want) == 0 {
+			t.Errorf("Log(%+v) found no commits", options)
+		}
+		if diff := commitsDiff(t, got, want); diff != "" {
+			t.Errorf("Log(%+v): %s", options, diff)
+		}
+	}
+
+	for name, read := range map[string]func(Repository) (interface{}, error){
+		"Refs": func(r Repository) (interface{}, error) { return r.Refs() },
+		"Tags": func(r Repository) (interface{}, error) { return r.Tags() },
+	} {
+		want, err := read(execRepo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := read(native)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g, w := asJSON(t, got), asJSON(t, want); g != w {
+			t.Errorf("%s differs\nnative: %s\nexec:   %s", name, g, w)
+		}
+	}
+}
+
+// commitsDiff describes the first difference between the commits the
+// native and exec backends read, or is empty.
+func commitsDiff(t *testing.T, native, exec []Commit) string {
+	t.Helper()
+	for i := 0; i < len(native) && i < len(exec); i++ {
+		var got, want map[string]interface{}
+		json.Unmarshal([]byte(asJSON(t, native[i])), &got)
+		json.Unmarshal([]byte(asJSON(t, exec[i])), &want)
+		for field := range want {
+			if g, w := asJSON(t, got[field]), asJSON(t, want[field]); g != w {
+				return fmt.Sprintf("commit %d (%s) %s: native %s, exec %s", i, exec[i].ShortHash, field, g, w)
+			}
+		}
+	}
+	if len(native) != len(exec) {
+		return fmt.Sprintf("native read %d commits, exec %d", len(native), len(exec))
+	}
+	return ""
+}
+
+func asJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestNativeMatchesExec(t *testing.T) {
+	r := newTestRepo(t)
+	buildHistory(r)
+
+	t.Run("loose", func(t *testing.T) {
+		compareBackends(t, r.dir)
+	})
+
+	// gc packs every object, with deltas, and moves refs to packed-refs.
+	r.git("gc", "-q", "--aggressive")
+	if _, err := os.Stat(filepath.Join(r.dir, ".git", "packed-refs")); err != nil {
+		t.Fatalf("gc wrote no packed-refs: %v", err)
+	}
+	t.Run("packed", func(t *testing.T) {
+		compareBackends(t, r.dir)
+	})
+
+	r.commit("After gc", map[string]string{"README.md": "# Test\n\nPacked, then loose.\n"})
+	r.git("tag", "-a", "v2.0", "-m", "Release 2.0")
+	t.Run("packed and loose", func(t *testing.T) {
+		compareBackends(t, r.dir)
+	})
+
+	// A shared clone borrows the objects above through info/alternates.
+	clone := &testRepo{t: t, dir: filepath.Join(t.TempDir(), "clone"), tick: r.tick}
+	r.git("clone", "-q", "--shared", r.dir, clone.dir)
+	if _, err := os.Stat(filepath.Join(clone.dir, ".git", "objects", "info", "alternates")); err != nil {
+		t.Fatalf("clone --shared wrote no alternates: %v", err)
+	}
+	clone.git("branch", "feature", "origin/feature")
+	clone.commit("In the clone", map[string]string{"clone.txt": "own object\n"})
+	t.Run("alternates", func(t *testing.T) {
+		compareBackends(t, clone.dir)
+	})
+
+	// A shallow clone lacks the parents of its boundary commits, which
+	// both backends then list as roots.
+	shallow := &testRepo{t: t, dir: filepath.Join(t.TempDir(), "shallow"), tick: r.tick}
+	r.git("clone", "-q", "--depth", "6", "--single-branch", "file://"+r.dir, shallow.dir)
+	if _, err := os.Stat(filepath.Join(shallow.dir, ".git", "shallow")); err != nil {
+		t.Fatalf("clone --depth wrote no shallow file: %v", err)
+	}
+	shallow.git("branch", "feature", "HEAD~3^2")
+	t.Run("shallow", func(t *testing.T) {
+		compareBackends(t, shallow.dir)
+	})
+}
+
+func TestNativeObjectFormat(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Initial commit", map[string]string{"a.txt": "a\n"})
+	r.git("config", "core.repositoryformatversion", "1")
+	r.git("config", "extensions.objectFormat", "sha256")
+	if _, err := NewNativeRepository(r.dir); err == nil || !strings.Contains(err.Error(), "does not support sha256") {
+		t.Errorf("NewNativeRepository of a sha256 repository: err = %v", err)
+	}
+}
+
+func TestNativeMissingAlternate(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Initial commit", map[string]string{"a.txt": "a\n"})
+	info := filepath.Join(r.dir, ".git", "objects", "info")
+	if err := os.MkdirAll(info, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(info, "alternates"), []byte("../../nowhere/objects\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewNativeRepository(r.dir); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("NewNativeRepository with a missing alternate: err = %v", err)
+	}
+}
+
+func TestCountLineChangesMatchesGit(t *testing.T) {
+	r := newTestRepo(t)
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 20; i++ {
+		before := source(rng, 200+rng.Intn(3000))
+		after := edit(rng, before, rng.Float64())
+		if err := os.WriteFile(filepath.Join(r.dir, "a"), []byte(joinLines(before)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(r.dir, "b"), []byte(joinLines(after)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := exec.Command("git", "diff", "--no-index", "--numstat", "a", "b")
+		cmd.Dir = r.dir
+		output, _ := cmd.Output() // Exits 1 when the files differ
+		var wantIns, wantDel int
+		fmt.Sscanf(string(output), "%d\t%d", &wantIns, &wantDel)
+
+		ins, del := countLineChanges(splitLines([]byte(joinLines(before))), splitLines([]byte(joinLines(after))))
+		if ins != wantIns || del != wantDel {
+			t.Errorf("case %d: countLineChanges = +%d -%d, git says +%d -%d", i, ins, del, wantIns, wantDel)
+		}
+	}
+}
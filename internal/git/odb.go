@@ -0,0 +1,531 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Object types as stored in loose objects and pack headers.
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+var objectTypeNames = map[string]int{
+	"commit": objCommit,
+	"tree":   objTree,
+	"blob":   objBlob,
+	"tag":    objTag,
+}
+
+var errObjectNotFound = errors.New("object not found")
+
+// object is a fully inflated git object.
+type object struct {
+	kind int
+	data []byte
+}
+
+// objectDB reads loose objects and packfiles straight from .git/objects,
+// and from the object directories listed in its info/alternates.
+type objectDB struct {
+	dir        string
+	packs      []*packFile
+	alternates []*objectDB
+
+	mu    sync.Mutex
+	cache map[string]object // Recently read objects; delta bases mostly
+}
+
+// maxCachedObjects bounds the object cache. Delta chains and tree walks
+// revisit the same objects constantly, so a small cache goes a long way.
+const maxCachedObjects = 4096
+
+// maxAlternateDepth is how deep git follows alternates of alternates.
+const maxAlternateDepth = 5
+
+func openObjectDB(objectsDir string) (*objectDB, error) {
+	return openObjects(objectsDir, 0)
+}
+
+func openObjects(objectsDir string, depth int) (*objectDB, error) {
+	db := &objectDB{dir: objectsDir, cache: make(map[string]object)}
+
+	idxFiles, err := filepath.Glob(filepath.Join(objectsDir, "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	for _, idxPath := range idxFiles {
+		pack, err := openPackFile(idxPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open pack %s: %v", filepath.Base(idxPath), err)
+		}
+		db.packs = append(db.packs, pack)
+	}
+
+	dirs, err := readAlternates(objectsDir)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if len(dirs) > 0 && depth >= maxAlternateDepth {
+		db.Close()
+		return nil, fmt.Errorf("alternate object directories nest more than %d deep at %s", maxAlternateDepth, objectsDir)
+	}
+	for _, dir := range dirs {
+		alternate, err := openObjects(dir, depth+1)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		db.alternates = append(db.alternates, alternate)
+	}
+	return db, nil
+}
+
+// readAlternates lists the object directories in objectsDir's
+// info/alternates, relative ones resolved against objectsDir. A listed
+// directory that does not exist is an error: its objects would be
+// missing from history.
+func readAlternates(objectsDir string) ([]string, error) {
+	content, err := os.ReadFile(filepath.Join(objectsDir, "info", "alternates"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alternates of %s: %v", objectsDir, err)
+	}
+
+	var dirs []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, `"`) {
+			// Paths with unusual characters are C-quoted.
+			if unquoted, err := strconv.Unquote(line); err == nil {
+				line = unquoted
+			}
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(objectsDir, line)
+		}
+		if info, err := os.Stat(line); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("alternate object directory %s does not exist; check %s", line, filepath.Join(objectsDir, "info", "alternates"))
+		}
+		dirs = append(dirs, line)
+	}
+	return dirs, nil
+}
+
+func (db *objectDB) Close() error {
+	for _, pack := range db.packs {
+		pack.file.Close()
+	}
+	for _, alternate := range db.alternates {
+		alternate.Close()
+	}
+	return nil
+}
+
+// read returns the object with the given hex hash, looking in loose objects
+// first and then in every pack.
+func (db *objectDB) read(hash string) (object, error) {
+	db.mu.Lock()
+	if obj, ok := db.cache[hash]; ok {
+		db.mu.Unlock()
+		return obj, nil
+	}
+	db.mu.Unlock()
+
+	obj, err := db.lookup(hash)
+	if err != nil {
+		return object{}, err
+	}
+
+	db.mu.Lock()
+	if len(db.cache) >= maxCachedObjects {
+		db.cache = make(map[string]object)
+	}
+	db.cache[hash] = obj
+	db.mu.Unlock()
+	return obj, nil
+}
+
+// lookup finds an object among the loose objects and packs, then in the
+// alternates.
+func (db *objectDB) lookup(hash string) (object, error) {
+	obj, err := db.readLoose(hash)
+	if errors.Is(err, errObjectNotFound) {
+		obj, err = db.readPacked(hash)
+	}
+	for _, alternate := range db.alternates {
+		if !errors.Is(err, errObjectNotFound) {
+			break
+		}
+		obj, err = alternate.lookup(hash)
+	}
+	return obj, err
+}
+
+// readTyped reads an object and checks that it has the expected type.
+func (db *objectDB) readTyped(hash string, kind int) ([]byte, error) {
+	obj, err := db.read(hash)
+	if err != nil {
+		return nil, err
+	}
+	if obj.kind != kind {
+		return nil, fmt.Errorf("object %s has type %d, expected %d", hash, obj.kind, kind)
+	}
+	return obj.data, nil
+}
+
+func (db *objectDB) readLoose(hash string) (object, error) {
+	if len(hash) != 40 {
+		return object{}, errObjectNotFound
+	}
+	file, err := os.Open(filepath.Join(db.dir, hash[:2], hash[2:]))
+	if os.IsNotExist(err) {
+		return object{}, errObjectNotFound
+	}
+	if err != nil {
+		return object{}, err
+	}
+	defer file.Close()
+
+	zr, err := zlib.NewReader(file)
+	if err != nil {
+		return object{}, fmt.Errorf("corrupt loose object %s: %v", hash, err)
+	}
+	defer zr.Close()
+
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		return object{}, fmt.Errorf("corrupt loose object %s: %v", hash, err)
+	}
+
+	// Header: "<type> <size>\x00"
+	nul := bytes.IndexByte(content, 0)
+	if nul < 0 {
+		return object{}, fmt.Errorf("corrupt loose object %s: missing header", hash)
+	}
+	header := strings.SplitN(string(content[:nul]), " ", 2)
+	kind, ok := objectTypeNames[header[0]]
+	if !ok || len(header) != 2 {
+		return object{}, fmt.Errorf("corrupt loose object %s: bad header %q", hash, content[:nul])
+	}
+	size, err := strconv.Atoi(header[1])
+	if err != nil || size != len(content)-nul-1 {
+		return object{}, fmt.Errorf("corrupt loose object %s: size mismatch", hash)
+	}
+	return object{kind: kind, data: content[nul+1:]}, nil
+}
+
+func (db *objectDB) readPacked(hash string) (object, error) {
+	raw, err := hex.DecodeString(hash)
+	if err != nil || len(raw) != 20 {
+		return object{}, errObjectNotFound
+	}
+	for _, pack := range db.packs {
+		if offset, ok := pack.find(raw); ok {
+			return pack.readAt(db, offset)
+		}
+	}
+	return object{}, fmt.Errorf("%w: %s", errObjectNotFound, hash)
+}
+
+// expand resolves an abbreviated hash against loose objects and packs,
+// alternates included.
+func (db *objectDB) expand(prefix string) (string, error) {
+	prefix = strings.ToLower(prefix)
+	if len(prefix) < 4 || len(prefix) > 40 {
+		return "", errObjectNotFound
+	}
+	if _, err := hex.DecodeString(prefix[:len(prefix)&^1]); err != nil {
+		return "", errObjectNotFound
+	}
+
+	matches := make(map[string]bool)
+	db.collectPrefix(prefix, matches)
+
+	switch len(matches) {
+	case 0:
+		return "", errObjectNotFound
+	case 1:
+		for name := range matches {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("short object ID %s is ambiguous", prefix)
+}
+
+// collectPrefix adds the objects starting with prefix to matches.
+func (db *objectDB) collectPrefix(prefix string, matches map[string]bool) {
+	entries, _ := os.ReadDir(filepath.Join(db.dir, prefix[:2]))
+	for _, entry := range entries {
+		if name := prefix[:2] + entry.Name(); strings.HasPrefix(name, prefix) {
+			matches[name] = true
+		}
+	}
+	for _, pack := range db.packs {
+		for _, name := range pack.withPrefix(prefix) {
+			matches[name] = true
+		}
+	}
+	for _, alternate := range db.alternates {
+		alternate.collectPrefix(prefix, matches)
+	}
+}
+
+// packFile is a .pack together with its version 2 .idx.
+type packFile struct {
+	file         *os.File
+	fanout       [256]uint32
+	hashes       []byte // count * 20 bytes, sorted
+	offsets      []byte // count * 4 bytes
+	largeOffsets []byte // 8 bytes each, for offsets >= 2^31
+}
+
+func openPackFile(idxPath string) (*packFile, error) {
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) {
+		return nil, fmt.Errorf("unsupported index format")
+	}
+	if version := binary.BigEndian.Uint32(idx[4:8]); version != 2 {
+		return nil, fmt.Errorf("unsupported index version %d", version)
+	}
+
+	pack := &packFile{}
+	for i := range pack.fanout {
+		pack.fanout[i] = binary.BigEndian.Uint32(idx[8+i*4:])
+	}
+	count := int(pack.fanout[255])
+
+	pos := 8 + 256*4
+	end := pos + count*20 + count*4 + count*4
+	if len(idx) < end {
+		return nil, fmt.Errorf("truncated index")
+	}
+	pack.hashes = idx[pos : pos+count*20]
+	pos += count * 20
+	pos += count * 4 // CRC32 table
+	pack.offsets = idx[pos : pos+count*4]
+	pos += count * 4
+	pack.largeOffsets = idx[pos:]
+
+	pack.file, err = os.Open(strings.TrimSuffix(idxPath, ".idx") + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+func (p *packFile) find(hash []byte) (int64, bool) {
+	lo := 0
+	if hash[0] > 0 {
+		lo = int(p.fanout[hash[0]-1])
+	}
+	hi := int(p.fanout[hash[0]])
+
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.hashes[(lo+i)*20:(lo+i+1)*20], hash) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.hashes[i*20:(i+1)*20], hash) {
+		return 0, false
+	}
+
+	offset := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+	large := int(offset&0x7fffffff) * 8
+	if large+8 > len(p.largeOffsets) {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(p.largeOffsets[large:])), true
+}
+
+func (p *packFile) withPrefix(prefix string) []string {
+	first, err := strconv.ParseUint(prefix[:2], 16, 8)
+	if err != nil {
+		return nil
+	}
+	lo := 0
+	if first > 0 {
+		lo = int(p.fanout[first-1])
+	}
+	hi := int(p.fanout[first])
+
+	var names []string
+	for i := lo; i < hi; i++ {
+		name := hex.EncodeToString(p.hashes[i*20 : (i+1)*20])
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// readAt inflates the pack entry at offset, applying deltas as needed.
+func (p *packFile) readAt(db *objectDB, offset int64) (object, error) {
+	r := bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62))
+
+	// Entry header: type in bits 4-6 of the first byte, size as a varint.
+	b, err := r.ReadByte()
+	if err != nil {
+		return object{}, err
+	}
+	kind := int(b>>4) & 7
+	size := uint64(b & 0x0f)
+	for shift := uint(4); b&0x80 != 0; shift += 7 {
+		if b, err = r.ReadByte(); err != nil {
+			return object{}, err
+		}
+		size |= uint64(b&0x7f) << shift
+	}
+
+	switch kind {
+	case objCommit, objTree, objBlob, objTag:
+		data, err := inflate(r, size)
+		if err != nil {
+			return object{}, err
+		}
+		return object{kind: kind, data: data}, nil
+
+	case objOfsDelta:
+		// Negative offset to the base, in git's "offset encoding".
+		b, err := r.ReadByte()
+		if err != nil {
+			return object{}, err
+		}
+		rel := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = r.ReadByte(); err != nil {
+				return object{}, err
+			}
+			rel = ((rel + 1) << 7) | int64(b&0x7f)
+		}
+		delta, err := inflate(r, size)
+		if err != nil {
+			return object{}, err
+		}
+		base, err := p.readAt(db, offset-rel)
+		if err != nil {
+			return object{}, err
+		}
+		return applyDelta(base, delta)
+
+	case objRefDelta:
+		var baseHash [20]byte
+		if _, err := io.ReadFull(r, baseHash[:]); err != nil {
+			return object{}, err
+		}
+		delta, err := inflate(r, size)
+		if err != nil {
+			return object{}, err
+		}
+		base, err := db.read(hex.EncodeToString(baseHash[:]))
+		if err != nil {
+			return object{}, err
+		}
+		return applyDelta(base, delta)
+	}
+	return object{}, fmt.Errorf("unknown pack entry type %d at offset %d", kind, offset)
+}
+
+func inflate(r io.Reader, size uint64) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, fmt.Errorf("corrupt pack entry: %v", err)
+	}
+	return data, nil
+}
+
+// applyDelta rebuilds an object from its base and a git delta: two size
+// varints followed by copy and insert instructions.
+func applyDelta(base object, delta []byte) (object, error) {
+	pos := 0
+	readSize := func() uint64 {
+		var size uint64
+		for shift := uint(0); pos < len(delta); shift += 7 {
+			b := delta[pos]
+			pos++
+			size |= uint64(b&0x7f) << shift
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		return size
+	}
+
+	if srcSize := readSize(); srcSize != uint64(len(base.data)) {
+		return object{}, fmt.Errorf("delta base size mismatch")
+	}
+	out := make([]byte, 0, readSize())
+
+	for pos < len(delta) {
+		op := delta[pos]
+		pos++
+
+		if op&0x80 == 0 {
+			// Insert the next op bytes literally.
+			n := int(op)
+			if n == 0 || pos+n > len(delta) {
+				return object{}, fmt.Errorf("invalid delta insert")
+			}
+			out = append(out, delta[pos:pos+n]...)
+			pos += n
+			continue
+		}
+
+		// Copy from base; op bits select which offset/size bytes follow.
+		var offset, length uint32
+		for i := uint(0); i < 4; i++ {
+			if op&(1<<i) != 0 && pos < len(delta) {
+				offset |= uint32(delta[pos]) << (8 * i)
+				pos++
+			}
+		}
+		for i := uint(0); i < 3; i++ {
+			if op&(0x10<<i) != 0 && pos < len(delta) {
+				length |= uint32(delta[pos]) << (8 * i)
+				pos++
+			}
+		}
+		if length == 0 {
+			length = 0x10000
+		}
+		if uint64(offset)+uint64(length) > uint64(len(base.data)) {
+			return object{}, fmt.Errorf("invalid delta copy")
+		}
+		out = append(out, base.data[offset:offset+length]...)
+	}
+
+	return object{kind: base.kind, data: out}, nil
+}
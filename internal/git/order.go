@@ -0,0 +1,117 @@
+package git
+
+import (
+	"fmt"
+	"time"
+)
+
+// Orders for CommitOptions.Order, as git log's --date-order,
+// --author-date-order and --topo-order: no commit is listed before all of
+// its children. The default walk lists commits newest commit date first,
+// which can put a parent with a skewed clock before its child.
+const (
+	OrderCommitDate = "commit-date"
+	OrderAuthorDate = "author-date"
+	OrderTopo       = "topo"
+)
+
+// Orders lists the values CommitOptions.Order accepts besides empty.
+func Orders() []string {
+	return []string{OrderAuthorDate, OrderCommitDate, OrderTopo}
+}
+
+func checkOrder(options CommitOptions) error {
+	switch options.Order {
+	case "", OrderCommitDate, OrderAuthorDate, OrderTopo:
+		return nil
+	default:
+		return fmt.Errorf("unknown order %q (use author-date, commit-date or topo)", options.Order)
+	}
+}
+
+// orderArgs is the git log flag for options.Order.
+func orderArgs(options CommitOptions) []string {
+	switch options.Order {
+	case OrderCommitDate:
+		return []string{"--date-order"}
+	case OrderAuthorDate:
+		return []string{"--author-date-order"}
+	case OrderTopo:
+		return []string{"--topo-order"}
+	default:
+		return nil
+	}
+}
+
+// sortCommits reorders commits, listed newest first, so every commit comes
+// after its listed children. Of the commits whose children are all listed,
+// the date orders take the newest, as git does, and the one that became
+// ready first among equals; topo order takes the one reached last, so a
+// line of history is finished before the next starts.
+func sortCommits(commits []Commit, order string) []Commit {
+	if order == "" || len(commits) < 2 {
+		return commits
+	}
+	date := func(c Commit) time.Time { return c.CommitDate }
+	if order == OrderAuthorDate {
+		date = func(c Commit) time.Time { return c.AuthorDate }
+	}
+
+	index := make(map[string]int, len(commits))
+	for i, commit := range commits {
+		index[commit.Hash] = i
+	}
+	children := make([]int, len(commits))
+	for _, commit := range commits {
+		for _, parent := range commit.ParentHashes {
+			if j, ok := index[parent]; ok {
+				children[j]++
+			}
+		}
+	}
+
+	// The tips are ready in the order they are listed, the rest as their
+	// last child is taken.
+	readyAt := make([]int, len(commits))
+	turn := len(commits)
+	var ready []int
+	for i := len(commits) - 1; i >= 0; i-- {
+		if children[i] == 0 {
+			ready = append(ready, i)
+			readyAt[i] = i
+		}
+	}
+	sorted := make([]Commit, 0, len(commits))
+	for len(ready) > 0 {
+		next := len(ready) - 1
+		if order != OrderTopo {
+			for k := len(ready) - 2; k >= 0; k-- {
+				a, b := date(commits[ready[k]]), date(commits[ready[next]])
+				if a.After(b) || a.Equal(b) && readyAt[ready[k]] < readyAt[ready[next]] {
+					next = k
+				}
+			}
+		}
+		i := ready[next]
+		ready = append(ready[:next], ready[next+1:]...)
+		sorted = append(sorted, commits[i])
+		for _, parent := range commits[i].ParentHashes {
+			if j, ok := index[parent]; ok {
+				if children[j]--; children[j] == 0 {
+					ready = append(ready, j)
+					readyAt[j] = turn
+					turn++
+				}
+			}
+		}
+	}
+	return sorted
+}
+
+// limitCommits keeps the first limit commits when limit is positive.
+func limitCommits(commits []Commit, limit int) []Commit {
+	if limit > 0 && len(commits) > limit {
+		return commits[:limit]
+	}
+	return commits
+}
@@ -0,0 +1,144 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// graphCommit is a commit named message with the given parents, committed
+// at hour h.
+func graphCommit(message string, h int, parents ...string) Commit {
+	date := time.Date(2024, 3, 1, h, 0, 0, 0, time.UTC)
+	return Commit{Hash: message, Message: message, ParentHashes: parents, AuthorDate: date, CommitDate: date}
+}
+
+func TestSortCommits(t *testing.T) {
+	// M merges C, branched off A, into B; B's clock ran ahead.
+	skewed := []Commit{
+		graphCommit("B", 9, "A"),
+		graphCommit("M", 8, "B", "C"),
+		graphCommit("C", 7, "A"),
+		graphCommit("A", 1),
+	}
+	// Both sides of M were committed at the same time.
+	tied := []Commit{
+		graphCommit("M", 8, "B2", "C2"),
+		graphCommit("B2", 7, "B1"),
+		graphCommit("C2", 7, "C1"),
+		graphCommit("B1", 6, "A"),
+		graphCommit("C1", 6, "A"),
+		graphCommit("A", 1),
+	}
+
+	tests := []struct {
+		name    string
+		commits []Commit
+		order   string
+		want    []string
+	}{
+		{"unsorted", skewed, "", []string{"B", "M", "C", "A"}},
+		{"date order puts children first", skewed, OrderCommitDate, []string{"M", "B", "C", "A"}},
+		{"topo finishes the merged line first", skewed, OrderTopo, []string{"M", "C", "B", "A"}},
+		{"date ties go to the first parent", tied, OrderCommitDate, []string{"M", "B2", "C2", "B1", "C1", "A"}},
+		{"author date ties", tied, OrderAuthorDate, []string{"M", "B2", "C2", "B1", "C1", "A"}},
+		{"topo with ties", tied, OrderTopo, []string{"M", "C2", "C1", "B2", "B1", "A"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := messages(sortCommits(test.commits, test.order)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("sortCommits(%s) = %q, want %q", test.order, got, test.want)
+			}
+		})
+	}
+
+	if got := messages(limitCommits(skewed, 2)); !reflect.DeepEqual(got, []string{"B", "M"}) {
+		t.Errorf("limitCommits(2) = %q", got)
+	}
+	if got := limitCommits(skewed, 0); len(got) != len(skewed) {
+		t.Errorf("limitCommits(0) kept %d of %d commits", len(got), len(skewed))
+	}
+}
+
+func TestSortMatchesGit(t *testing.T) {
+	// Commits made without a tick share their date.
+	r := newTestRepo(t)
+	r.commit("A", map[string]string{"a.txt": "a\n"})
+	r.git("checkout", "-q", "-b", "side")
+	r.git("commit", "-q", "--allow-empty", "-m", "C1")
+	r.git("commit", "-q", "--allow-empty", "-m", "C2")
+	r.git("checkout", "-q", "main")
+	r.git("commit", "-q", "--allow-empty", "-m", "B1")
+	r.git("commit", "-q", "--allow-empty", "-m", "B2")
+	r.git("merge", "-q", "--no-ff", "-m", "M", "side")
+
+	execRepo := NewExecRepository(r.dir)
+	native, err := NewNativeRepository(r.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsorted, err := execRepo.Log(CommitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := NewMemoryRepository(unsorted, nil)
+
+	for _, order := range Orders() {
+		want, err := execRepo.Log(CommitOptions{Order: order})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, repo := range map[string]Repository{"native": native, "memory": memory} {
+			got, err := repo.Log(CommitOptions{Order: order})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g, w := messages(got), messages(want); !reflect.DeepEqual(g, w) {
+				t.Errorf("%s Log in %s order = %q, git lists %q", name, order, g, w)
+			}
+		}
+	}
+}
+
+// countingCache counts the commits a backend diffed and cached.
+type countingCache struct {
+	mapCache
+	puts int
+}
+
+func (c *countingCache) Put(commit Commit, diffKey string, withFiles bool) {
+	c.puts++
+	c.mapCache.Put(commit, diffKey, withFiles)
+}
+
+func TestNativeSortDiffsKeptCommits(t *testing.T) {
+	r := newTestRepo(t)
+	buildHistory(r)
+	native, err := NewNativeRepository(r.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := &countingCache{mapCache: mapCache{}}
+	native.Cache = cache
+
+	for _, order := range Orders() {
+		options := CommitOptions{Order: order, Limit: 2, ShowFileChanges: true}
+		want, err := NewExecRepository(r.dir).Log(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cache.mapCache, cache.puts = mapCache{}, 0
+		got, err := native.Log(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := commitsDiff(t, got, want); diff != "" {
+			t.Errorf("Log(%+v): %s", options, diff)
+		}
+		// The walk reads every commit to sort them, but diffs only the
+		// two it lists.
+		if cache.puts != 2 {
+			t.Errorf("Log(%+v) diffed %d commits, want 2", options, cache.puts)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// patchContext is the number of unchanged lines shown around each change,
+// git's default for -U.
+const patchContext = 3
+
+// Patch runs git show for the diff against the first parent, which is the
+// diff the commit's FileChanges describe, merges included.
+func (r *ExecRepository) Patch(rev string, paths ...string) (string, error) {
+	args := []string{"show", "--format=", "--patch", "--no-color", "--no-ext-diff", "-M", "--diff-merges=first-parent", rev, "--"}
+	output, err := r.command(append(args, topPaths(paths)...)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to execute git show %s: %v", rev, err)
+	}
+	return strings.TrimLeft(string(output), "\n"), nil
+}
+
+// topPaths anchors repository-relative paths at the top of the work tree,
+// so they mean the same file from any directory.
+func topPaths(paths []string) []string {
+	anchored := make([]string, len(paths))
+	for i, path := range paths {
+		anchored[i] = ":(top,literal)" + path
+	}
+	return anchored
+}
+
+// Patch writes the diff itself from the two trees. Renames are detected
+// among the given paths only, as git does when a pathspec limits the diff.
+func (r *NativeRepository) Patch(rev string, paths ...string) (string, error) {
+	hash, err := r.resolve(rev)
+	if err != nil {
+		return "", err
+	}
+	raw, err := r.readCommit(hash)
+	if err != nil {
+		return "", err
+	}
+	parentTree := ""
+	if len(raw.parents) > 0 {
+		parent, err := r.readCommit(raw.parents[0])
+		if err != nil {
+			return "", err
+		}
+		parentTree = parent.tree
+	}
+
+	var changes []treeChange
+	if err := r.walkTreeDiff(parentTree, raw.tree, "", &changes); err != nil {
+		return "", err
+	}
+	if len(paths) > 0 {
+		wanted := make(map[string]bool, len(paths))
+		for _, path := range paths {
+			wanted[path] = true
+		}
+		kept := changes[:0]
+		for _, change := range changes {
+			if wanted[change.path] {
+				kept = append(kept, change)
+			}
+		}
+		changes = kept
+	}
+	if changes, err = r.detectRenames(changes, CommitOptions{}); err != nil {
+		return "", err
+	}
+
+	var patch strings.Builder
+	for _, change := range changes {
+		oldContent, err := r.blobContent(change.oldHash, change.oldMode)
+		if err != nil {
+			return "", err
+		}
+		newContent, err := r.blobContent(change.newHash, change.newMode)
+		if err != nil {
+			return "", err
+		}
+		writeFilePatch(&patch, change, oldContent, newContent)
+	}
+	return patch.String(), nil
+}
+
+// Patch is not available from memory: fixtures record which files changed,
+// not their contents.
+func (r *MemoryRepository) Patch(rev string, paths ...string) (string, error) {
+	if _, err := r.resolve(rev); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("memory backend has no file contents to diff")
+}
+
+// writeFilePatch writes one file of a patch the way git diff does: the
+// extended header, then the hunks, or a note for binary files.
+func writeFilePatch(patch *strings.Builder, change treeChange, oldContent, newContent []byte) {
+	oldPath, newPath := change.path, change.path
+	if change.oldPath != "" {
+		oldPath = change.oldPath
+	}
+	fmt.Fprintf(patch, "diff --git a/%s b/%s\n", oldPath, newPath)
+
+	switch change.status {
+	case 'A':
+		fmt.Fprintf(patch, "new file mode %s\n", change.newMode)
+	case 'D':
+		fmt.Fprintf(patch, "deleted file mode %s\n", change.oldMode)
+	case 'R', 'C':
+		verb := "rename"
+		if change.status == 'C' {
+			verb = "copy"
+		}
+		fmt.Fprintf(patch, "similarity index %d%%\n%s from %s\n%s to %s\n", change.similarity, verb, oldPath, verb, newPath)
+	}
+	if change.oldMode != "" && change.newMode != "" && change.oldMode != change.newMode {
+		fmt.Fprintf(patch, "old mode %s\nnew mode %s\n", change.oldMode, change.newMode)
+	}
+	if change.oldHash == change.newHash {
+		return // Pure renames and mode changes have no content to show
+	}
+	index := fmt.Sprintf("index %s..%s", abbreviate(change.oldHash), abbreviate(change.newHash))
+	if change.oldMode == change.newMode {
+		index += " " + change.newMode
+	}
+	patch.WriteString(index + "\n")
+
+	from, to := "a/"+oldPath, "b/"+newPath
+	if change.status == 'A' {
+		from = "/dev/null"
+	}
+	if change.status == 'D' {
+		to = "/dev/null"
+	}
+	if isBinary(oldContent) || isBinary(newContent) {
+		fmt.Fprintf(patch, "Binary files %s and %s differ\n", from, to)
+		return
+	}
+	fmt.Fprintf(patch, "--- %s\n+++ %s\n", from, to)
+	writeHunks(patch, splitLines(oldContent), splitLines(newContent), patchContext)
+}
+
+// abbreviate shortens an object name for the index line; missing sides
+// are written as zeros.
+func abbreviate(hash string) string {
+	if hash == "" {
+		return "0000000"
+	}
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// editLine is one line of an edit script with its place in both files.
+type editLine struct {
+	op       diffOp
+	text     string
+	old, new int // Lines of a and b before this one
+}
+
+// writeHunks writes the unified diff of a and b, with context unchanged
+// lines around each change and hunks merged when their context touches.
+func writeHunks(patch *strings.Builder, a, b []string, context int) {
+	// The whole files go to lineEdits: which lines git's diff leaves out
+	// of its search depends on how often they occur in each.
+	ops := lineEdits(a, b)
+
+	lines := make([]editLine, 0, len(ops))
+	x, y := 0, 0
+	for _, op := range ops {
+		line := editLine{op: op, old: x, new: y}
+		switch op {
+		case opEqual:
+			line.text = a[x]
+			x, y = x+1, y+1
+		case opDelete:
+			line.text = a[x]
+			x++
+		case opInsert:
+			line.text = b[y]
+			y++
+		}
+		lines = append(lines, line)
+	}
+
+	for i := 0; i < len(lines); {
+		for i < len(lines) && lines[i].op == opEqual {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+		start := max(i-context, 0)
+		end := i
+		for {
+			for end < len(lines) && lines[end].op != opEqual {
+				end++
+			}
+			next := end
+			for next < len(lines) && lines[next].op == opEqual {
+				next++
+			}
+			if next == len(lines) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		end = min(end+context, len(lines))
+		writeHunk(patch, lines[start:end])
+		i = end
+	}
+}
+
+// writeHunk writes a hunk header and its lines. Like git, a side without
+// lines is numbered from the line before the hunk.
+func writeHunk(patch *strings.Builder, lines []editLine) {
+	oldCount, newCount := 0, 0
+	for _, line := range lines {
+		if line.op != opInsert {
+			oldCount++
+		}
+		if line.op != opDelete {
+			newCount++
+		}
+	}
+	fmt.Fprintf(patch, "@@ -%s +%s @@\n", hunkRange(lines[0].old, oldCount), hunkRange(lines[0].new, newCount))
+
+	for _, line := range lines {
+		mark := " "
+		switch line.op {
+		case opInsert:
+			mark = "+"
+		case opDelete:
+			mark = "-"
+		}
+		patch.WriteString(mark + line.text)
+		if !strings.HasSuffix(line.text, "\n") {
+			patch.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(before, count int) string {
+	start := before + 1
+	if count == 0 {
+		start = before
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
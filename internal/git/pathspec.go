@@ -0,0 +1,175 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// hasPaths reports whether options limit history to some paths.
+func hasPaths(options CommitOptions) bool {
+	return len(options.Paths) > 0 || len(options.ExcludePaths) > 0
+}
+
+// validatePaths checks the path options that git itself would reject.
+func validatePaths(options CommitOptions) error {
+	if options.Follow && len(options.Paths) != 1 {
+		return fmt.Errorf("--follow requires exactly one path")
+	}
+	return nil
+}
+
+// pathspecArgs are the path limits in options as git pathspecs, to follow
+// "--" on a command line.
+func pathspecArgs(options CommitOptions) []string {
+	args := append([]string(nil), options.Paths...)
+	for _, exclude := range options.ExcludePaths {
+		args = append(args, ":(exclude)"+exclude)
+	}
+	return args
+}
+
+// pathspec is the subset of git pathspecs the native and memory backends
+// understand: plain paths (a file, or everything under a directory),
+// wildcards where "*" also matches "/", the ":(exclude)", ":!" and ":^"
+// exclusions and the ":/" and ":(top)" anchors. Patterns are relative to
+// prefix, the current directory inside the worktree.
+type pathspec struct {
+	include []pathPattern
+	exclude []pathPattern
+}
+
+type pathPattern struct {
+	literal string
+	glob    *regexp.Regexp
+}
+
+func newPathspec(prefix string, options CommitOptions) (*pathspec, error) {
+	spec := &pathspec{}
+	add := func(raw string, exclude bool) error {
+		pattern, excluded, err := compilePathPattern(prefix, raw)
+		if err != nil {
+			return err
+		}
+		if excluded || exclude {
+			spec.exclude = append(spec.exclude, pattern)
+		} else {
+			spec.include = append(spec.include, pattern)
+		}
+		return nil
+	}
+	for _, raw := range options.Paths {
+		if err := add(raw, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, raw := range options.ExcludePaths {
+		if err := add(raw, true); err != nil {
+			return nil, err
+		}
+	}
+	return spec, nil
+}
+
+func compilePathPattern(prefix, raw string) (pathPattern, bool, error) {
+	exclude, top := false, false
+	switch {
+	case strings.HasPrefix(raw, ":("):
+		end := strings.Index(raw, ")")
+		if end < 0 {
+			return pathPattern{}, false, fmt.Errorf("invalid pathspec %q: missing ')'", raw)
+		}
+		for _, magic := range strings.Split(raw[2:end], ",") {
+			switch magic {
+			case "exclude":
+				exclude = true
+			case "top":
+				top = true
+			case "":
+			default:
+				return pathPattern{}, false, fmt.Errorf("unsupported pathspec magic %q in %q", magic, raw)
+			}
+		}
+		raw = raw[end+1:]
+	case strings.HasPrefix(raw, ":!"), strings.HasPrefix(raw, ":^"):
+		exclude = true
+		raw = raw[2:]
+	case strings.HasPrefix(raw, ":/"):
+		top = true
+		raw = raw[2:]
+	}
+
+	if !top && prefix != "" {
+		raw = path.Join(prefix, raw)
+	}
+	raw = strings.TrimPrefix(path.Clean("/"+raw), "/")
+
+	if !strings.ContainsAny(raw, "*?[") {
+		return pathPattern{literal: raw}, exclude, nil
+	}
+	glob, err := globRegexp(raw)
+	if err != nil {
+		return pathPattern{}, false, fmt.Errorf("invalid pathspec %q: %v", raw, err)
+	}
+	return pathPattern{glob: glob}, exclude, nil
+}
+
+// globRegexp translates a wildcard pattern the way git matches pathspecs
+// without the glob magic: "*" and "?" also match "/".
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("(/.*)?$")
+	return regexp.Compile(expr.String())
+}
+
+func (p pathPattern) matches(name string) bool {
+	if p.glob != nil {
+		return p.glob.MatchString(name)
+	}
+	return p.literal == "" || name == p.literal || strings.HasPrefix(name, p.literal+"/")
+}
+
+// matches reports whether a file path is selected: it must match some
+// include (or there are none) and no exclude.
+func (s *pathspec) matches(name string) bool {
+	included := len(s.include) == 0
+	for _, pattern := range s.include {
+		if pattern.matches(name) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, pattern := range s.exclude {
+		if pattern.matches(name) {
+			return false
+		}
+	}
+	return true
+}
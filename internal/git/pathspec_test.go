@@ -0,0 +1,83 @@
+package git
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var pathspecFiles = []string{
+	"README.md",
+	"main.go",
+	"cmd/root.go",
+	"cmd/root_test.go",
+	"docs/guide.md",
+	"docs/api/index.md",
+	"docs/api/v1.txt",
+}
+
+func TestPathspec(t *testing.T) {
+	r := newTestRepo(t)
+	files := make(map[string]string)
+	for _, name := range pathspecFiles {
+		files[name] = name + "\n"
+	}
+	r.commit("Initial commit", files)
+
+	for _, test := range []struct {
+		prefix  string
+		paths   []string
+		exclude []string
+		want    []string
+	}{
+		{paths: []string{"docs"}, want: []string{"docs/api/index.md", "docs/api/v1.txt", "docs/guide.md"}},
+		{paths: []string{"*.md"}, want: []string{"README.md", "docs/api/index.md", "docs/guide.md"}},
+		{paths: []string{"cmd/*_test.go", "main.go"}, want: []string{"cmd/root_test.go", "main.go"}},
+		{paths: []string{"docs", ":!*.md"}, want: []string{"docs/api/v1.txt"}},
+		{paths: []string{":(exclude)docs/api"}, want: []string{"README.md", "cmd/root.go", "cmd/root_test.go", "docs/guide.md", "main.go"}},
+		{paths: []string{":^cmd/*", ":!docs/*/*"}, want: []string{"README.md", "docs/guide.md", "main.go"}},
+		{paths: []string{"docs/api/v?.txt", "docs/[gh]*"}, want: []string{"docs/api/v1.txt", "docs/guide.md"}},
+		{exclude: []string{"*.go", "docs/api"}, want: []string{"README.md", "docs/guide.md"}},
+		{prefix: "docs", paths: []string{"*.md", ":!api"}, want: []string{"docs/guide.md"}},
+		{prefix: "docs", paths: []string{":/main.go", ":(top)cmd/root.go"}, want: []string{"cmd/root.go", "main.go"}},
+	} {
+		options := CommitOptions{Paths: test.paths, ExcludePaths: test.exclude}
+		spec, err := newPathspec(test.prefix, options)
+		if err != nil {
+			t.Fatalf("newPathspec(%q, %q, %q): %v", test.prefix, test.paths, test.exclude, err)
+		}
+		var got []string
+		for _, name := range pathspecFiles {
+			if spec.matches(name) {
+				got = append(got, name)
+			}
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("pathspec %q excluding %q in %q matches %q, want %q", test.paths, test.exclude, test.prefix, got, test.want)
+		}
+
+		// git agrees, given the same pathspecs from the same directory.
+		args := append([]string{"-C", test.prefix, "ls-files", "--full-name", "--"}, pathspecArgs(options)...)
+		if test.prefix == "" {
+			args = args[2:]
+		}
+		listed := strings.Fields(r.git(args...))
+		sort.Strings(listed)
+		if !reflect.DeepEqual(listed, test.want) {
+			t.Errorf("git ls-files %q lists %q, want %q", args, listed, test.want)
+		}
+	}
+}
+
+func TestPathspecErrors(t *testing.T) {
+	for _, raw := range []string{":(exclude", ":(glob)*.go", ":(icase,exclude)a"} {
+		if _, err := newPathspec("", CommitOptions{Paths: []string{raw}}); err == nil {
+			t.Errorf("newPathspec(%q) succeeded", raw)
+		}
+	}
+	if err := validatePaths(CommitOptions{Paths: []string{"a", "b"}, Follow: true}); err == nil {
+		t.Error("--follow with two paths was accepted")
+	}
+}
@@ -0,0 +1,270 @@
+package git
+
+import "sort"
+
+// Similarity scores use git's scale, on which maxScore is a perfect match,
+// so thresholds and reported percentages agree with git's -M and -C.
+const (
+	maxScore           = 60000
+	defaultRenameScore = 50
+	// renameLimit bounds the sources × targets matrix for inexact
+	// detection, like git's diff.renameLimit.
+	renameLimit = 1000
+)
+
+// renameMatch is a candidate source for an added file.
+type renameMatch struct {
+	target, source int
+	score          int
+}
+
+// detectRenames pairs added files with the deleted files they were renamed
+// from, and with copy detection on, with the modified files they were
+// copied from. Identical blobs are paired first; the rest are scored on
+// shared content and matched best first, the way git's diffcore-rename
+// does.
+func (r *NativeRepository) detectRenames(changes []treeChange, options CommitOptions) ([]treeChange, error) {
+	if options.NoRenames {
+		return changes, nil
+	}
+	copies := options.CopyThreshold > 0
+
+	var sources, targets []int
+	for i, change := range changes {
+		switch {
+		case change.status == 'A':
+			targets = append(targets, i)
+		case change.status == 'D', copies && change.status == 'M':
+			sources = append(sources, i)
+		}
+	}
+	if len(sources) == 0 || len(targets) == 0 {
+		return changes, nil
+	}
+
+	matched := make(map[int]renameMatch) // By target
+	uses := make(map[int]int)            // By source
+	take := func(m renameMatch) {
+		matched[m.target] = m
+		uses[m.source]++
+	}
+	claimed := func(source int) bool { return uses[source] > 0 }
+
+	// Exact matches: prefer a deleted file nobody claimed yet.
+	bySourceHash := make(map[string][]int)
+	for _, s := range sources {
+		bySourceHash[changes[s].oldHash] = append(bySourceHash[changes[s].oldHash], s)
+	}
+	for _, t := range targets {
+		candidates := bySourceHash[changes[t].newHash]
+		best := -1
+		for _, s := range candidates {
+			if changes[s].status == 'D' && !claimed(s) {
+				best = s
+				break
+			}
+		}
+		if best < 0 && copies && len(candidates) > 0 {
+			best = candidates[0]
+		}
+		if best >= 0 {
+			take(renameMatch{target: t, source: best, score: maxScore})
+		}
+	}
+
+	matches, err := r.scoreRenames(changes, sources, targets, matched, uses, options)
+	if err != nil {
+		return nil, err
+	}
+	minRename := thresholdScore(options.RenameThreshold)
+	minCopy := thresholdScore(options.CopyThreshold)
+	// First every source is used at most once, then copies may reuse them.
+	for _, m := range matches {
+		if _, ok := matched[m.target]; ok || claimed(m.source) {
+			continue
+		}
+		minimum := minCopy
+		if changes[m.source].status == 'D' {
+			minimum = minRename
+		}
+		if m.score >= minimum {
+			take(m)
+		}
+	}
+	if copies {
+		for _, m := range matches {
+			if _, ok := matched[m.target]; !ok && m.score >= minCopy {
+				take(m)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return changes, nil
+	}
+
+	removed := make(map[int]bool)
+	for _, s := range sources {
+		removed[s] = changes[s].status == 'D' && claimed(s)
+	}
+	// A deleted file claimed several times is copied to all but the last
+	// target and renamed to that one; a file that still exists is copied.
+	for _, t := range targets {
+		m, ok := matched[t]
+		if !ok {
+			continue
+		}
+		source := changes[m.source]
+		status := byte('C')
+		if source.status == 'D' {
+			if uses[m.source]--; !claimed(m.source) {
+				status = 'R'
+			}
+		}
+		changes[t].status = status
+		changes[t].oldPath = source.path
+		changes[t].oldHash = source.oldHash
+		changes[t].oldMode = source.oldMode
+		changes[t].similarity = m.score * 100 / maxScore
+	}
+
+	kept := changes[:0]
+	for i, change := range changes {
+		if removed[i] {
+			continue
+		}
+		kept = append(kept, change)
+	}
+	return kept, nil
+}
+
+// thresholdScore converts a percentage threshold to git's score scale.
+func thresholdScore(percent int) int {
+	if percent <= 0 {
+		percent = defaultRenameScore
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	return percent * maxScore / 100
+}
+
+// scoreRenames rates every unmatched target against every source it could
+// have come from and returns the pairs above the lowest threshold in use,
+// best first.
+func (r *NativeRepository) scoreRenames(changes []treeChange, sources, targets []int, matched map[int]renameMatch, uses map[int]int, options CommitOptions) ([]renameMatch, error) {
+	copies := options.CopyThreshold > 0
+	minimum := thresholdScore(options.RenameThreshold)
+	if copies && thresholdScore(options.CopyThreshold) < minimum {
+		minimum = thresholdScore(options.CopyThreshold)
+	}
+
+	var open, candidates []int
+	for _, t := range targets {
+		if _, ok := matched[t]; !ok && changes[t].newMode != "120000" && changes[t].newMode != "160000" {
+			open = append(open, t)
+		}
+	}
+	for _, s := range sources {
+		if (copies || uses[s] == 0) && changes[s].oldMode != "120000" && changes[s].oldMode != "160000" {
+			candidates = append(candidates, s)
+		}
+	}
+	if len(open) == 0 || len(candidates) == 0 || len(open)*len(candidates) > renameLimit*renameLimit {
+		return nil, nil
+	}
+
+	spans := make(map[string]*spanCounts)
+	load := func(hash string) (*spanCounts, error) {
+		if counts, ok := spans[hash]; ok {
+			return counts, nil
+		}
+		content, err := r.odb.readTyped(hash, objBlob)
+		if err != nil {
+			return nil, err
+		}
+		counts := countSpans(content)
+		spans[hash] = counts
+		return counts, nil
+	}
+
+	var matches []renameMatch
+	for _, t := range open {
+		dst, err := load(changes[t].newHash)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range candidates {
+			src, err := load(changes[s].oldHash)
+			if err != nil {
+				return nil, err
+			}
+			if score := similarity(src, dst, minimum); score >= minimum {
+				matches = append(matches, renameMatch{target: t, source: s, score: score})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	return matches, nil
+}
+
+// spanCounts is the content fingerprint git compares for inexact renames:
+// the number of bytes in each hashed span of a blob. A span ends at a
+// newline or after 64 bytes.
+type spanCounts struct {
+	size  int
+	bytes map[uint32]int
+}
+
+// spanHashBase is the modulus git folds span hashes into.
+const spanHashBase = 107927
+
+func countSpans(content []byte) *spanCounts {
+	counts := &spanCounts{size: len(content), bytes: make(map[uint32]int)}
+	text := !isBinary(content)
+
+	var accum1, accum2 uint32
+	n := 0
+	for i, c := range content {
+		// CR in a CRLF pair does not count for text files.
+		if text && c == '\r' && i+1 < len(content) && content[i+1] == '\n' {
+			continue
+		}
+		old := accum1
+		accum1 = (accum1 << 7) ^ (accum2 >> 25)
+		accum2 = (accum2 << 7) ^ (old >> 25)
+		accum1 += uint32(c)
+		if n++; n < 64 && c != '\n' {
+			continue
+		}
+		counts.bytes[(accum1+accum2*0x61)%spanHashBase] += n
+		n, accum1, accum2 = 0, 0, 0
+	}
+	if n > 0 {
+		counts.bytes[(accum1+accum2*0x61)%spanHashBase] += n
+	}
+	return counts
+}
+
+// similarity scores how much of dst was copied from src, relative to the
+// larger of the two. Pairs whose sizes alone rule out reaching minimum
+// score zero without comparing content.
+func similarity(src, dst *spanCounts, minimum int) int {
+	maxSize, baseSize := src.size, dst.size
+	if baseSize > maxSize {
+		maxSize, baseSize = baseSize, maxSize
+	}
+	if dst.size == 0 || maxSize*(maxScore-minimum) < (maxSize-baseSize)*maxScore {
+		return 0
+	}
+
+	copied := 0
+	for hash, srcCount := range src.bytes {
+		dstCount := dst.bytes[hash]
+		if srcCount < dstCount {
+			copied += srcCount
+		} else {
+			copied += dstCount
+		}
+	}
+	return copied * maxScore / maxSize
+}
@@ -0,0 +1,85 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Repository is the read-only view of a git repository that the formatters
+// and the web renderer work against. Backends decide how history is read:
+// by running the git binary, by reading the object database directly, or
+// from fixtures held in memory.
+type Repository interface {
+	// Log returns the commits selected by options, newest first.
+	Log(options CommitOptions) ([]Commit, error)
+	// Stream is Log without buffering: commits are yielded as they are
+	// read, until the iterator is exhausted or ctx is cancelled.
+	Stream(ctx context.Context, options CommitOptions) (CommitIterator, error)
+	// Show returns a single commit with its stats and file changes.
+	Show(rev string) (*Commit, error)
+	// Refs lists branches, remote branches and tags.
+	Refs() ([]Ref, error)
+	// Tags lists the tags pointing at commits, with their dates and the
+	// messages of annotated tags.
+	Tags() ([]Tag, error)
+	// Diff returns the files changed between two revisions.
+	Diff(from, to string) ([]FileChange, error)
+	// Patch returns the unified diff of a commit against its first
+	// parent, limited to paths when any are given. A renamed file needs
+	// both its old and new path.
+	Patch(rev string, paths ...string) (string, error)
+	// Blame attributes every line of path at rev to the commit that last
+	// changed it.
+	Blame(path, rev string) ([]BlameLine, error)
+}
+
+// Ref is a named pointer into the commit graph.
+type Ref struct {
+	Name   string // Full name, e.g. refs/heads/main or refs/tags/v1.0.0
+	Hash   string // Object the ref points at
+	Target string // Peeled commit for annotated tags, empty otherwise
+}
+
+// Tag is a tag on a commit, annotated or lightweight.
+type Tag struct {
+	Name      string    // Short name, e.g. v1.0.0
+	Commit    string    // Commit the tag points at, annotated tags peeled
+	Date      time.Time // Tagger date, or the commit date for lightweight tags
+	Message   string    // Message of an annotated tag
+	Annotated bool
+}
+
+// BlameLine is one line of a file together with the commit that introduced it.
+type BlameLine struct {
+	LineNumber  int
+	Content     string
+	Hash        string
+	AuthorName  string
+	AuthorEmail string
+	AuthorDate  time.Time
+}
+
+// Backends accepted by Open.
+const (
+	BackendExec   = "exec"
+	BackendNative = "native"
+)
+
+// Open returns the repository backend with the given name for the working
+// tree at dir. An empty name selects the exec backend.
+func Open(backend, dir string) (Repository, error) {
+	switch backend {
+	case "", BackendExec:
+		return NewExecRepository(dir), nil
+	case BackendNative:
+		return NewNativeRepository(dir)
+	default:
+		return nil, fmt.Errorf("unknown backend %q (available: %s, %s)", backend, BackendExec, BackendNative)
+	}
+}
+
+// GetCommits reads history by running git in the current directory.
+func GetCommits(options CommitOptions) ([]Commit, error) {
+	return NewExecRepository("").Log(options)
+}
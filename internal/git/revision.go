@@ -0,0 +1,84 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// revisionRange is a set of revision expressions taken apart: commits
+// reachable from include but not from exclude, plus for every A...B pair
+// the commits reachable from either side but not from both.
+type revisionRange struct {
+	include   []string
+	exclude   []string
+	symmetric [][2]string
+}
+
+// revisionExprs lists the revisions selected by options, Branch first.
+func revisionExprs(options CommitOptions) []string {
+	var revs []string
+	if options.Branch != "" {
+		revs = append(revs, options.Branch)
+	}
+	return append(revs, options.Revisions...)
+}
+
+// parseRevisions splits revision expressions into the endpoints a backend
+// has to resolve. With no expressions at all, history starts at HEAD.
+func parseRevisions(revs []string) (revisionRange, error) {
+	var r revisionRange
+	if len(revs) == 0 {
+		r.include = []string{"HEAD"}
+		return r, nil
+	}
+
+	endpoint := func(name string) string {
+		if name == "" {
+			return "HEAD"
+		}
+		return name
+	}
+	for _, expr := range revs {
+		switch {
+		case strings.TrimSpace(expr) == "":
+			return r, fmt.Errorf("empty revision")
+		case strings.HasPrefix(expr, "-"):
+			return r, fmt.Errorf("invalid revision %q: revisions cannot start with '-'", expr)
+		case strings.Contains(expr, "..."):
+			from, to, _ := strings.Cut(expr, "...")
+			r.symmetric = append(r.symmetric, [2]string{endpoint(from), endpoint(to)})
+		case strings.Contains(expr, ".."):
+			from, to, _ := strings.Cut(expr, "..")
+			r.exclude = append(r.exclude, endpoint(from))
+			r.include = append(r.include, endpoint(to))
+		case strings.HasPrefix(expr, "^"):
+			r.exclude = append(r.exclude, expr[1:])
+		default:
+			r.include = append(r.include, expr)
+		}
+	}
+	return r, nil
+}
+
+// endpoints lists every revision name in the range, for validation.
+func (r revisionRange) endpoints() []string {
+	names := append(append([]string(nil), r.include...), r.exclude...)
+	for _, pair := range r.symmetric {
+		names = append(names, pair[0], pair[1])
+	}
+	return names
+}
+
+// DescribeRange is the human-readable selection options make, for the
+// headers of listings: "v1.0..v1.1", "main (first parent)", or empty when
+// the default history of HEAD is shown.
+func DescribeRange(options CommitOptions) string {
+	desc := strings.Join(revisionExprs(options), " ")
+	if options.FirstParent {
+		if desc == "" {
+			desc = "HEAD"
+		}
+		desc += " (first parent)"
+	}
+	return desc
+}
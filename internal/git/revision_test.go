@@ -0,0 +1,117 @@
+package git
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// messages lists the commits by message, in order.
+func messages(commits []Commit) []string {
+	names := make([]string, len(commits))
+	for i, commit := range commits {
+		names[i] = commit.Message
+	}
+	return names
+}
+
+func TestParseRevisions(t *testing.T) {
+	for _, test := range []struct {
+		revs []string
+		want revisionRange
+	}{
+		{nil, revisionRange{include: []string{"HEAD"}}},
+		{[]string{"v1.0..main"}, revisionRange{include: []string{"main"}, exclude: []string{"v1.0"}}},
+		{[]string{"v1.0.."}, revisionRange{include: []string{"HEAD"}, exclude: []string{"v1.0"}}},
+		{[]string{"main", "^v1.0", "feature"}, revisionRange{include: []string{"main", "feature"}, exclude: []string{"v1.0"}}},
+		{[]string{"main...feature"}, revisionRange{symmetric: [][2]string{{"main", "feature"}}}},
+		{[]string{"...feature"}, revisionRange{symmetric: [][2]string{{"HEAD", "feature"}}}},
+	} {
+		got, err := parseRevisions(test.revs)
+		if err != nil {
+			t.Errorf("parseRevisions(%q): %v", test.revs, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseRevisions(%q) = %+v, want %+v", test.revs, got, test.want)
+		}
+	}
+
+	for _, revs := range [][]string{{""}, {" "}, {"--all"}, {"main", "-n1"}} {
+		if _, err := parseRevisions(revs); err == nil {
+			t.Errorf("parseRevisions(%q) succeeded", revs)
+		}
+	}
+}
+
+func TestRevisionsMatchGit(t *testing.T) {
+	// other is an unrelated history, with no merge base with main.
+	r := newTestRepo(t)
+	r.commit("A", map[string]string{"a.txt": "a\n"})
+	r.commit("B", map[string]string{"b.txt": "b\n"})
+	r.git("checkout", "-q", "-b", "feature")
+	r.commit("F", map[string]string{"f.txt": "f\n"})
+	r.git("checkout", "-q", "main")
+	r.commit("C", map[string]string{"c.txt": "c\n"})
+	r.git("checkout", "-q", "--orphan", "other")
+	r.commit("O1", map[string]string{"o.txt": "o\n"})
+	r.commit("O2", map[string]string{"o.txt": "oo\n"})
+	r.git("checkout", "-q", "main")
+
+	execRepo := NewExecRepository(r.dir)
+	native, err := NewNativeRepository(r.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := execRepo.Log(CommitOptions{Revisions: []string{"main", "feature", "other"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, err := execRepo.Refs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := NewMemoryRepository(all, refs)
+	backends := map[string]Repository{"exec": execRepo, "native": native, "memory": memory}
+
+	for _, test := range []struct {
+		revs []string
+		want []string
+	}{
+		{[]string{"main...feature"}, []string{"C", "F"}},
+		{[]string{"main...other"}, []string{"O2", "O1", "C", "B", "A"}},
+		{[]string{"other...main"}, []string{"O2", "O1", "C", "B", "A"}},
+		{[]string{"main..other"}, []string{"O2", "O1"}},
+		{[]string{"other", "^main"}, []string{"O2", "O1"}},
+		{[]string{"feature...other", "^main"}, []string{"O2", "O1", "F"}},
+	} {
+		for name, repo := range backends {
+			got, err := repo.Log(CommitOptions{Revisions: test.revs})
+			if err != nil {
+				t.Errorf("%s Log(%q): %v", name, test.revs, err)
+				continue
+			}
+			if !reflect.DeepEqual(messages(got), test.want) {
+				t.Errorf("%s Log(%q) = %q, want %q", name, test.revs, messages(got), test.want)
+			}
+		}
+	}
+
+	for _, revs := range [][]string{{"nowhere"}, {"main..nowhere"}, {"nowhere...main"}, {"^nowhere"}} {
+		for name, repo := range backends {
+			if _, err := repo.Log(CommitOptions{Revisions: revs}); err == nil {
+				t.Errorf("%s Log(%q) of an unknown revision succeeded", name, revs)
+			}
+			it, err := repo.Stream(context.Background(), CommitOptions{Revisions: revs})
+			if err == nil {
+				for it.Next() {
+				}
+				err = it.Err()
+				it.Close()
+			}
+			if err == nil {
+				t.Errorf("%s Stream(%q) of an unknown revision succeeded", name, revs)
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// GrepPattern compiles the --grep patterns in options into one expression
+// matching any of them, or returns nil when there are none. Formatters use
+// it to highlight what matched.
+func GrepPattern(options CommitOptions) (*regexp.Regexp, error) {
+	if len(options.Grep) == 0 {
+		return nil, nil
+	}
+	parts := make([]string, len(options.Grep))
+	for i, pattern := range options.Grep {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid grep pattern %q: %v", pattern, err)
+		}
+		parts[i] = "(?:" + pattern + ")"
+	}
+	expr := strings.Join(parts, "|")
+	if options.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// matchesGrep reports whether a commit message passes --grep: some line
+// matches a pattern, or with --invert-grep, none does.
+func matchesGrep(pattern *regexp.Regexp, message string, invert bool) bool {
+	if pattern == nil {
+		return true
+	}
+	matched := false
+	for _, line := range strings.Split(message, "\n") {
+		if pattern.MatchString(line) {
+			matched = true
+			break
+		}
+	}
+	return matched != invert
+}
+
+// authorPattern compiles --author, honouring -i.
+func authorPattern(options CommitOptions) (*regexp.Regexp, error) {
+	if options.Author == "" {
+		return nil, nil
+	}
+	expr := options.Author
+	if options.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid author pattern: %v", err)
+	}
+	return pattern, nil
+}
+
+// matchesAuthor reports whether --author matches the commit's author or
+// one of its co-authors, as "Name <email>".
+func matchesAuthor(pattern *regexp.Regexp, commit Commit) bool {
+	for _, person := range commit.Authors() {
+		if pattern.MatchString(person.Name + " <" + person.Email + ">") {
+			return true
+		}
+	}
+	return false
+}
+
+// searchArgs are the git log options for message and pickaxe search. perl
+// says whether git can match --grep as Perl regular expressions.
+func searchArgs(options CommitOptions, perl bool) []string {
+	var args []string
+	for _, pattern := range options.Grep {
+		args = append(args, "--grep="+pattern)
+	}
+	if len(options.Grep) > 0 {
+		// GrepPattern checks the patterns, and formatters highlight them,
+		// with Go's regexp. Perl syntax agrees with it on \d, \w and (?i);
+		// without PCRE git has only POSIX extended expressions, which
+		// share the rest.
+		if perl {
+			args = append(args, "--perl-regexp")
+		} else {
+			args = append(args, "--extended-regexp")
+		}
+	}
+	if options.InvertGrep {
+		args = append(args, "--invert-grep")
+	}
+	if options.IgnoreCase {
+		args = append(args, "--regexp-ignore-case")
+	}
+	if options.Pickaxe != "" {
+		args = append(args, "-S"+options.Pickaxe)
+	}
+	if options.PickaxeRegex != "" {
+		args = append(args, "-G"+options.PickaxeRegex)
+	}
+	return args
+}
+
+// perlRegexps reports whether git was built with PCRE, which --perl-regexp
+// needs. git compiles the patterns before it walks, so an empty walk over
+// --all answers without reading history, in empty repositories too.
+func (r *ExecRepository) perlRegexps() bool {
+	r.perlOnce.Do(func() {
+		r.perl = r.command("log", "--max-count=0", "--all", "--perl-regexp", "--grep=.").Run() == nil
+	})
+	return r.perl
+}
+
+func hasPickaxe(options CommitOptions) bool {
+	return options.Pickaxe != "" || options.PickaxeRegex != ""
+}
+
+// pickaxe decides whether one file change adds or removes the searched
+// text: -S compares how often the string occurs before and after, -G looks
+// for the regular expression in the added and removed lines.
+type pickaxe struct {
+	str     []byte
+	strFold *regexp.Regexp // -S with -i
+	regex   *regexp.Regexp // -G
+}
+
+func newPickaxe(options CommitOptions) (*pickaxe, error) {
+	if !hasPickaxe(options) {
+		return nil, nil
+	}
+	if options.Pickaxe != "" && options.PickaxeRegex != "" {
+		return nil, fmt.Errorf("-S and -G cannot be used together")
+	}
+	p := &pickaxe{str: []byte(options.Pickaxe)}
+	if options.Pickaxe != "" && options.IgnoreCase {
+		p.strFold = regexp.MustCompile("(?i)" + regexp.QuoteMeta(options.Pickaxe))
+	}
+	if options.PickaxeRegex != "" {
+		expr := "(?m)" + options.PickaxeRegex // ^ and $ match at lines, as in git
+		if options.IgnoreCase {
+			expr = "(?i)" + expr
+		}
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -G pattern %q: %v", options.PickaxeRegex, err)
+		}
+		p.regex = regex
+	}
+	return p, nil
+}
+
+// markPickaxe flags the file changes of commit that matched a pickaxe
+// search, given the matched paths per commit.
+func markPickaxe(commit *Commit, matches map[string]map[string]bool) {
+	files := matches[commit.Hash]
+	if len(files) == 0 || len(commit.FileChanges) == 0 {
+		return
+	}
+	// The changes may be shared with a cache, so mark a copy.
+	changes := append([]FileChange(nil), commit.FileChanges...)
+	for i := range changes {
+		changes[i].PickaxeMatch = files[changes[i].FilePath]
+	}
+	commit.FileChanges = changes
+}
+
+// pickaxeIterator marks pickaxe matches on the commits of another iterator.
+type pickaxeIterator struct {
+	CommitIterator
+	matches map[string]map[string]bool
+}
+
+func (it *pickaxeIterator) Commit() Commit {
+	commit := it.CommitIterator.Commit()
+	markPickaxe(&commit, it.matches)
+	return commit
+}
+
+func (p *pickaxe) count(content []byte) int {
+	if p.strFold != nil {
+		return len(p.strFold.FindAllIndex(content, -1))
+	}
+	return bytes.Count(content, p.str)
+}
+
+// matches reports whether the change from old to new content, either of
+// which is nil for an added or deleted file, is picked up by the search.
+// Like git, -G skips binary files.
+func (p *pickaxe) matches(old, new []byte) bool {
+	if p.regex == nil {
+		return p.count(old) != p.count(new)
+	}
+	if isBinary(old) || isBinary(new) {
+		return false
+	}
+	if old == nil {
+		return p.regex.Match(new)
+	}
+	if new == nil {
+		return p.regex.Match(old)
+	}
+
+	a, b := splitLines(old), splitLines(new)
+	i, j := 0, 0
+	for _, op := range lineEdits(a, b) {
+		switch op {
+		case opEqual:
+			i++
+			j++
+		case opInsert:
+			if p.regex.MatchString(strings.TrimSuffix(b[j], "\n")) {
+				return true
+			}
+			j++
+		case opDelete:
+			if p.regex.MatchString(strings.TrimSuffix(a[i], "\n")) {
+				return true
+			}
+			i++
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package git
+
+import (
+	"sort"
+	"strings"
+)
+
+// TagsByCommit maps commit hashes to the names of the tags pointing at
+// them, with annotated tags peeled to their commit. Names are sorted.
+func TagsByCommit(repo Repository) (map[string][]string, error) {
+	refs, err := repo.Refs()
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[string][]string)
+	for _, ref := range refs {
+		name, ok := strings.CutPrefix(ref.Name, "refs/tags/")
+		if !ok {
+			continue
+		}
+		hash := ref.Hash
+		if ref.Target != "" {
+			hash = ref.Target
+		}
+		tags[hash] = append(tags[hash], name)
+	}
+	for _, names := range tags {
+		sort.Strings(names)
+	}
+	return tags, nil
+}
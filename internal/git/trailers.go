@@ -0,0 +1,125 @@
+package git
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Trailer is a "Key: value" line at the end of a commit message, as git
+// interpret-trailers reads them: Signed-off-by, Co-authored-by, Fixes...
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// Person is someone credited with a commit.
+type Person struct {
+	Name  string
+	Email string
+}
+
+// CoAuthorKey is the trailer naming additional authors of a commit.
+const CoAuthorKey = "Co-authored-by"
+
+// trailerPattern matches "Key: value" and the "Key #value" form
+// Conventional Commits allows for issue references.
+var trailerPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*|BREAKING CHANGE)(?::\s*(.*)|\s+(#.*))$`)
+
+// ParseTrailers reads the trailers in the last paragraph of a commit
+// body. The paragraph only counts when every line is a trailer or the
+// indented continuation of one; otherwise the body has no trailers.
+func ParseTrailers(body string) []Trailer {
+	_, paragraph := splitTrailers(body)
+	if paragraph == "" {
+		return nil
+	}
+	var trailers []Trailer
+	for _, line := range strings.Split(paragraph, "\n") {
+		if line != "" && (line[0] == ' ' || line[0] == '\t') {
+			last := &trailers[len(trailers)-1]
+			last.Value = strings.TrimSpace(last.Value + " " + strings.TrimSpace(line))
+			continue
+		}
+		m := trailerPattern.FindStringSubmatch(strings.TrimRight(line, " \t\r"))
+		trailers = append(trailers, Trailer{Key: m[1], Value: strings.TrimSpace(m[2] + m[3])})
+	}
+	return trailers
+}
+
+// splitTrailers separates a body into its text and its trailer paragraph,
+// which is empty when the body ends with ordinary text.
+func splitTrailers(body string) (text, trailers string) {
+	body = strings.TrimSpace(body)
+	start := strings.LastIndex(body, "\n\n")
+	paragraph := body[start+1:]
+	if start < 0 {
+		paragraph = body
+	}
+	paragraph = strings.TrimLeft(paragraph, "\n")
+
+	lines := strings.Split(paragraph, "\n")
+	for i, line := range lines {
+		continuation := line != "" && (line[0] == ' ' || line[0] == '\t')
+		if (i == 0 || !continuation) && !trailerPattern.MatchString(strings.TrimRight(line, " \t\r")) {
+			return body, ""
+		}
+	}
+	if start < 0 {
+		return "", paragraph
+	}
+	return strings.TrimSpace(body[:start]), paragraph
+}
+
+// BodyText is the body without its trailers.
+func (c Commit) BodyText() string {
+	text, _ := splitTrailers(c.Body)
+	return text
+}
+
+// CoAuthors lists the people in the commit's Co-authored-by trailers.
+func (c Commit) CoAuthors() []Person {
+	var people []Person
+	for _, trailer := range c.Trailers {
+		if strings.EqualFold(trailer.Key, CoAuthorKey) {
+			people = append(people, ParsePerson(trailer.Value))
+		}
+	}
+	return people
+}
+
+// Authors lists the commit's author followed by its co-authors, each once.
+func (c Commit) Authors() []Person {
+	people := []Person{{Name: c.AuthorName, Email: c.AuthorEmail}}
+	for _, person := range c.CoAuthors() {
+		duplicate := false
+		for _, other := range people {
+			if strings.EqualFold(person.Email, other.Email) && person.Email != "" || person == other {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			people = append(people, person)
+		}
+	}
+	return people
+}
+
+// ParsePerson reads "Name <email>"; without angle brackets the whole value
+// is the name.
+func ParsePerson(value string) Person {
+	open := strings.LastIndexByte(value, '<')
+	closing := strings.LastIndexByte(value, '>')
+	if open < 0 || closing < open {
+		return Person{Name: strings.TrimSpace(value)}
+	}
+	return Person{Name: strings.TrimSpace(value[:open]), Email: strings.TrimSpace(value[open+1 : closing])}
+}
+
+// String formats the person the way git does, "Name <email>".
+func (p Person) String() string {
+	if p.Email == "" {
+		return p.Name
+	}
+	return p.Name + " <" + p.Email + ">"
+}
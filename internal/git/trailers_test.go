@@ -0,0 +1,113 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTrailers(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		body string
+		text string
+		want []Trailer
+	}{
+		{
+			name: "none",
+			body: "Explain the change.\n\nAnd why.",
+			text: "Explain the change.\n\nAnd why.",
+		},
+		{
+			name: "signed off",
+			body: "Explain the change.\n\nSigned-off-by: Ann <ann@example.com>\nFixes #12",
+			text: "Explain the change.",
+			want: []Trailer{{"Signed-off-by", "Ann <ann@example.com>"}, {"Fixes", "#12"}},
+		},
+		{
+			name: "continuation lines",
+			body: "Explain.\n\nBREAKING CHANGE: the flag is gone\n  and so is its config key\nReviewed-by: Bob <bob@example.com>",
+			text: "Explain.",
+			want: []Trailer{
+				{"BREAKING CHANGE", "the flag is gone and so is its config key"},
+				{"Reviewed-by", "Bob <bob@example.com>"},
+			},
+		},
+		{
+			name: "final paragraph is not all trailers",
+			body: "Explain.\n\nSigned-off-by: Ann <ann@example.com>\nthen some more prose",
+			text: "Explain.\n\nSigned-off-by: Ann <ann@example.com>\nthen some more prose",
+		},
+		{
+			name: "trailers before the final paragraph",
+			body: "Explain.\n\nSigned-off-by: Ann <ann@example.com>\n\nA closing note.",
+			text: "Explain.\n\nSigned-off-by: Ann <ann@example.com>\n\nA closing note.",
+		},
+		{
+			name: "paragraph starts with a continuation",
+			body: "Explain.\n\n  indented: quote",
+			text: "Explain.\n\n  indented: quote",
+		},
+		{
+			name: "co-authors",
+			body: "Pair on it.\n\nCo-authored-by: Cy <cy@example.com>\nco-authored-by: Di <di@example.com>",
+			text: "Pair on it.",
+			want: []Trailer{{CoAuthorKey, "Cy <cy@example.com>"}, {"co-authored-by", "Di <di@example.com>"}},
+		},
+		{
+			name: "only trailers",
+			body: "\nCo-authored-by: Cy <cy@example.com>\nRefs: #7\n",
+			want: []Trailer{{CoAuthorKey, "Cy <cy@example.com>"}, {"Refs", "#7"}},
+		},
+		{
+			name: "empty",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ParseTrailers(test.body); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseTrailers = %q, want %q", got, test.want)
+			}
+			if got := (Commit{Body: test.body}).BodyText(); got != test.text {
+				t.Errorf("BodyText = %q, want %q", got, test.text)
+			}
+		})
+	}
+}
+
+func TestAuthors(t *testing.T) {
+	commit := Commit{
+		AuthorName:  "Ann",
+		AuthorEmail: "ann@example.com",
+		Trailers: []Trailer{
+			{CoAuthorKey, "Cy <cy@example.com>"},
+			{"Signed-off-by", "Bob <bob@example.com>"},
+			{"co-authored-by", "Ann Again <ANN@example.com>"},
+			{CoAuthorKey, "Cy <cy@example.com>"},
+			{CoAuthorKey, "Di"},
+		},
+	}
+	want := []Person{{"Ann", "ann@example.com"}, {"Cy", "cy@example.com"}, {"Di", ""}}
+	if got := commit.Authors(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Authors = %v, want %v", got, want)
+	}
+	if got := len(commit.CoAuthors()); got != 4 {
+		t.Errorf("CoAuthors found %d people, want 4", got)
+	}
+}
+
+func TestParsePerson(t *testing.T) {
+	for value, want := range map[string]Person{
+		"Ann <ann@example.com>":       {"Ann", "ann@example.com"},
+		"  Ann B.  < ann@b.org > ":    {"Ann B.", "ann@b.org"},
+		"Ann <old> <ann@example.com>": {"Ann <old>", "ann@example.com"},
+		"Ann":                         {"Ann", ""},
+		"Ann > x <":                   {"Ann > x <", ""},
+	} {
+		got := ParsePerson(value)
+		if got != want {
+			t.Errorf("ParsePerson(%q) = %+v, want %+v", value, got, want)
+		}
+		if want.Email != "" && ParsePerson(got.String()) != got {
+			t.Errorf("%q does not read back as %+v", got.String(), got)
+		}
+	}
+}
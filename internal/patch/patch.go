@@ -0,0 +1,351 @@
+// Package patch reads the unified diffs git.Repository.Patch returns into
+// files, hunks and lines, and prepares them for display: changed lines
+// paired up for side-by-side and word diffs, long hunks folded and code
+// highlighted by language.
+package patch
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Kind is what a diff line, or a part of one, does.
+type Kind int
+
+const (
+	Context Kind = iota // Unchanged, on both sides
+	Added
+	Deleted
+	Folded // Stands for lines left out of a long hunk
+)
+
+// Line is one line of a hunk.
+type Line struct {
+	Kind Kind
+	Text string // Without the +, - or space in front
+	// Old and New number the line on each side, zero on the side it is
+	// not on.
+	Old, New int
+	// NoNewline is set on the last line of a file without a final newline.
+	NoNewline bool
+	// Hidden counts the lines a Folded line stands for.
+	Hidden int
+}
+
+// Hunk is a stretch of changes with the unchanged lines around them.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Section            string // The enclosing function or heading git found, if any
+	Lines              []Line
+}
+
+// Header is the "@@ -1,4 +1,5 @@" line of the hunk, without the section.
+func (h Hunk) Header() string {
+	return "@@ -" + hunkRange(h.OldStart, h.OldLines) + " +" + hunkRange(h.NewStart, h.NewLines) + " @@"
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return strconv.Itoa(start)
+	}
+	return strconv.Itoa(start) + "," + strconv.Itoa(lines)
+}
+
+// File is the diff of one file.
+type File struct {
+	// OldPath and NewPath are empty on the side the file does not exist,
+	// and differ for renames and copies.
+	OldPath, NewPath string
+	Status           string // Added, Modified, Deleted, Renamed or Copied, as in git.FileChange
+	Similarity       int    // Percent, for renames and copies
+	OldMode, NewMode string // Set when the mode changed or the file was added or deleted
+	Binary           bool
+	Hunks            []Hunk
+}
+
+// Path is where the file is after the change, or was before a deletion.
+func (f File) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// Counts are the lines the file's hunks add and delete.
+func (f File) Counts() (insertions, deletions int) {
+	for _, hunk := range f.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case Added:
+				insertions++
+			case Deleted:
+				deletions++
+			}
+		}
+	}
+	return insertions, deletions
+}
+
+// Parse reads a patch in git's format, as git show or git diff write it
+// without colours. Lines it does not understand are skipped.
+func Parse(text string) []File {
+	var files []File
+	var file *File
+	var hunk *Hunk
+	// Lines each side of the current hunk still has to come.
+	oldLeft, newLeft := 0, 0
+	oldLine, newLine := 0, 0
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for _, line := range lines {
+		if hunk != nil && (oldLeft > 0 || newLeft > 0 || strings.HasPrefix(line, `\`)) {
+			switch {
+			case strings.HasPrefix(line, " ") || line == "":
+				hunk.Lines = append(hunk.Lines, Line{Kind: Context, Text: strings.TrimPrefix(line, " "), Old: oldLine, New: newLine})
+				oldLine, newLine = oldLine+1, newLine+1
+				oldLeft, newLeft = oldLeft-1, newLeft-1
+				continue
+			case strings.HasPrefix(line, "-"):
+				hunk.Lines = append(hunk.Lines, Line{Kind: Deleted, Text: line[1:], Old: oldLine})
+				oldLine, oldLeft = oldLine+1, oldLeft-1
+				continue
+			case strings.HasPrefix(line, "+"):
+				hunk.Lines = append(hunk.Lines, Line{Kind: Added, Text: line[1:], New: newLine})
+				newLine, newLeft = newLine+1, newLeft-1
+				continue
+			case strings.HasPrefix(line, `\`):
+				if n := len(hunk.Lines); n > 0 {
+					hunk.Lines[n-1].NoNewline = true
+				}
+				continue
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files = append(files, gitHeaderPaths(line[len("diff --git "):]))
+			file, hunk = &files[len(files)-1], nil
+		case file == nil:
+		case strings.HasPrefix(line, "@@ "):
+			h, ok := parseHunkHeader(line)
+			if !ok {
+				continue
+			}
+			file.Hunks = append(file.Hunks, h)
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			oldLeft, newLeft = h.OldLines, h.NewLines
+			oldLine, newLine = h.OldStart, h.NewStart
+		case hunk != nil:
+			// The hunks have ended without another file starting.
+		case strings.HasPrefix(line, "new file mode "):
+			file.Status, file.NewMode, file.OldPath = "Added", line[len("new file mode "):], ""
+		case strings.HasPrefix(line, "deleted file mode "):
+			file.Status, file.OldMode, file.NewPath = "Deleted", line[len("deleted file mode "):], ""
+		case strings.HasPrefix(line, "old mode "):
+			file.OldMode = line[len("old mode "):]
+		case strings.HasPrefix(line, "new mode "):
+			file.NewMode = line[len("new mode "):]
+		case strings.HasPrefix(line, "similarity index "):
+			file.Similarity, _ = strconv.Atoi(strings.TrimSuffix(line[len("similarity index "):], "%"))
+		case strings.HasPrefix(line, "rename from "):
+			file.Status, file.OldPath = "Renamed", unquote(line[len("rename from "):])
+		case strings.HasPrefix(line, "rename to "):
+			file.NewPath = unquote(line[len("rename to "):])
+		case strings.HasPrefix(line, "copy from "):
+			file.Status, file.OldPath = "Copied", unquote(line[len("copy from "):])
+		case strings.HasPrefix(line, "copy to "):
+			file.NewPath = unquote(line[len("copy to "):])
+		case strings.HasPrefix(line, "Binary files "):
+			file.Binary = true
+		case strings.HasPrefix(line, "--- "):
+			file.OldPath = sidePath(line[len("--- "):], "a/")
+		case strings.HasPrefix(line, "+++ "):
+			file.NewPath = sidePath(line[len("+++ "):], "b/")
+		}
+	}
+	return files
+}
+
+// gitHeaderPaths starts a file from the paths on its "diff --git" line.
+// They are only a guess when they contain spaces; the lines after the
+// header name them for certain.
+func gitHeaderPaths(paths string) File {
+	file := File{Status: "Modified"}
+	if strings.HasPrefix(paths, `"`) {
+		if end := closingQuote(paths); end > 0 {
+			file.OldPath = sidePath(paths[:end+1], "a/")
+			file.NewPath = sidePath(strings.TrimSpace(paths[end+1:]), "b/")
+			return file
+		}
+	}
+	// With the same path on both sides the line is "a/p b/p".
+	if half := (len(paths) - 1) / 2; len(paths)%2 == 1 && paths[half] == ' ' &&
+		strings.TrimPrefix(paths[:half], "a/") == strings.TrimPrefix(paths[half+1:], "b/") {
+		file.OldPath = sidePath(paths[:half], "a/")
+		file.NewPath = file.OldPath
+		return file
+	}
+	if i := strings.Index(paths, " b/"); i >= 0 {
+		file.OldPath = sidePath(paths[:i], "a/")
+		file.NewPath = sidePath(paths[i+1:], "b/")
+	}
+	return file
+}
+
+// closingQuote is the index of the quote ending the C-style string s
+// starts with, or -1.
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// sidePath is the path of a "---" or "+++" line: empty for /dev/null, and
+// without the a/ or b/ prefix and git's quoting otherwise.
+func sidePath(path, prefix string) string {
+	path = unquote(strings.TrimSuffix(path, "\t"))
+	if path == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+// unquote undoes the quoting git puts around paths with unusual characters.
+func unquote(path string) string {
+	if strings.HasPrefix(path, `"`) {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			return unquoted
+		}
+	}
+	return path
+}
+
+// parseHunkHeader reads "@@ -12,7 +12,9 @@ func name". A missing count
+// means one line.
+func parseHunkHeader(line string) (Hunk, bool) {
+	end := strings.Index(line[3:], " @@")
+	if end < 0 {
+		return Hunk{}, false
+	}
+	ranges := strings.Fields(line[3 : 3+end])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return Hunk{}, false
+	}
+	var h Hunk
+	var ok1, ok2 bool
+	h.OldStart, h.OldLines, ok1 = parseRange(ranges[0][1:])
+	h.NewStart, h.NewLines, ok2 = parseRange(ranges[1][1:])
+	h.Section = strings.TrimSpace(line[3+end+3:])
+	return h, ok1 && ok2
+}
+
+func parseRange(r string) (start, lines int, ok bool) {
+	startText, linesText, found := strings.Cut(r, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !found {
+		return start, 1, true
+	}
+	lines, err = strconv.Atoi(linesText)
+	return start, lines, err == nil
+}
+
+// Row is a line of a side-by-side diff: an unchanged line on both sides,
+// or a deleted line beside the added line that took its place. A side is
+// nil where a change added or deleted more lines than the other side has.
+type Row struct {
+	Old, New *Line
+}
+
+// Rows pairs the hunk's lines up side by side. Each run of deleted lines
+// is matched in order with the run of added lines right after it; context
+// and folded lines sit on both sides.
+func (h Hunk) Rows() []Row {
+	var rows []Row
+	for i := 0; i < len(h.Lines); {
+		line := &h.Lines[i]
+		if line.Kind == Context || line.Kind == Folded {
+			rows = append(rows, Row{Old: line, New: line})
+			i++
+			continue
+		}
+		var deleted, added []*Line
+		for ; i < len(h.Lines) && h.Lines[i].Kind == Deleted; i++ {
+			deleted = append(deleted, &h.Lines[i])
+		}
+		for ; i < len(h.Lines) && h.Lines[i].Kind == Added; i++ {
+			added = append(added, &h.Lines[i])
+		}
+		for j := 0; j < len(deleted) || j < len(added); j++ {
+			var row Row
+			if j < len(deleted) {
+				row.Old = deleted[j]
+			}
+			if j < len(added) {
+				row.New = added[j]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// UnifiedLine is a line of a unified diff, with the row it pairs up in so
+// the words it changed can be found.
+type UnifiedLine struct {
+	*Line
+	Row Row
+}
+
+// Spans is the line highlighted, its changed words marked.
+func (u UnifiedLine) Spans(path string) []Span {
+	if u.Kind == Deleted {
+		return u.Row.OldSpans(path)
+	}
+	return u.Row.NewSpans(path)
+}
+
+// Unified lists the hunk's lines in their own order, which is the way a
+// unified diff shows them: each change's deleted lines, then its added
+// ones.
+func (h Hunk) Unified() []UnifiedLine {
+	rows := make(map[*Line]Row, len(h.Lines))
+	for _, row := range h.Rows() {
+		if row.Old != nil {
+			rows[row.Old] = row
+		}
+		if row.New != nil {
+			rows[row.New] = row
+		}
+	}
+	lines := make([]UnifiedLine, len(h.Lines))
+	for i := range h.Lines {
+		lines[i] = UnifiedLine{Line: &h.Lines[i], Row: rows[&h.Lines[i]]}
+	}
+	return lines
+}
+
+// Collapse folds the middle of a hunk of more than max lines into a single
+// Folded line, keeping the first and last max/2 lines. A max of zero or
+// less keeps every hunk whole.
+func (h Hunk) Collapse(max int) Hunk {
+	if max <= 0 || len(h.Lines) <= max {
+		return h
+	}
+	head, tail := (max+1)/2, max/2
+	lines := make([]Line, 0, max+1)
+	lines = append(lines, h.Lines[:head]...)
+	lines = append(lines, Line{Kind: Folded, Hidden: len(h.Lines) - head - tail})
+	lines = append(lines, h.Lines[len(h.Lines)-tail:]...)
+	h.Lines = lines
+	return h
+}
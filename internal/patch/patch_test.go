@@ -0,0 +1,212 @@
+package patch
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testPatch is git show -M output for a commit that renames a file with a
+// space in its name, changes a binary file, edits Go code and adds a file
+// whose name git quotes.
+const testPatch = `diff --git a/my notes.txt b/docs notes.txt
+similarity index 87%
+rename from my notes.txt
+rename to docs notes.txt
+index 2019eda..6045236 100644
+--- a/my notes.txt	
++++ b/docs notes.txt	
+@@ -5,3 +5,4 @@ four
+ five
+ six
+ seven
++eight
+\ No newline at end of file
+diff --git a/logo.bin b/logo.bin
+index bdc955b..8835708 100644
+Binary files a/logo.bin and b/logo.bin differ
+diff --git a/main.go b/main.go
+index 374a230..59af8d5 100644
+--- a/main.go
++++ b/main.go
+@@ -1,7 +1,7 @@
+ package main
+ 
+ func main() {
+-	x := 1
+-	println("old", x)
+-	// gone
++	x := 2
++	println("new", x)
++	defer done()
+ }
+diff --git "a/new\tfile.txt" "b/new\tfile.txt"
+new file mode 100644
+index 0000000..383ba0a
+--- /dev/null
++++ "b/new\tfile.txt"
+@@ -0,0 +1 @@
++tab	here
+`
+
+// show writes spans the way git diff --word-diff does, deleted words in
+// [-...-] and added ones in {+...+}.
+func show(spans []Span) string {
+	var b strings.Builder
+	for _, span := range spans {
+		switch span.Kind {
+		case Deleted:
+			b.WriteString("[-" + span.Text + "-]")
+		case Added:
+			b.WriteString("{+" + span.Text + "+}")
+		default:
+			b.WriteString(span.Text)
+		}
+	}
+	return b.String()
+}
+
+func TestParse(t *testing.T) {
+	files := Parse(testPatch)
+	if len(files) != 4 {
+		t.Fatalf("Parse found %d files, want 4", len(files))
+	}
+
+	renamed := files[0]
+	if renamed.Status != "Renamed" || renamed.OldPath != "my notes.txt" || renamed.NewPath != "docs notes.txt" || renamed.Similarity != 87 {
+		t.Errorf("renamed file = %+v", renamed)
+	}
+	if len(renamed.Hunks) != 1 {
+		t.Fatalf("renamed file has %d hunks", len(renamed.Hunks))
+	}
+	hunk := renamed.Hunks[0]
+	if hunk.Header() != "@@ -5,3 +5,4 @@" || hunk.Section != "four" {
+		t.Errorf("hunk header = %q, section %q", hunk.Header(), hunk.Section)
+	}
+	if last := hunk.Lines[len(hunk.Lines)-1]; last != (Line{Kind: Added, Text: "eight", New: 8, NoNewline: true}) {
+		t.Errorf("last line = %+v", last)
+	}
+	if first := hunk.Lines[0]; first != (Line{Kind: Context, Text: "five", Old: 5, New: 5}) {
+		t.Errorf("first line = %+v", first)
+	}
+
+	if binary := files[1]; !binary.Binary || binary.Path() != "logo.bin" || len(binary.Hunks) != 0 {
+		t.Errorf("binary file = %+v", binary)
+	}
+	if ins, del := files[2].Counts(); files[2].Status != "Modified" || ins != 3 || del != 3 {
+		t.Errorf("main.go is %s with +%d -%d", files[2].Status, ins, del)
+	}
+	added := files[3]
+	if added.Status != "Added" || added.OldPath != "" || added.Path() != "new\tfile.txt" || added.NewMode != "100644" {
+		t.Errorf("added file = %+v", added)
+	}
+	if got := added.Hunks[0].Lines; !reflect.DeepEqual(got, []Line{{Kind: Added, Text: "tab\there", New: 1}}) {
+		t.Errorf("added lines = %+v", got)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	hunk := Parse(testPatch)[2].Hunks[0]
+	var got []string
+	for _, line := range hunk.Unified() {
+		marker := map[Kind]string{Context: " ", Added: "+", Deleted: "-"}[line.Kind]
+		got = append(got, marker+show(line.Spans("main.go")))
+	}
+	// Each deleted line is marked against the added line in its place.
+	want := []string{
+		" package main",
+		" ",
+		" func main() {",
+		"-\tx := [-1-]",
+		`-	println("[-old-]", x)`,
+		"-\t[-//-] [-gone-]",
+		"+\tx := {+2+}",
+		`+	println("{+new+}", x)`,
+		"+\t{+defer+} {+done()+}",
+		" }",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unified diff:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	files := Parse(testPatch)
+	side := func(line *Line, spans []Span) string {
+		if line == nil {
+			return "~"
+		}
+		return show(spans)
+	}
+
+	var got []string
+	for _, row := range files[2].Hunks[0].Rows() {
+		got = append(got, side(row.Old, row.OldSpans("main.go"))+" | "+side(row.New, row.NewSpans("main.go")))
+	}
+	want := []string{
+		"package main | package main",
+		" | ",
+		"func main() { | func main() {",
+		"\tx := [-1-] | \tx := {+2+}",
+		`	println("[-old-]", x) | 	println("{+new+}", x)`,
+		"\t[-//-] [-gone-] | \t{+defer+} {+done()+}",
+		"} | }",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("side by side:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A line added with nothing in its place has an empty old side.
+	rows := files[0].Hunks[0].Rows()
+	if last := rows[len(rows)-1]; last.Old != nil || last.New == nil || last.New.Text != "eight" {
+		t.Errorf("last row = %+v", last)
+	}
+}
+
+func TestWordDiff(t *testing.T) {
+	var got []string
+	for _, row := range Parse(testPatch)[2].Hunks[0].Rows() {
+		got = append(got, show(row.WordSpans("main.go")))
+	}
+	want := []string{
+		"package main",
+		"",
+		"func main() {",
+		"\tx := [-1-]{+2+}",
+		`	println("[-old-]{+new+}", x)`,
+		"\t[-//-]{+defer+} [-gone-]{+done()+}",
+		"}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("word diff:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	hunk := Parse(testPatch)[2].Hunks[0]
+	if got := hunk.Collapse(0); len(got.Lines) != len(hunk.Lines) {
+		t.Errorf("Collapse(0) left %d of %d lines", len(got.Lines), len(hunk.Lines))
+	}
+	if got := hunk.Collapse(len(hunk.Lines)); len(got.Lines) != len(hunk.Lines) {
+		t.Errorf("Collapse of a short hunk left %d of %d lines", len(got.Lines), len(hunk.Lines))
+	}
+
+	folded := hunk.Collapse(5)
+	var kinds []Kind
+	for _, line := range folded.Lines {
+		kinds = append(kinds, line.Kind)
+	}
+	if want := []Kind{Context, Context, Context, Folded, Added, Context}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("Collapse(5) kinds = %v, want %v", kinds, want)
+	}
+	if hidden := folded.Lines[3].Hidden; hidden != 5 {
+		t.Errorf("folded line hides %d lines, want 5", hidden)
+	}
+	// The folded line sits on both sides.
+	if row := folded.Rows()[3]; row.Old == nil || row.Old != row.New || row.Old.Kind != Folded {
+		t.Errorf("folded row = %+v", row)
+	}
+	if len(hunk.Lines) != 10 {
+		t.Errorf("Collapse changed the hunk it was called on")
+	}
+}
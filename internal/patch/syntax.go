@@ -0,0 +1,300 @@
+package patch
+
+import (
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Class is the part of a language's syntax a span of code is.
+type Class string
+
+const (
+	Plain   Class = ""
+	Keyword Class = "keyword"
+	String  Class = "string"
+	Comment Class = "comment"
+	Number  Class = "number"
+)
+
+// language is as much of a language's syntax as it takes to colour a
+// single line: diffs show lines out of context, so there is no state to
+// carry between them.
+type language struct {
+	keywords     map[string]bool
+	ignoreCase   bool     // Keywords match in any case
+	lineComments []string // Start comments running to the end of the line
+	blockComment [2]string
+	quotes       string // Characters that open and close strings
+}
+
+func keywords(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(list) {
+		set[word] = true
+	}
+	return set
+}
+
+var (
+	cStyle = [2]string{"/*", "*/"}
+
+	golang = &language{
+		keywords: keywords(`break case chan const continue default defer else fallthrough for func go goto
+			if import interface map package range return select struct switch type var true false nil iota`),
+		lineComments: []string{"//"},
+		blockComment: cStyle,
+		quotes:       "\"'`",
+	}
+	clang = &language{
+		keywords: keywords(`auto break case char const continue default do double else enum extern float for
+			goto if inline int long register return short signed sizeof static struct switch typedef union
+			unsigned void volatile while bool true false NULL nullptr class namespace template typename public
+			private protected virtual override new delete this using operator friend try catch throw constexpr`),
+		lineComments: []string{"//"},
+		blockComment: cStyle,
+		quotes:       `"'`,
+	}
+	java = &language{
+		keywords: keywords(`abstract boolean break byte case catch char class const continue default do double
+			else enum extends final finally float for if implements import instanceof int interface long native
+			new package private protected public return short static super switch synchronized this throw throws
+			try void volatile while true false null var val fun object when is in out override namespace using`),
+		lineComments: []string{"//"},
+		blockComment: cStyle,
+		quotes:       `"'`,
+	}
+	javascript = &language{
+		keywords: keywords(`async await break case catch class const continue debugger default delete do else
+			export extends finally for from function if import in instanceof let new of return static super switch
+			this throw try typeof var void while yield true false null undefined interface type enum implements
+			private protected public readonly as`),
+		lineComments: []string{"//"},
+		blockComment: cStyle,
+		quotes:       "\"'`",
+	}
+	rust = &language{
+		keywords: keywords(`as async await break const continue crate dyn else enum extern false fn for if impl
+			in let loop match mod move mut pub ref return self Self static struct super trait true type unsafe use
+			where while`),
+		lineComments: []string{"//"},
+		blockComment: cStyle,
+		quotes:       `"`,
+	}
+	python = &language{
+		keywords: keywords(`and as assert async await break class continue def del elif else except False
+			finally for from global if import in is lambda None nonlocal not or pass raise return True try while
+			with yield`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	}
+	ruby = &language{
+		keywords: keywords(`alias and begin break case class def defined do else elsif end ensure false for if
+			in module next nil not or redo rescue retry return self super then true undef unless until when while
+			yield require`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	}
+	shell = &language{
+		keywords: keywords(`if then else elif fi case esac for select while until do done in function return
+			local export readonly declare set unset shift exit`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	}
+	sql = &language{
+		keywords: keywords(`select from where insert into values update set delete create table alter drop
+			index view join inner left right outer on and or not null is as order by group having limit
+			distinct union all primary key foreign references default unique begin commit rollback`),
+		ignoreCase:   true,
+		lineComments: []string{"--"},
+		blockComment: cStyle,
+		quotes:       `'"`,
+	}
+	config = &language{
+		keywords:     keywords(`true false null yes no on off`),
+		lineComments: []string{"#"},
+		quotes:       `"'`,
+	}
+	json = &language{
+		keywords: keywords(`true false null`),
+		quotes:   `"`,
+	}
+	css = &language{
+		keywords:     keywords(`important inherit initial unset none auto`),
+		blockComment: cStyle,
+		quotes:       `"'`,
+	}
+	markup = &language{
+		blockComment: [2]string{"<!--", "-->"},
+		quotes:       `"`,
+	}
+)
+
+// fileNames lists the file extensions of each language, and the names of
+// files in it that have none.
+var fileNames = map[*language]string{
+	golang:     ".go",
+	clang:      ".c .h .cc .cpp .cxx .hpp .hh",
+	java:       ".java .kt .kts .cs .scala",
+	javascript: ".js .jsx .mjs .cjs .ts .tsx",
+	rust:       ".rs",
+	python:     ".py",
+	ruby:       ".rb Rakefile Gemfile",
+	shell:      ".sh .bash .zsh Makefile makefile GNUmakefile Dockerfile",
+	sql:        ".sql",
+	config:     ".yml .yaml .toml .ini .cfg .conf",
+	json:       ".json",
+	css:        ".css .scss .less",
+	markup:     ".html .htm .xml .svg .tpl .tmpl .vue",
+}
+
+// languages is fileNames the other way round.
+var languages = make(map[string]*language)
+
+func init() {
+	for lang, names := range fileNames {
+		for _, name := range strings.Fields(names) {
+			languages[name] = lang
+		}
+	}
+}
+
+// languageOf picks the language from a file's name, nil for files it
+// does not know.
+func languageOf(file string) *language {
+	base := path.Base(file)
+	if lang, ok := languages[base]; ok {
+		return lang
+	}
+	return languages[strings.ToLower(path.Ext(base))]
+}
+
+// Highlight splits a line of the file at path into spans of one syntax
+// class each. Lines of files in unknown languages are one plain span.
+func Highlight(path, text string) []Span {
+	if text == "" {
+		return nil
+	}
+	lang := languageOf(path)
+	if lang == nil {
+		return []Span{{Text: text}}
+	}
+
+	var spans []Span
+	add := func(class Class, text string) {
+		if n := len(spans); n > 0 && spans[n-1].Class == class {
+			spans[n-1].Text += text
+			return
+		}
+		spans = append(spans, Span{Text: text, Class: class})
+	}
+
+	i := 0
+	if end := lang.continuesComment(text); end > 0 {
+		add(Comment, text[:end])
+		i = end
+	}
+	for i < len(text) {
+		rest := text[i:]
+		if lang.startsComment(rest) {
+			add(Comment, rest)
+			break
+		}
+		if start, end := lang.blockComment[0], lang.blockComment[1]; start != "" && strings.HasPrefix(rest, start) {
+			n := len(rest)
+			if close := strings.Index(rest[len(start):], end); close >= 0 {
+				n = len(start) + close + len(end)
+			}
+			add(Comment, rest[:n])
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		switch {
+		case strings.ContainsRune(lang.quotes, r):
+			n := quoted(rest, r)
+			add(String, rest[:n])
+			i += n
+		case isWordRune(r):
+			n := size
+			for n < len(rest) {
+				next, size := utf8.DecodeRuneInString(rest[n:])
+				if !isWordRune(next) {
+					break
+				}
+				n += size
+			}
+			word := rest[:n]
+			switch {
+			case unicode.IsDigit(r):
+				add(Number, word)
+			case lang.isKeyword(word):
+				add(Keyword, word)
+			default:
+				add(Plain, word)
+			}
+			i += n
+		default:
+			add(Plain, rest[:size])
+			i += size
+		}
+	}
+	return spans
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (l *language) isKeyword(word string) bool {
+	if l.ignoreCase {
+		word = strings.ToLower(word)
+	}
+	return l.keywords[word]
+}
+
+func (l *language) startsComment(text string) bool {
+	for _, prefix := range l.lineComments {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// continuesComment guesses whether a line starts inside a block comment
+// opened on an earlier line, and returns where the comment ends if so: the
+// closing marker comes before any opening one, or the line is one of the
+// " * " lines of a C-style comment.
+func (l *language) continuesComment(text string) int {
+	start, end := l.blockComment[0], l.blockComment[1]
+	if start == "" {
+		return 0
+	}
+	if close := strings.Index(text, end); close >= 0 {
+		if open := strings.Index(text, start); open < 0 || open > close {
+			return close + len(end)
+		}
+	}
+	trimmed := strings.TrimLeft(text, " \t")
+	if start == "/*" && (trimmed == "*" || strings.HasPrefix(trimmed, "* ")) {
+		return len(text)
+	}
+	return 0
+}
+
+// quoted is the length of the string text starts with, up to its closing
+// quote or the end of the line.
+func quoted(text string, quote rune) int {
+	for i := 1; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && quote != '`':
+			i++
+		case rune(text[i]) == quote:
+			return i + 1
+		}
+	}
+	return len(text)
+}
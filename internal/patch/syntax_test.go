@@ -0,0 +1,31 @@
+package patch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	for _, test := range []struct {
+		path, text string
+		want       []Span
+	}{
+		{"main.go", `x := "a // b" // note`, []Span{
+			{Text: "x := "}, {Text: `"a // b"`, Class: String}, {Text: " "}, {Text: "// note", Class: Comment},
+		}},
+		{"main.go", "return 42", []Span{
+			{Text: "return", Class: Keyword}, {Text: " "}, {Text: "42", Class: Number},
+		}},
+		{"tool.py", "def f(): # done", []Span{
+			{Text: "def", Class: Keyword}, {Text: " f(): "}, {Text: "# done", Class: Comment},
+		}},
+		{"query.sql", "select 1 -- one", []Span{
+			{Text: "select", Class: Keyword}, {Text: " "}, {Text: "1", Class: Number}, {Text: " "}, {Text: "-- one", Class: Comment},
+		}},
+		{"notes.txt", "return 42", []Span{{Text: "return 42"}}},
+	} {
+		if got := Highlight(test.path, test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Highlight(%q, %q) = %+v, want %+v", test.path, test.text, got, test.want)
+		}
+	}
+}
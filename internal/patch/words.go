@@ -0,0 +1,266 @@
+package patch
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxWordPairs bounds the work of matching two lines word by word; longer
+// pairs of lines are shown as changed throughout.
+const maxWordPairs = 40000
+
+// Span is a run of a line with one syntax class. In a changed line, Kind
+// marks the words the change added or deleted; the rest is Context.
+type Span struct {
+	Text  string
+	Class Class
+	Kind  Kind
+}
+
+// Spans splits a row into highlighted spans for each way of showing it:
+// old and new are its sides with the changed words marked, and words is
+// the single line of a word diff, deleted and added words in the order
+// they replace each other. A side the row does not have is nil. A line
+// with nothing beside it has no words marked on its side, and is all
+// deleted or added in the word diff.
+func (r Row) Spans(path string) (old, new, words []Span) {
+	switch {
+	case r.Old != nil && r.New != nil && r.Old.Kind == Deleted && r.New.Kind == Added:
+		// Handled below.
+	case r.Old != nil && r.Old.Kind == Deleted:
+		old = Highlight(path, r.Old.Text)
+		return old, nil, mark(append([]Span(nil), old...), Deleted)
+	case r.New != nil && r.New.Kind == Added:
+		new = Highlight(path, r.New.Text)
+		return nil, new, mark(append([]Span(nil), new...), Added)
+	case r.New != nil:
+		new = Highlight(path, r.New.Text)
+		return new, new, new
+	default:
+		return nil, nil, nil
+	}
+
+	oldWords, newWords := splitWords(r.Old.Text), splitWords(r.New.Text)
+	edits := wordEdits(oldWords, newWords)
+	oldKinds := make([]Kind, 0, len(oldWords))
+	newKinds := make([]Kind, 0, len(newWords))
+	for _, e := range edits {
+		switch e.kind {
+		case Context:
+			oldKinds = append(oldKinds, Context)
+			newKinds = append(newKinds, Context)
+		case Deleted:
+			oldKinds = append(oldKinds, Deleted)
+		case Added:
+			newKinds = append(newKinds, Added)
+		}
+	}
+	old = split(Highlight(path, r.Old.Text), oldWords, oldKinds)
+	new = split(Highlight(path, r.New.Text), newWords, newKinds)
+
+	// The word diff takes unchanged text from the new side.
+	oldRest, newRest := old, new
+	for _, e := range edits {
+		var taken []Span
+		switch e.kind {
+		case Context:
+			_, oldRest = take(oldRest, len(e.text))
+			taken, newRest = take(newRest, len(e.text))
+		case Deleted:
+			taken, oldRest = take(oldRest, len(e.text))
+		case Added:
+			taken, newRest = take(newRest, len(e.text))
+		}
+		words = append(words, taken...)
+	}
+	return old, new, merge(words)
+}
+
+// OldSpans is the old side of Spans.
+func (r Row) OldSpans(path string) []Span {
+	old, _, _ := r.Spans(path)
+	return old
+}
+
+// NewSpans is the new side of Spans.
+func (r Row) NewSpans(path string) []Span {
+	_, new, _ := r.Spans(path)
+	return new
+}
+
+// WordSpans is the word diff line of Spans.
+func (r Row) WordSpans(path string) []Span {
+	_, _, words := r.Spans(path)
+	return words
+}
+
+// mark sets kind on every span.
+func mark(spans []Span, kind Kind) []Span {
+	for i := range spans {
+		spans[i].Kind = kind
+	}
+	return spans
+}
+
+// split cuts highlighted spans at the word boundaries and gives each piece
+// the kind of its word.
+func split(spans []Span, words []string, kinds []Kind) []Span {
+	var out []Span
+	for i, word := range words {
+		var taken []Span
+		taken, spans = take(spans, len(word))
+		out = append(out, mark(taken, kinds[i])...)
+	}
+	return merge(out)
+}
+
+// take removes the first n bytes of spans, splitting a span if needed.
+// Spans itself is left as it was.
+func take(spans []Span, n int) (taken, rest []Span) {
+	for n > 0 && len(spans) > 0 {
+		span := spans[0]
+		if len(span.Text) > n {
+			head, tail := span, span
+			head.Text, tail.Text = span.Text[:n], span.Text[n:]
+			return append(taken, head), append([]Span{tail}, spans[1:]...)
+		}
+		taken = append(taken, span)
+		n -= len(span.Text)
+		spans = spans[1:]
+	}
+	return taken, spans
+}
+
+// merge joins neighbouring spans that look the same.
+func merge(spans []Span) []Span {
+	var out []Span
+	for _, span := range spans {
+		if span.Text == "" {
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].Class == span.Class && out[n-1].Kind == span.Kind {
+			out[n-1].Text += span.Text
+			continue
+		}
+		out = append(out, span)
+	}
+	return out
+}
+
+// splitWords cuts a line into words: runs of letters and digits, runs of
+// spaces, and single other characters, so punctuation changes stay small.
+func splitWords(text string) []string {
+	var words []string
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		end := i + size
+		if class := wordClass(r); class != 0 {
+			for end < len(text) {
+				next, size := utf8.DecodeRuneInString(text[end:])
+				if wordClass(next) != class {
+					break
+				}
+				end += size
+			}
+		}
+		words = append(words, text[i:end])
+		i = end
+	}
+	return words
+}
+
+// wordClass groups the runes that make up a word: 1 for letters, digits
+// and underscores, 2 for spaces and 0 for runes that stand alone.
+func wordClass(r rune) int {
+	switch {
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	case unicode.IsSpace(r):
+		return 2
+	default:
+		return 0
+	}
+}
+
+type wordEdit struct {
+	kind Kind
+	text string
+}
+
+// wordEdits is the shortest way from one line's words to the other's, the
+// deleted words of each change before the added ones.
+func wordEdits(a, b []string) []wordEdit {
+	// Trim what the lines share at either end before matching the middle.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []wordEdit
+	for _, word := range a[:prefix] {
+		edits = append(edits, wordEdit{Context, word})
+	}
+	edits = append(edits, middleEdits(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, word := range a[len(a)-suffix:] {
+		edits = append(edits, wordEdit{Context, word})
+	}
+	return edits
+}
+
+// middleEdits matches words by their longest common subsequence, or not at
+// all when the lines are too long for it.
+func middleEdits(a, b []string) []wordEdit {
+	var edits []wordEdit
+	flush := func(deleted, added []string) {
+		for _, word := range deleted {
+			edits = append(edits, wordEdit{Deleted, word})
+		}
+		for _, word := range added {
+			edits = append(edits, wordEdit{Added, word})
+		}
+	}
+	if len(a)*len(b) > maxWordPairs {
+		flush(a, b)
+		return edits
+	}
+
+	// common[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	var deleted, added []string
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			flush(deleted, added)
+			deleted, added = nil, nil
+			edits = append(edits, wordEdit{Context, a[i]})
+			i, j = i+1, j+1
+		case common[i+1][j] >= common[i][j+1]:
+			deleted = append(deleted, a[i])
+			i++
+		default:
+			added = append(added, b[j])
+			j++
+		}
+	}
+	flush(append(deleted, a[i:]...), append(added, b[j:]...))
+	return edits
+}
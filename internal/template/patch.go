@@ -0,0 +1,48 @@
+package template
+
+import (
+	"html/template"
+	"strings"
+
+	"human-git-history/internal/patch"
+)
+
+// code marks up highlighted code for commit.tpl's diffs: tok-* classes
+// by syntax, and word-added or word-deleted on the words a change touched.
+func code(spans []patch.Span) template.HTML {
+	var out strings.Builder
+	for _, span := range spans {
+		var classes []string
+		if span.Class != patch.Plain {
+			classes = append(classes, "tok-"+string(span.Class))
+		}
+		switch span.Kind {
+		case patch.Added:
+			classes = append(classes, "word-added")
+		case patch.Deleted:
+			classes = append(classes, "word-deleted")
+		}
+		text := template.HTMLEscapeString(span.Text)
+		if len(classes) == 0 {
+			out.WriteString(text)
+			continue
+		}
+		out.WriteString(`<span class="` + strings.Join(classes, " ") + `">` + text + `</span>`)
+	}
+	return template.HTML(out.String())
+}
+
+// lineKind names a diff line's kind for CSS classes: context, added,
+// deleted or folded.
+func lineKind(kind patch.Kind) string {
+	switch kind {
+	case patch.Added:
+		return "added"
+	case patch.Deleted:
+		return "deleted"
+	case patch.Folded:
+		return "folded"
+	default:
+		return "context"
+	}
+}
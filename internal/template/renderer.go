@@ -4,7 +4,9 @@ import (
 	// "bytes"
 	"fmt"
 	"html/template"
+	"human-git-history/internal/changelog"
 	"human-git-history/internal/git"
+	"human-git-history/internal/patch"
 	"io"
 	"os"
 	"path/filepath"
@@ -16,11 +18,13 @@ import "encoding/json"
 
 type TemplateData struct {
 	Commits     []git.Commit
+	Releases    []changelog.Release // For the changelog page
 	Title       string
 	Description string
 	GeneratedAt time.Time
 	Stats       *RepoStats
 	Options     RenderOptions
+	Patches     map[string][]patch.File // Diff of each commit by hash, with Options.Patch
 }
 
 type RepoStats struct {
@@ -50,8 +54,11 @@ type RenderOptions struct {
 	Since         string
 	Until         string
 	Author        string
+	Range         string // Revisions shown, empty for the history of HEAD
 	Theme         string // light, dark, auto
 	CompactView   bool
+	Patch         string // unified, side-by-side or word to show diffs; empty for none
+	HunkLines     int    // Hunks longer than this start folded; 0 for none
 }
 
 type TemplateRenderer struct {
@@ -59,14 +66,10 @@ type TemplateRenderer struct {
 	assetDir  string
 }
 
-func NewRenderer(templateDir, assetDir string) (*TemplateRenderer, error) {
-	tr := &TemplateRenderer{
-		templates: make(map[string]*template.Template),
-		assetDir:  assetDir,
-	}
-
-	// Define template functions
-	funcMap := template.FuncMap{
+// Funcs returns the template helpers that do not depend on HTML escaping.
+// They are shared by the web pages and the terminal's --format templates.
+func Funcs() map[string]interface{} {
+	return map[string]interface{}{
 		"formatDate":      formatDate,
 		"formatTimeAgo":   formatTimeAgo,
 		"formatDateTime":  formatDateTime,
@@ -91,8 +94,6 @@ func NewRenderer(templateDir, assetDir string) (*TemplateRenderer, error) {
 		"toUpper":         strings.ToUpper,
 		"toLower":         strings.ToLower,
 		"replace":         strings.ReplaceAll,
-		"safeHTML":        func(s string) template.HTML { return template.HTML(s) },
-		"safeJS":          func(s string) template.JS { return template.JS(s) },
 		"dict": func(values ...interface{}) (map[string]interface{}, error) {
 			if len(values)%2 != 0 {
 				return nil, fmt.Errorf("dict requires even number of arguments")
@@ -123,6 +124,20 @@ func NewRenderer(templateDir, assetDir string) (*TemplateRenderer, error) {
 			return value
 		},
 	}
+}
+
+func NewRenderer(templateDir, assetDir string) (*TemplateRenderer, error) {
+	tr := &TemplateRenderer{
+		templates: make(map[string]*template.Template),
+		assetDir:  assetDir,
+	}
+
+	// Define template functions
+	funcMap := template.FuncMap(Funcs())
+	funcMap["safeHTML"] = func(s string) template.HTML { return template.HTML(s) }
+	funcMap["safeJS"] = func(s string) template.JS { return template.JS(s) }
+	funcMap["code"] = code
+	funcMap["lineKind"] = lineKind
 
 	// Load and parse templates
 	templates := []string{
@@ -132,6 +147,12 @@ func NewRenderer(templateDir, assetDir string) (*TemplateRenderer, error) {
 		"stats.tpl",
 	}
 
+	// Pages show their commits with commit.tpl, so it is parsed into each.
+	commitContent, err := os.ReadFile(filepath.Join(templateDir, "commit.tpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template commit.tpl: %v", err)
+	}
+
 	for _, tmpl := range templates {
 		path := filepath.Join(templateDir, tmpl)
 		content, err := os.ReadFile(path)
@@ -140,6 +161,9 @@ func NewRenderer(templateDir, assetDir string) (*TemplateRenderer, error) {
 		}
 
 		t, err := template.New(tmpl).Funcs(funcMap).Parse(string(content))
+		if err == nil && tmpl != "commit.tpl" {
+			_, err = t.New("commit.tpl").Parse(string(commitContent))
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse template %s: %v", tmpl, err)
 		}
@@ -158,7 +182,9 @@ func (tr *TemplateRenderer) RenderIndex(w io.Writer, data TemplateData) error {
 	return tmpl.Execute(w, data)
 }
 
-func (tr *TemplateRenderer) RenderCommit(w io.Writer, commit git.Commit, options RenderOptions) error {
+// RenderCommit renders a single commit card, with files as its diff when
+// options.Patch is set.
+func (tr *TemplateRenderer) RenderCommit(w io.Writer, commit git.Commit, files []patch.File, options RenderOptions) error {
 	tmpl, ok := tr.templates["commit.tpl"]
 	if !ok {
 		return fmt.Errorf("commit template not found")
@@ -166,9 +192,11 @@ func (tr *TemplateRenderer) RenderCommit(w io.Writer, commit git.Commit, options
 	return tmpl.Execute(w, struct {
 		Commit  git.Commit
 		Options RenderOptions
+		Patch   []patch.File
 	}{
 		Commit:  commit,
 		Options: options,
+		Patch:   files,
 	})
 }
 
@@ -189,6 +217,22 @@ func (tr *TemplateRenderer) RenderStats(w io.Writer, stats RepoStats) error {
 }
 
 func (tr *TemplateRenderer) RenderToFile(filename string, data TemplateData) error {
+	return tr.renderFile(filename, func(w io.Writer) error {
+		return tr.RenderIndex(w, data)
+	})
+}
+
+// RenderChangelogToFile writes the changelog page, with data.Releases as
+// its content.
+func (tr *TemplateRenderer) RenderChangelogToFile(filename string, data TemplateData) error {
+	return tr.renderFile(filename, func(w io.Writer) error {
+		return tr.RenderChangelog(w, data)
+	})
+}
+
+// renderFile creates filename, with the assets beside it, and renders a
+// page into it.
+func (tr *TemplateRenderer) renderFile(filename string, render func(w io.Writer) error) error {
 	// Create directory if it doesn't exist
 	dir := filepath.Dir(filename)
 	if err := os.MkdirAll(dir, 0755); err != nil {
@@ -209,7 +253,7 @@ func (tr *TemplateRenderer) RenderToFile(filename string, data TemplateData) err
 		}
 	}
 
-	return tr.RenderIndex(file, data)
+	return render(file)
 }
 
 // Helper functions for templates
@@ -217,8 +261,9 @@ func formatDate(t time.Time) string {
 	return t.Format("2006-01-02")
 }
 
+// formatDateTime keeps the zone t is in, the author's for commit dates.
 func formatDateTime(t time.Time) string {
-	return t.Format("2006-01-02 15:04:05")
+	return t.Format("2006-01-02 15:04:05 -0700")
 }
 
 func formatTimeAgo(t time.Time) string {
@@ -348,11 +393,13 @@ func percentage(part, total int) float64 {
 	return float64(part) / float64(total) * 100
 }
 
+// truncate cuts s to length characters, never inside one.
 func truncate(s string, length int) string {
-	if len(s) <= length {
+	runes := []rune(s)
+	if len(runes) <= length {
 		return s
 	}
-	return s[:length] + "..."
+	return string(runes[:length]) + "..."
 }
 
 func copyDir(src, dst string) error {
@@ -0,0 +1,131 @@
+package template
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"human-git-history/internal/git"
+	"human-git-history/internal/patch"
+)
+
+const fixturePatch = `diff --git a/templates/commit.tpl b/templates/commit.tpl
+index 1111111..2222222 100644
+--- a/templates/commit.tpl
++++ b/templates/commit.tpl
+@@ -1,3 +1,3 @@ header
+ <div class="commit-card">
+-    {{if .Options.ShowStats}}
++    {{if and .Options.ShowStats .Commit.Stats}}
+ </div>
+`
+
+func TestRenderIndexFixture(t *testing.T) {
+	repo, err := git.LoadFixture("../git/testdata/history.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits, err := repo.Log(git.CommitOptions{ShowFileChanges: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer, err := NewRenderer("../../templates", "../../assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, options := range []RenderOptions{
+		{ShowFiles: true, ShowStats: true},
+		{ShowFiles: true, GroupByDate: true},
+		{GroupByAuthor: true, Theme: "dark"},
+		{ShowFiles: true, Patch: "unified"},
+		{Patch: "side-by-side", HunkLines: 2},
+		{Patch: "word"},
+	} {
+		data := TemplateData{
+			Commits:     commits,
+			Title:       "Fixture <history>",
+			GeneratedAt: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+			Stats:       &RepoStats{TotalCommits: len(commits)},
+			Options:     options,
+			Patches:     map[string][]patch.File{commits[0].Hash: patch.Parse(fixturePatch)},
+		}
+		var buf bytes.Buffer
+		if err := renderer.RenderIndex(&buf, data); err != nil {
+			t.Fatalf("RenderIndex with %+v: %v", options, err)
+		}
+
+		page := buf.String()
+		if !strings.Contains(page, "Fixture &lt;history&gt;") {
+			t.Errorf("with %+v the page has no escaped title", options)
+		}
+		for _, commit := range commits {
+			if !strings.Contains(page, commit.ShortHash) {
+				t.Errorf("with %+v the page has no commit %s", options, commit.ShortHash)
+			}
+		}
+		if options.ShowFiles && !strings.Contains(page, "internal/web/diff view.go") {
+			t.Errorf("with %+v the page has no file changes", options)
+		}
+
+		hasDiff := strings.Contains(page, `class="diff-table"`)
+		if hasDiff != (options.Patch != "") {
+			t.Errorf("with %+v diff shown = %v", options, hasDiff)
+		}
+		if options.Patch != "" && !strings.Contains(page, "diff-"+options.Patch) {
+			t.Errorf("with %+v the diff is not laid out as %s", options, options.Patch)
+		}
+		if folded := strings.Contains(page, `class="diff-folded"`); folded != (options.HunkLines > 0) {
+			t.Errorf("with %+v hunk folded = %v", options, folded)
+		}
+	}
+}
+
+// TestRenderIndexNestsCommits checks that index.tpl hands each commit card
+// the same data RenderCommit does, so the patch lands on its own commit.
+func TestRenderIndexNestsCommits(t *testing.T) {
+	repo, err := git.LoadFixture("../git/testdata/history.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits, err := repo.Log(git.CommitOptions{ShowFileChanges: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer, err := NewRenderer("../../templates", "../../assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := RenderOptions{ShowFiles: true, ShowStats: true, Patch: "unified"}
+	patches := map[string][]patch.File{commits[1].Hash: patch.Parse(fixturePatch)}
+	var page bytes.Buffer
+	err = renderer.RenderIndex(&page, TemplateData{
+		Commits:     commits,
+		Title:       "Fixture",
+		Stats:       &RepoStats{TotalCommits: len(commits)},
+		GeneratedAt: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+		Options:     options,
+		Patches:     patches,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, commit := range commits {
+		var card bytes.Buffer
+		if err := renderer.RenderCommit(&card, commit, patches[commit.Hash], options); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(page.String(), card.String()) {
+			t.Errorf("the page does not show commit %s as RenderCommit does:\n%s", commit.ShortHash, card.String())
+		}
+		if hasDiff := strings.Contains(card.String(), `class="diff-table"`); hasDiff != (commit.Hash == commits[1].Hash) {
+			t.Errorf("commit %s diff shown = %v", commit.ShortHash, hasDiff)
+		}
+	}
+	if n := strings.Count(page.String(), `class="diff-table"`); n != 1 {
+		t.Errorf("the page shows %d diffs, want 1", n)
+	}
+}
@@ -0,0 +1,87 @@
+// Package term answers questions about the terminal output goes to.
+package term
+
+import (
+	"os"
+	"strconv"
+)
+
+// defaultWidth and defaultHeight are assumed for terminals that do not
+// report their size.
+const (
+	defaultWidth  = 80
+	defaultHeight = 24
+)
+
+// Width returns the number of columns of the terminal f is connected to,
+// or 0 when f is not a terminal and output should not be fitted to any
+// width. $COLUMNS stands in for terminals that report no size.
+func Width(f *os.File) int {
+	cols, _, ok := size(f)
+	if !ok {
+		return 0
+	}
+	return orDefault(cols, "COLUMNS", defaultWidth)
+}
+
+// IsTerminal reports whether f is connected to a terminal.
+func IsTerminal(f *os.File) bool {
+	_, _, ok := size(f)
+	return ok
+}
+
+// orDefault is n, or the value of the environment variable env when the
+// terminal reported no size, or fallback without either.
+func orDefault(n int, env string, fallback int) int {
+	if n > 0 {
+		return n
+	}
+	if n, err := strconv.Atoi(os.Getenv(env)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// TTY is the terminal the user sits at, opened directly so full-screen
+// interfaces keep working while standard input and output are redirected,
+// as they are for a command inside $(...).
+type TTY struct {
+	In      *os.File
+	Out     *os.File
+	restore func() error
+}
+
+// OpenTTY opens the terminal and switches it to raw mode: keys arrive one
+// at a time without being echoed, and Ctrl-C is a key like any other.
+func OpenTTY() (*TTY, error) {
+	in, out, err := openTTY()
+	if err != nil {
+		return nil, err
+	}
+	restore, err := makeRaw(in, out)
+	if err != nil {
+		in.Close()
+		if out != in {
+			out.Close()
+		}
+		return nil, err
+	}
+	return &TTY{In: in, Out: out, restore: restore}, nil
+}
+
+// Size returns the columns and rows of the terminal, falling back to
+// $COLUMNS and $LINES, then 80x24.
+func (t *TTY) Size() (cols, rows int) {
+	cols, rows, _ = size(t.Out)
+	return orDefault(cols, "COLUMNS", defaultWidth), orDefault(rows, "LINES", defaultHeight)
+}
+
+// Close puts the terminal back in the mode it was found in.
+func (t *TTY) Close() error {
+	err := t.restore()
+	t.In.Close()
+	if t.Out != t.In {
+		t.Out.Close()
+	}
+	return err
+}
@@ -0,0 +1,26 @@
+//go:build !unix && !windows
+
+package term
+
+import (
+	"errors"
+	"os"
+)
+
+// size reports no terminal on systems without the unix window size ioctl.
+func size(f *os.File) (cols, rows int, ok bool) {
+	return 0, 0, false
+}
+
+var errNoTerminal = errors.New("terminal interfaces are not supported on this system")
+
+func openTTY() (in, out *os.File, err error) {
+	return nil, nil, errNoTerminal
+}
+
+func makeRaw(in, out *os.File) (restore func() error, err error) {
+	return nil, errNoTerminal
+}
+
+// NotifyResize does nothing where there is no terminal to resize.
+func NotifyResize(ch chan<- os.Signal) {}
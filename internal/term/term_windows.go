@@ -0,0 +1,62 @@
+//go:build windows
+
+package term
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// size reads the visible size of the console window.
+func size(f *os.File) (cols, rows int, ok bool) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(f.Fd()), &info); err != nil {
+		return 0, 0, false
+	}
+	return int(info.Window.Right-info.Window.Left) + 1, int(info.Window.Bottom-info.Window.Top) + 1, true
+}
+
+// openTTY opens the console's input and output buffers.
+func openTTY() (in, out *os.File, err error) {
+	in, err = os.OpenFile("CONIN$", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err = os.OpenFile("CONOUT$", os.O_RDWR, 0)
+	if err != nil {
+		in.Close()
+		return nil, nil, err
+	}
+	return in, out, nil
+}
+
+// makeRaw turns off line input, echo and Ctrl-C handling, and has the
+// console speak VT sequences both ways, as unix terminals do.
+func makeRaw(in, out *os.File) (restore func() error, err error) {
+	inHandle, outHandle := windows.Handle(in.Fd()), windows.Handle(out.Fd())
+	var inMode, outMode uint32
+	if err := windows.GetConsoleMode(inHandle, &inMode); err != nil {
+		return nil, err
+	}
+	if err := windows.GetConsoleMode(outHandle, &outMode); err != nil {
+		return nil, err
+	}
+
+	raw := inMode&^(windows.ENABLE_ECHO_INPUT|windows.ENABLE_PROCESSED_INPUT|windows.ENABLE_LINE_INPUT) | windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+	if err := windows.SetConsoleMode(inHandle, raw); err != nil {
+		return nil, err
+	}
+	if err := windows.SetConsoleMode(outHandle, outMode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		windows.SetConsoleMode(inHandle, inMode)
+		return nil, err
+	}
+	return func() error {
+		windows.SetConsoleMode(outHandle, outMode)
+		return windows.SetConsoleMode(inHandle, inMode)
+	}, nil
+}
+
+// NotifyResize does nothing: consoles send no signal when resized, so
+// full-screen interfaces read the size again on every key.
+func NotifyResize(ch chan<- os.Signal) {}
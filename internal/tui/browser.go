@@ -0,0 +1,492 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"human-git-history/internal/formatter"
+	"human-git-history/internal/git"
+)
+
+// minBatch is the fewest commits read from history at a time.
+const minBatch = 100
+
+// Options are what the browser needs from the command running it.
+type Options struct {
+	// Checkout checks a commit out in the working tree; the browser asks
+	// for confirmation first. When nil, commits cannot be checked out.
+	Checkout func(hash string) error
+	// Patch lays out the diff view; v switches between the layouts.
+	Patch formatter.PatchOptions
+}
+
+// pane is the part of the browser the cursor keys move.
+type pane int
+
+const (
+	listPane pane = iota
+	detailPane
+)
+
+// What the filter matches.
+const (
+	byMessage = "message"
+	byAuthor  = "author"
+)
+
+// browser is the state of `git-history tui`: the commits read so far,
+// those the filter lets through, and what is on screen.
+type browser struct {
+	repo    git.Repository
+	screen  *screen
+	loader  *loader
+	options Options
+
+	commits   []git.Commit
+	shown     []int // Indexes into commits that pass the filter
+	cursor    int   // Selected entry of shown
+	top       int   // First entry of shown on screen
+	focus     pane
+	detailTop int
+
+	filter     string
+	filterBy   string
+	editing    bool // Typing the filter
+	confirming bool // Asking whether to check out the selected commit
+	status     string
+
+	diff *diffView
+}
+
+// diffView shows the patch of one file of a commit at a time.
+type diffView struct {
+	commit git.Commit
+	file   int
+	patch  string
+	err    error
+	lines  []string // The patch drawn at width
+	width  int
+	top    int
+}
+
+// Browse shows the history options select in a full-screen browser until
+// the user quits. History is read as the list scrolls, past any limit
+// being the only end.
+func Browse(repo git.Repository, commitOptions git.CommitOptions, options Options) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it, err := repo.Stream(ctx, commitOptions)
+	if err != nil {
+		return err
+	}
+	l := newLoader(it)
+	defer func() {
+		cancel()
+		l.close()
+	}()
+
+	s, err := openScreen()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	b := &browser{repo: repo, screen: s, loader: l, options: options, filterBy: byMessage}
+	batches := l.batches
+	for {
+		b.fill()
+		b.draw()
+		select {
+		case k, ok := <-s.keys:
+			if !ok || !b.handle(k) {
+				return nil
+			}
+		case next, ok := <-batches:
+			if !ok {
+				batches = nil
+				continue
+			}
+			b.add(next)
+		case <-s.resized:
+		}
+	}
+}
+
+// fill asks for more history while the list, or the commits the filter
+// lets through, end less than two screens below the cursor.
+func (b *browser) fill() {
+	_, rows := b.screen.Size()
+	if len(b.shown) < b.cursor+2*rows {
+		b.loader.more(max(2*rows, minBatch))
+	}
+}
+
+func (b *browser) add(next batch) {
+	b.loader.received(next)
+	for _, commit := range next.commits {
+		b.commits = append(b.commits, commit)
+		if b.matches(commit) {
+			b.shown = append(b.shown, len(b.commits)-1)
+		}
+	}
+	if next.err != nil {
+		b.status = "Error reading history: " + next.err.Error()
+	}
+}
+
+// matches reports whether commit passes the filter: its subject or body
+// contain the text, or the name or email of its author or a co-author do,
+// without regard to case.
+func (b *browser) matches(commit git.Commit) bool {
+	if b.filter == "" {
+		return true
+	}
+	needle := strings.ToLower(b.filter)
+	contains := func(s string) bool { return strings.Contains(strings.ToLower(s), needle) }
+	if b.filterBy == byAuthor {
+		for _, person := range commit.Authors() {
+			if contains(person.Name) || contains(person.Email) {
+				return true
+			}
+		}
+		return false
+	}
+	return contains(commit.Message) || contains(commit.Body)
+}
+
+// refilter applies a changed filter, keeping the selected commit selected
+// when it still passes.
+func (b *browser) refilter() {
+	selected, ok := b.selected()
+	b.shown = b.shown[:0]
+	b.cursor, b.top, b.detailTop = 0, 0, 0
+	for i, commit := range b.commits {
+		if b.matches(commit) {
+			if ok && commit.Hash == selected.Hash {
+				b.cursor = len(b.shown)
+			}
+			b.shown = append(b.shown, i)
+		}
+	}
+}
+
+func (b *browser) selected() (git.Commit, bool) {
+	if b.cursor >= len(b.shown) {
+		return git.Commit{}, false
+	}
+	return b.commits[b.shown[b.cursor]], true
+}
+
+// handle acts on a key and reports whether the browser stays open.
+func (b *browser) handle(k key) bool {
+	if k == keyCtrlC {
+		return false
+	}
+	switch {
+	case b.confirming:
+		b.confirming = false
+		b.status = ""
+		if k == 'y' || k == 'Y' {
+			b.checkout()
+		}
+		return true
+	case b.editing:
+		b.editFilter(k)
+		return true
+	case b.diff != nil:
+		b.handleDiff(k)
+		return true
+	}
+
+	b.status = ""
+	_, rows := b.screen.Size()
+	page := max(rows/2-1, 1)
+	switch k {
+	case 'q':
+		return false
+	case keyEscape:
+		if b.filter != "" {
+			b.filter = ""
+			b.refilter()
+		}
+	case keyTab:
+		b.focus = 1 - b.focus
+	case keyUp, 'k':
+		b.move(-1)
+	case keyDown, 'j':
+		b.move(1)
+	case keyPageUp:
+		b.move(-page)
+	case keyPageDown, ' ':
+		b.move(page)
+	case keyHome, 'g':
+		b.move(-len(b.shown))
+	case keyEnd, 'G':
+		b.move(len(b.shown))
+	case keyEnter, 'd':
+		if commit, ok := b.selected(); ok {
+			b.openDiff(commit)
+		}
+	case '/', '@':
+		b.editing = true
+		b.filterBy = byMessage
+		if k == '@' {
+			b.filterBy = byAuthor
+		}
+		b.filter = ""
+		b.refilter()
+	case 'y':
+		if commit, ok := b.selected(); ok {
+			b.screen.Copy(commit.Hash)
+			b.status = "Copied " + commit.Hash
+		}
+	case 'c':
+		if _, ok := b.selected(); ok && b.options.Checkout != nil {
+			b.confirming = true
+		}
+	}
+	return true
+}
+
+// move moves the cursor in the list, or scrolls the detail pane when it
+// has the focus.
+func (b *browser) move(n int) {
+	if b.focus == detailPane {
+		b.detailTop = max(b.detailTop+n, 0)
+		return
+	}
+	cursor := max(min(b.cursor+n, len(b.shown)-1), 0)
+	if cursor != b.cursor {
+		b.cursor, b.detailTop = cursor, 0
+	}
+}
+
+// editFilter edits the filter as it is typed, applying it on every key.
+func (b *browser) editFilter(k key) {
+	switch k {
+	case keyEnter:
+		b.editing = false
+		return
+	case keyEscape:
+		b.editing = false
+		b.filter = ""
+	case keyBackspace:
+		if r := []rune(b.filter); len(r) > 0 {
+			b.filter = string(r[:len(r)-1])
+		}
+	case keyUp:
+		b.move(-1)
+		return
+	case keyDown:
+		b.move(1)
+		return
+	default:
+		if k < ' ' {
+			return
+		}
+		b.filter += string(rune(k))
+	}
+	b.refilter()
+}
+
+func (b *browser) checkout() {
+	commit, ok := b.selected()
+	if !ok {
+		return
+	}
+	if err := b.options.Checkout(commit.Hash); err != nil {
+		b.status = "Error checking out " + commit.ShortHash + ": " + err.Error()
+		return
+	}
+	b.status = "Checked out " + commit.ShortHash
+}
+
+func (b *browser) openDiff(commit git.Commit) {
+	if len(commit.FileChanges) == 0 {
+		b.status = "No file changes in " + commit.ShortHash
+		return
+	}
+	b.diff = &diffView{commit: commit}
+	if b.options.Patch.Mode == "" {
+		b.options.Patch.Mode = formatter.PatchUnified
+	}
+	b.showFile(0)
+}
+
+// showFile reads the patch of the commit's file-th changed file.
+func (b *browser) showFile(file int) {
+	d := b.diff
+	d.file, d.top = file, 0
+	change := d.commit.FileChanges[file]
+	paths := []string{change.FilePath}
+	if change.OldPath != "" {
+		paths = append(paths, change.OldPath)
+	}
+	d.patch, d.err = b.repo.Patch(d.commit.Hash, paths...)
+	d.lines = nil
+}
+
+// render draws the patch for the screen's width, again only when the
+// width or the layout changed.
+func (b *browser) render(cols int) {
+	d := b.diff
+	if d.lines != nil && d.width == cols {
+		return
+	}
+	d.width = cols
+	if d.err != nil {
+		d.lines = []string{red("Error reading the diff: " + d.err.Error())}
+		return
+	}
+	var buf bytes.Buffer
+	formatter.NewRenderer(&buf, cols).PrintPatch(d.patch, b.options.Patch)
+	d.lines = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+func (b *browser) handleDiff(k key) {
+	d := b.diff
+	b.status = ""
+	cols, rows := b.screen.Size()
+	page := max(rows-3, 1)
+	switch k {
+	case 'q', keyEscape, keyBackspace:
+		b.diff = nil
+		return
+	case keyUp, 'k':
+		d.top--
+	case keyDown, 'j':
+		d.top++
+	case keyPageUp:
+		d.top -= page
+	case keyPageDown, ' ':
+		d.top += page
+	case keyHome, 'g':
+		d.top = 0
+	case keyEnd, 'G':
+		d.top = len(d.lines)
+	case keyLeft, 'h', 'p', '[':
+		if d.file > 0 {
+			b.showFile(d.file - 1)
+		}
+	case keyRight, 'l', 'n', ']', keyTab:
+		if d.file < len(d.commit.FileChanges)-1 {
+			b.showFile(d.file + 1)
+		}
+	case 'v':
+		modes := formatter.PatchModes()
+		for i, mode := range modes {
+			if mode == b.options.Patch.Mode {
+				b.options.Patch.Mode = modes[(i+1)%len(modes)]
+				break
+			}
+		}
+		d.lines = nil
+	case 'y':
+		b.screen.Copy(d.commit.Hash)
+		b.status = "Copied " + d.commit.Hash
+	}
+	b.render(cols)
+	d.top = max(min(d.top, len(d.lines)-(rows-2)), 0)
+}
+
+func (b *browser) draw() {
+	cols, rows := b.screen.Size()
+	if b.diff != nil {
+		b.screen.Draw(b.diffLines(cols, rows))
+		return
+	}
+	lines := panes(cols, rows-1, b.listLines, b.detailLines)
+	b.screen.Draw(append(lines, b.statusLine(cols)))
+}
+
+// listLines draws the commits around the cursor as compact lines, exactly
+// height of them.
+func (b *browser) listLines(width, height int) []string {
+	lines := make([]string, height)
+	if len(b.shown) == 0 {
+		switch {
+		case !b.loader.done:
+			lines[0] = dim("Reading history…")
+		case b.filter != "":
+			lines[0] = dim("No commits match")
+		default:
+			lines[0] = dim("No commits")
+		}
+		return lines
+	}
+
+	if b.cursor < b.top {
+		b.top = b.cursor
+	}
+	if b.cursor >= b.top+height {
+		b.top = b.cursor - height + 1
+	}
+	var buf bytes.Buffer
+	r := formatter.NewRenderer(&buf, width)
+	for row := 0; row < height && b.top+row < len(b.shown); row++ {
+		buf.Reset()
+		r.PrintCompact([]git.Commit{b.commits[b.shown[b.top+row]]}, false)
+		line := strings.TrimSuffix(buf.String(), "\n")
+		if b.top+row == b.cursor {
+			line = reverse + pad(formatter.StripColor(line), width) + noReverse
+		}
+		lines[row] = line
+	}
+	return lines
+}
+
+// detailLines draws the selected commit in the preview pane.
+func (b *browser) detailLines(width, height int) []string {
+	commit, ok := b.selected()
+	if !ok {
+		return make([]string, height)
+	}
+	return preview(commit, width, height, &b.detailTop)
+}
+
+// diffLines draws the diff view: the file being shown, its patch and the
+// status line.
+func (b *browser) diffLines(cols, rows int) []string {
+	d := b.diff
+	b.render(cols)
+	header := fmt.Sprintf("%s %s  %s", yellow(d.commit.ShortHash), dim(fmt.Sprintf("file %d/%d", d.file+1, len(d.commit.FileChanges))), bold(d.commit.Message))
+
+	lines := []string{header}
+	end := min(d.top+rows-2, len(d.lines))
+	lines = append(lines, d.lines[d.top:end]...)
+	for len(lines) < rows-1 {
+		lines = append(lines, dim("~"))
+	}
+	return append(lines, bar(cols, "", "↑↓ scroll  ←→ file  v "+b.options.Patch.Mode+"  y copy  q back"))
+}
+
+// statusLine shows where the cursor is in the history read so far, then
+// the filter, a message or a question, with the keys on the right.
+func (b *browser) statusLine(cols int) string {
+	position := fmt.Sprintf("%d/%d", min(b.cursor+1, len(b.shown)), len(b.shown))
+	if !b.loader.done {
+		position += "+"
+	}
+	commit, _ := b.selected()
+
+	switch {
+	case b.editing:
+		return bar(cols, fmt.Sprintf("%s  %s: %s▏", position, b.filterBy, b.filter), "⏎ keep  esc clear")
+	case b.confirming:
+		return bar(cols, fmt.Sprintf("Check out %s, detaching HEAD? (y/n)", commit.ShortHash), "")
+	}
+	left := position
+	if b.filter != "" {
+		left += fmt.Sprintf("  %s: %s", b.filterBy, b.filter)
+	}
+	if b.status != "" {
+		left += "  " + b.status
+	}
+	keys := "↑↓ move  ⏎ diff  tab pane  / message  @ author  y copy"
+	if b.options.Checkout != nil {
+		keys += "  c checkout"
+	}
+	return bar(cols, left, keys+"  q quit")
+}
@@ -0,0 +1,285 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"human-git-history/internal/formatter"
+	"human-git-history/internal/git"
+)
+
+// patchRepository serves a made-up patch for any file, which the memory
+// backend cannot diff, and records the paths asked for.
+type patchRepository struct {
+	git.Repository
+	asked [][]string
+}
+
+func (r *patchRepository) Patch(rev string, paths ...string) (string, error) {
+	r.asked = append(r.asked, paths)
+	var patch strings.Builder
+	patch.WriteString("diff --git a/" + paths[0] + " b/" + paths[0] + "\n--- a/" + paths[0] + "\n+++ b/" + paths[0] + "\n@@ -1,20 +1,20 @@\n")
+	for i := 0; i < 20; i++ {
+		patch.WriteString(" line\n")
+	}
+	return patch.String(), nil
+}
+
+// newTestBrowser browses the fixture history on a screen of cols by rows
+// drawn into a file, with the whole history read.
+func newTestBrowser(t *testing.T, cols, rows int) (*browser, *patchRepository, func() string) {
+	t.Helper()
+	formatter.SetColor(false)
+	memory, err := git.LoadFixture("../git/testdata/history.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &patchRepository{Repository: memory}
+	ctx, cancel := context.WithCancel(context.Background())
+	it, err := repo.Stream(ctx, git.CommitOptions{ShowFileChanges: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := newLoader(it)
+	t.Cleanup(func() {
+		cancel()
+		l.close()
+	})
+
+	s, drawn := newTestScreen(t, cols, rows)
+	b := &browser{repo: repo, screen: s, loader: l, filterBy: byMessage}
+	for !l.done {
+		b.fill()
+		b.add(<-l.batches)
+	}
+	return b, repo, drawn
+}
+
+// shownMessages lists the subjects of the commits the filter lets through.
+func shownMessages(b *browser) []string {
+	var messages []string
+	for _, i := range b.shown {
+		messages = append(messages, b.commits[i].Message)
+	}
+	return messages
+}
+
+func press(b *browser, keys ...key) bool {
+	open := true
+	for _, k := range keys {
+		open = b.handle(k)
+	}
+	return open
+}
+
+func typeText(b *browser, text string) {
+	for _, r := range text {
+		b.handle(key(r))
+	}
+}
+
+func TestBrowserScrolling(t *testing.T) {
+	b, _, drawn := newTestBrowser(t, 100, 8)
+	if len(b.commits) != 5 || len(b.shown) != 5 {
+		t.Fatalf("read %d commits, showing %d", len(b.commits), len(b.shown))
+	}
+
+	for _, step := range []struct {
+		keys   []key
+		cursor int
+	}{
+		{[]key{keyDown, 'j'}, 2},
+		{[]key{'G'}, 4},
+		{[]key{keyDown}, 4},
+		{[]key{keyPageUp}, 1}, // Half a screen
+		{[]key{keyUp, keyUp}, 0},
+		{[]key{keyPageDown, ' '}, 4},
+		{[]key{keyHome}, 0},
+		{[]key{keyEnd, 'g'}, 0},
+	} {
+		press(b, step.keys...)
+		if b.cursor != step.cursor {
+			t.Errorf("after %v cursor = %d, want %d", step.keys, b.cursor, step.cursor)
+		}
+	}
+
+	// The list scrolls to keep the cursor on screen; the first rows are
+	// the list, three of them at this height.
+	press(b, 'G')
+	b.draw()
+	screen := formatter.StripColor(drawn())
+	if strings.Contains(screen, "f6a1c3e") || !strings.Contains(screen, b.commits[4].ShortHash) || b.top != 2 {
+		t.Errorf("with the cursor on the last commit top = %d, screen:\n%s", b.top, screen)
+	}
+
+	// With the focus on the detail pane, the keys scroll it instead.
+	press(b, keyTab, 'j', 'j')
+	if b.cursor != 4 || b.detailTop == 0 {
+		t.Errorf("detail pane scrolling moved cursor to %d, detail to %d", b.cursor, b.detailTop)
+	}
+	press(b, keyTab, 'k')
+	if b.cursor != 3 || b.detailTop != 0 {
+		t.Errorf("back in the list, cursor = %d, detail = %d", b.cursor, b.detailTop)
+	}
+
+	if press(b, 'q') {
+		t.Error("q left the browser open")
+	}
+	if press(b, keyCtrlC) {
+		t.Error("Ctrl-C left the browser open")
+	}
+}
+
+func TestBrowserFilter(t *testing.T) {
+	b, _, drawn := newTestBrowser(t, 100, 8)
+	press(b, 'j', 'j')
+	selected := b.commits[b.shown[b.cursor]].Message
+
+	press(b, '/')
+	typeText(b, "PARSER")
+	want := []string{"Merge branch 'fix/parser' into main", "fix(parser)!: keep blank lines in bodies"}
+	if got := shownMessages(b); !reflect.DeepEqual(got, want) {
+		t.Fatalf("message filter shows %q, want %q", got, want)
+	}
+	if got := b.commits[b.shown[b.cursor]].Message; got != selected {
+		t.Errorf("the filter moved the selection from %q to %q", selected, got)
+	}
+	b.draw()
+	if screen := drawn(); !strings.Contains(screen, "2/2  message: PARSER") {
+		t.Errorf("status line while editing:\n%s", screen)
+	}
+
+	// Backspace widens the filter; q is typed into it, not quitting.
+	press(b, keyBackspace, keyBackspace, keyBackspace, keyBackspace, keyBackspace, keyBackspace)
+	if !press(b, 'q') || b.filter != "q" {
+		t.Fatalf("typing q in the filter: filter = %q", b.filter)
+	}
+	if len(b.shown) != 0 {
+		t.Errorf("filter q shows %q", shownMessages(b))
+	}
+	b.draw()
+	if screen := drawn(); !strings.Contains(screen, "No commits match") {
+		t.Errorf("an empty filtered list:\n%s", screen)
+	}
+
+	// Enter keeps the filter, Escape then clears it.
+	press(b, keyBackspace)
+	typeText(b, "blank")
+	press(b, keyEnter)
+	if b.editing || len(b.shown) != 1 {
+		t.Fatalf("after Enter editing = %v, showing %q", b.editing, shownMessages(b))
+	}
+	press(b, keyEscape)
+	if b.filter != "" || len(b.shown) != 5 {
+		t.Errorf("Escape left filter %q showing %d commits", b.filter, len(b.shown))
+	}
+
+	// @ filters on authors and co-authors.
+	press(b, '@')
+	typeText(b, "sam@")
+	want = []string{"feat(web): render diffs | side by side", "Merge branch 'fix/parser' into main", "fix(parser)!: keep blank lines in bodies"}
+	if got := shownMessages(b); b.filterBy != byAuthor || !reflect.DeepEqual(got, want) {
+		t.Errorf("author filter shows %q, want %q", got, want)
+	}
+	press(b, keyEscape)
+	if b.editing || b.filter != "" || len(b.shown) != 5 {
+		t.Errorf("Escape while editing left filter %q showing %d commits", b.filter, len(b.shown))
+	}
+}
+
+func TestBrowserDiff(t *testing.T) {
+	b, repo, drawn := newTestBrowser(t, 100, 8)
+	b.handle(keyEnter)
+	if b.diff == nil {
+		t.Fatal("Enter opened no diff")
+	}
+	if b.options.Patch.Mode != formatter.PatchUnified {
+		t.Errorf("the diff opened in mode %q", b.options.Patch.Mode)
+	}
+	b.draw()
+	drawn()
+
+	press(b, 'G')
+	if want := len(b.diff.lines) - 6; b.diff.top != want {
+		t.Errorf("End scrolled the diff to %d, want %d", b.diff.top, want)
+	}
+	press(b, keyPageUp, keyUp) // A page is the screen less the header and status
+	if want := len(b.diff.lines) - 6 - 5 - 1; b.diff.top != want {
+		t.Errorf("scrolling up went to %d, want %d", b.diff.top, want)
+	}
+	press(b, keyHome, keyUp)
+	if b.diff.top != 0 {
+		t.Errorf("scrolling above the top went to %d", b.diff.top)
+	}
+	b.draw()
+	if screen := formatter.StripColor(drawn()); !strings.Contains(screen, "f6a1c3e file 1/3  feat(web)") || !strings.Contains(screen, "M templates/commit.tpl") {
+		t.Errorf("diff screen:\n%s", screen)
+	}
+
+	// The renamed file is diffed with its old path too.
+	press(b, 'n', 'n', 'n', keyLeft, keyRight)
+	if b.diff.file != 2 {
+		t.Errorf("on file %d, want 2", b.diff.file)
+	}
+	if last := repo.asked[len(repo.asked)-1]; !reflect.DeepEqual(last, []string{"internal/web/diff view.go", "internal/web/diff.go"}) {
+		t.Errorf("the renamed file's patch was read for %q", last)
+	}
+
+	press(b, 'v')
+	if modes := formatter.PatchModes(); b.options.Patch.Mode != modes[1] {
+		t.Errorf("v switched to %q", b.options.Patch.Mode)
+	}
+	if !press(b, 'q') || b.diff != nil {
+		t.Error("q did not go back to the list")
+	}
+
+	// A commit without file changes has no diff to open.
+	b.commits[b.shown[0]].FileChanges = nil
+	press(b, 'd')
+	if b.diff != nil || !strings.HasPrefix(b.status, "No file changes") {
+		t.Errorf("opening an empty diff: status %q", b.status)
+	}
+}
+
+func TestBrowserCheckout(t *testing.T) {
+	b, _, drawn := newTestBrowser(t, 100, 8)
+	press(b, 'c')
+	if b.confirming {
+		t.Fatal("c asked to check out without a Checkout option")
+	}
+
+	var checkedOut []string
+	failing := errors.New("local changes")
+	b.options.Checkout = func(hash string) error {
+		checkedOut = append(checkedOut, hash)
+		if len(checkedOut) > 1 {
+			return failing
+		}
+		return nil
+	}
+	press(b, 'j', 'c', 'n')
+	if b.confirming || len(checkedOut) != 0 {
+		t.Errorf("n checked out %q", checkedOut)
+	}
+	press(b, 'c')
+	b.draw()
+	if screen := drawn(); !strings.Contains(screen, "Check out c4e8a2f, detaching HEAD? (y/n)") {
+		t.Errorf("no question on screen:\n%s", screen)
+	}
+	press(b, 'y')
+	if !reflect.DeepEqual(checkedOut, []string{b.commits[1].Hash}) || b.status != "Checked out c4e8a2f" {
+		t.Errorf("y checked out %q, status %q", checkedOut, b.status)
+	}
+	press(b, 'c', 'Y')
+	if b.status != "Error checking out c4e8a2f: local changes" {
+		t.Errorf("a failed checkout: status %q", b.status)
+	}
+
+	press(b, 'y')
+	if screen := drawn(); !strings.Contains(screen, "\x1b]52;c;") || b.status != "Copied "+b.commits[1].Hash {
+		t.Errorf("y copied nothing: status %q", b.status)
+	}
+}
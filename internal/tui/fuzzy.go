@@ -0,0 +1,121 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Scores for fuzzyMatch. Every matched character earns scoreMatch; runs
+// of adjacent matches and matches starting a word earn more, and every
+// character skipped inside the match costs a point, so "fxbug" ranks
+// "fix bug" above "fixed the debug output".
+const (
+	scoreMatch       = 16
+	bonusConsecutive = 8
+	bonusBoundary    = 8
+	penaltyGap       = 1
+)
+
+// query is what the user typed into a fuzzy finder: terms separated by
+// spaces, each of which must match. A term in lower case matches without
+// regard to case.
+type query []string
+
+func parseQuery(text string) query {
+	return strings.Fields(text)
+}
+
+// match is a line a query matched, with the runes to highlight.
+type match struct {
+	index     int
+	score     int
+	positions []int
+}
+
+// match scores text against every term, adding up their scores. Positions
+// are rune offsets into text, in order.
+func (q query) match(index int, text string) (match, bool) {
+	m := match{index: index}
+	runes := []rune(text)
+	var lower []rune
+	for _, term := range q {
+		haystack := runes
+		if strings.ToLower(term) == term {
+			if lower == nil {
+				// Rune by rune, so positions line up with text.
+				lower = make([]rune, len(runes))
+				for i, r := range runes {
+					lower[i] = unicode.ToLower(r)
+				}
+			}
+			haystack = lower
+		}
+		score, positions, ok := fuzzyMatch([]rune(term), haystack)
+		if !ok {
+			return match{}, false
+		}
+		m.score += score
+		m.positions = append(m.positions, positions...)
+	}
+	sort.Ints(m.positions)
+	return m, true
+}
+
+// fuzzyMatch finds the runes of pattern in text, in order but not
+// necessarily adjacent. Like fzf's first algorithm it takes the first
+// place the whole pattern fits, then narrows it to the shortest window
+// ending there, which is the match scored.
+func fuzzyMatch(pattern, text []rune) (score int, positions []int, ok bool) {
+	if len(pattern) == 0 {
+		return 0, nil, true
+	}
+	// Forward: where the pattern's last rune is first reached.
+	p, end := 0, -1
+	for i, r := range text {
+		if r == pattern[p] {
+			p++
+			if p == len(pattern) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	// Backward: the latest start from which the pattern still fits.
+	p, start := len(pattern)-1, end
+	for i := end; i >= 0; i-- {
+		if text[i] == pattern[p] {
+			p--
+			if p < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	p = 0
+	for i := start; i <= end && p < len(pattern); i++ {
+		if text[i] != pattern[p] {
+			score -= penaltyGap
+			continue
+		}
+		score += scoreMatch
+		if len(positions) > 0 && positions[len(positions)-1] == i-1 {
+			score += bonusConsecutive
+		}
+		if i == 0 || isBoundary(text[i-1]) {
+			score += bonusBoundary
+		}
+		positions = append(positions, i)
+		p++
+	}
+	return score, positions, true
+}
+
+// isBoundary reports whether r ends a word, so the rune after it starts one.
+func isBoundary(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("/-_.:,;()[]{}<>\"'", r)
+}
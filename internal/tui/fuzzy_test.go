@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		pattern   string
+		text      string
+		ok        bool
+		score     int
+		positions []int
+	}{
+		{"empty pattern", "", "anything", true, 0, nil},
+		{"no match", "xyz", "fix bug", false, 0, nil},
+		{"out of order", "gub", "fix bug", false, 0, nil},
+		// 16 and 8 for a at the start, 1 off for b, 16 for c.
+		{"gap", "ac", "abc", true, 39, []int{0, 2}},
+		{"consecutive", "ab", "xab", true, 16 + 16 + 8, []int{1, 2}},
+		{"word start", "b", "a-b", true, 16 + 8, []int{2}},
+		{"inside a word", "b", "ab", true, 16, []int{1}},
+		// The window is narrowed to the last a before the b.
+		{"shortest window", "ab", "a a b", true, 16 + 8 - 1 + 16 + 8, []int{2, 4}},
+		{"unicode", "ëu", "zoë mut", true, 16 - 1 - 1 + 16, []int{2, 5}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score, positions, ok := fuzzyMatch([]rune(test.pattern), []rune(test.text))
+			if ok != test.ok {
+				t.Fatalf("fuzzyMatch(%q, %q) ok = %v, want %v", test.pattern, test.text, ok, test.ok)
+			}
+			if !ok {
+				return
+			}
+			if score != test.score || !reflect.DeepEqual(positions, test.positions) {
+				t.Errorf("fuzzyMatch(%q, %q) = %d, %v, want %d, %v", test.pattern, test.text, score, positions, test.score, test.positions)
+			}
+		})
+	}
+}
+
+// The ranking the scores are documented to give.
+func TestFuzzyMatchRanksWordStarts(t *testing.T) {
+	pattern := []rune("fxbug")
+	close, positions, ok := fuzzyMatch(pattern, []rune("fix bug"))
+	if !ok || !reflect.DeepEqual(positions, []int{0, 2, 4, 5, 6}) {
+		t.Fatalf("fix bug: ok = %v, positions = %v", ok, positions)
+	}
+	far, _, ok := fuzzyMatch(pattern, []rune("fixed the debug output"))
+	if !ok {
+		t.Fatal("fixed the debug output did not match")
+	}
+	if close <= far {
+		t.Errorf("fix bug scored %d, not above fixed the debug output at %d", close, far)
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	tests := []struct {
+		query     string
+		text      string
+		ok        bool
+		positions []int
+	}{
+		// Lower-case terms ignore case, others match it exactly.
+		{"fix", "FIX bug", true, []int{0, 1, 2}},
+		{"Fix", "fix bug", false, nil},
+		{"Fix", "Fix bug", true, []int{0, 1, 2}},
+		{"zoë", "ZOË", true, []int{0, 1, 2}},
+		// Every term has to match, anywhere; positions come out in order.
+		{"bug fix", "fix bug", true, []int{0, 1, 2, 4, 5, 6}},
+		{"bug feat", "fix bug", false, nil},
+		{"  ", "fix bug", true, nil},
+	}
+	for _, test := range tests {
+		m, ok := parseQuery(test.query).match(3, test.text)
+		if ok != test.ok {
+			t.Errorf("query %q on %q: ok = %v, want %v", test.query, test.text, ok, test.ok)
+			continue
+		}
+		if ok && (m.index != 3 || !reflect.DeepEqual(m.positions, test.positions)) {
+			t.Errorf("query %q on %q = %+v, want positions %v", test.query, test.text, m, test.positions)
+		}
+	}
+
+	// Terms add up their scores.
+	both, _ := parseQuery("fix bug").match(0, "fix bug")
+	one, _ := parseQuery("fix").match(0, "fix bug")
+	if both.score <= one.score {
+		t.Errorf("two matching terms scored %d, one %d", both.score, one.score)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	line := "\x1b[33mabc\x1b[0m déf"
+	// Positions count runes without the colour sequences: b is 1, é is 5.
+	want := "\x1b[33ma" + matchOn + "b" + matchOff + "c\x1b[0m d" + matchOn + "é" + matchOff + "f"
+	if got := highlight(line, []int{1, 5}); got != want {
+		t.Errorf("highlight = %q, want %q", got, want)
+	}
+	if got := highlight(line, nil); got != line {
+		t.Errorf("highlight without positions = %q", got)
+	}
+	// Positions past the end are ignored.
+	if got := highlight("ab", []int{1, 9}); got != "a"+matchOn+"b"+matchOff {
+		t.Errorf("highlight = %q", got)
+	}
+}
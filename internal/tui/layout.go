@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"bytes"
+	"strings"
+
+	"human-git-history/internal/formatter"
+	"human-git-history/internal/git"
+
+	"github.com/fatih/color"
+)
+
+var (
+	bold   = color.New(color.Bold).SprintFunc()
+	dim    = color.New(color.Faint).SprintFunc()
+	red    = color.New(color.FgRed).SprintFunc()
+	yellow = color.New(color.FgYellow).SprintFunc()
+)
+
+// Selection and the status line are drawn in reverse video, which stays
+// visible with colours turned off.
+const (
+	reverse   = "\x1b[7m"
+	noReverse = "\x1b[27m"
+)
+
+// sideBySide is the narrowest terminal that gets the preview pane beside
+// the list rather than below it.
+const sideBySide = 120
+
+// panes lays out a list and a preview in height rows: side by side on wide
+// terminals, the preview below the list otherwise. Both draw functions
+// return exactly the number of lines asked for.
+func panes(cols, height int, list, detail func(width, height int) []string) []string {
+	var lines []string
+	if cols >= sideBySide {
+		listWidth := cols * 2 / 5
+		left := list(listWidth, height)
+		right := detail(cols-listWidth-1, height)
+		for i := 0; i < height; i++ {
+			lines = append(lines, pad(left[i], listWidth)+dim("│")+right[i])
+		}
+		return lines
+	}
+	listHeight := max((height-1)/2, 1)
+	lines = list(cols, listHeight)
+	lines = append(lines, dim(strings.Repeat("─", cols)))
+	return append(lines, detail(cols, height-listHeight-1)...)
+}
+
+// preview draws commit in the detailed format, with its body, trailers
+// and file changes, in exactly height lines scrolled to *top. *top is
+// kept within the text.
+func preview(commit git.Commit, width, height int, top *int) []string {
+	var buf bytes.Buffer
+	formatter.NewRenderer(&buf, width).PrintDetailed([]git.Commit{commit}, true, false, true)
+	all := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	*top = max(min(*top, len(all)-height), 0)
+	lines := make([]string, height)
+	copy(lines, all[*top:])
+	return lines
+}
+
+// bar lays out a status line: left and right text in reverse video across
+// the width, the keys dropped when there is no room for them.
+func bar(cols int, left, right string) string {
+	left = " " + left
+	right += " "
+	gap := cols - formatter.DisplayWidth(left) - formatter.DisplayWidth(right)
+	if gap < 1 {
+		return reverse + pad(left, cols) + noReverse
+	}
+	return reverse + left + strings.Repeat(" ", gap) + right + noReverse
+}
+
+// pad clips s to width columns and fills it up to them with spaces.
+func pad(s string, width int) string {
+	s = formatter.Clip(s, width)
+	return s + strings.Repeat(" ", max(width-formatter.DisplayWidth(s), 0))
+}
@@ -0,0 +1,77 @@
+package tui
+
+import "human-git-history/internal/git"
+
+// batch is the next stretch of history read by a loader. Done is set on
+// the last one, with the error that ended the walk, if any.
+type batch struct {
+	commits []git.Commit
+	done    bool
+	err     error
+}
+
+// loader reads history in the background, a batch at a time and only when
+// asked, so interfaces can scroll through all of it while reading no more
+// than they show.
+type loader struct {
+	it       git.CommitIterator
+	requests chan int
+	batches  chan batch
+	pending  bool
+	done     bool
+}
+
+func newLoader(it git.CommitIterator) *loader {
+	l := &loader{
+		it:       it,
+		requests: make(chan int),
+		batches:  make(chan batch, 1),
+	}
+	go l.run()
+	return l
+}
+
+// run is the only goroutine touching the iterator.
+func (l *loader) run() {
+	defer close(l.batches)
+	for n := range l.requests {
+		var b batch
+		for len(b.commits) < n && l.it.Next() {
+			b.commits = append(b.commits, l.it.Commit())
+		}
+		if len(b.commits) < n {
+			b.done, b.err = true, l.it.Err()
+		}
+		l.batches <- b
+		if b.done {
+			return
+		}
+	}
+}
+
+// more asks for up to n more commits, unless a batch is still being read
+// or history has run out.
+func (l *loader) more(n int) {
+	if l.pending || l.done {
+		return
+	}
+	l.pending = true
+	l.requests <- n
+}
+
+// received records that b arrived, so more may ask again.
+func (l *loader) received(b batch) {
+	l.pending = false
+	l.done = b.done
+}
+
+// close stops the walk. The iterator's context must already be cancelled,
+// so a batch being read ends early.
+func (l *loader) close() {
+	if !l.done {
+		close(l.requests)
+	}
+	for range l.batches {
+	}
+	l.it.Close()
+}
@@ -0,0 +1,356 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"human-git-history/internal/formatter"
+	"human-git-history/internal/git"
+)
+
+// ErrCancelled is returned by Pick when the user leaves without picking.
+var ErrCancelled = errors.New("cancelled")
+
+// pickBatch is how many commits the picker reads at a time. It reads the
+// whole history, since any commit may match, but a batch at a time so the
+// first ones can be picked while the rest are read.
+const pickBatch = 500
+
+// Control keys the picker binds beyond the browser's.
+const (
+	keyCtrlB key = 0x02
+	keyCtrlF key = 0x06
+	keyCtrlJ key = 0x0a
+	keyCtrlK key = 0x0b
+	keyCtrlN key = 0x0e
+	keyCtrlP key = 0x10
+	keyCtrlU key = 0x15
+	keyCtrlW key = 0x17
+)
+
+// Matched characters are bold and underlined; turning off only those
+// attributes keeps the line's colours.
+const (
+	matchOn  = "\x1b[1;4m"
+	matchOff = "\x1b[22;24m"
+)
+
+// PickOptions configure Pick.
+type PickOptions struct {
+	Multi bool   // Tab marks commits, and every marked commit is picked
+	Query string // Typed in before the finder opens
+}
+
+// picker is the state of `git-history pick`.
+type picker struct {
+	screen  *screen
+	loader  *loader
+	options PickOptions
+
+	commits []git.Commit
+	lines   []string // Compact line of each commit, in colour
+	plain   []string // The same lines without colours, as matched
+
+	input      string
+	matches    []match // Best first
+	cursor     int
+	top        int
+	previewTop int
+	marked     map[int]bool // By index into commits
+}
+
+// Pick shows a fuzzy finder over the history options select, one compact
+// line per commit with the commit under the cursor previewed beside them.
+// It returns the commit picked with Enter or, with Multi, the commits
+// marked with Tab, newest first. Leaving with Escape or Ctrl-C returns
+// ErrCancelled.
+func Pick(repo git.Repository, commitOptions git.CommitOptions, options PickOptions) ([]git.Commit, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it, err := repo.Stream(ctx, commitOptions)
+	if err != nil {
+		return nil, err
+	}
+	l := newLoader(it)
+	defer func() {
+		cancel()
+		l.close()
+	}()
+
+	s, err := openScreen()
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	p := &picker{screen: s, loader: l, options: options, input: options.Query, marked: make(map[int]bool)}
+	batches := l.batches
+	var readErr error
+	for {
+		l.more(pickBatch)
+		p.draw()
+		select {
+		case k, ok := <-s.keys:
+			if !ok {
+				return nil, ErrCancelled
+			}
+			if picked, done := p.handle(k); done {
+				if picked == nil {
+					return nil, ErrCancelled
+				}
+				return picked, nil
+			}
+		case next, ok := <-batches:
+			if !ok {
+				batches = nil
+				continue
+			}
+			l.received(next)
+			p.add(next.commits)
+			if next.err != nil {
+				readErr = next.err
+			}
+		case <-s.resized:
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+}
+
+// add renders and scores newly read commits. Lines are rendered at full
+// length and clipped when drawn, so match positions stay valid at any
+// width.
+func (p *picker) add(commits []git.Commit) {
+	var buf bytes.Buffer
+	r := formatter.NewRenderer(&buf, 0)
+	q := parseQuery(p.input)
+	for _, commit := range commits {
+		buf.Reset()
+		r.PrintCompact([]git.Commit{commit}, false)
+		line := strings.TrimSuffix(buf.String(), "\n")
+
+		index := len(p.commits)
+		p.commits = append(p.commits, commit)
+		p.lines = append(p.lines, line)
+		p.plain = append(p.plain, formatter.StripColor(line))
+		if m, ok := q.match(index, p.plain[index]); ok {
+			p.matches = append(p.matches, m)
+		}
+	}
+	p.sort()
+}
+
+// setInput matches the commits against a changed query. A query that
+// only grew can only match fewer lines, so just the previous matches are
+// tried again.
+func (p *picker) setInput(input string) {
+	narrowing := strings.HasPrefix(input, p.input)
+	p.input = input
+	q := parseQuery(input)
+
+	var candidates []int
+	if narrowing {
+		for _, m := range p.matches {
+			candidates = append(candidates, m.index)
+		}
+		sort.Ints(candidates)
+	} else {
+		candidates = make([]int, len(p.commits))
+		for i := range candidates {
+			candidates[i] = i
+		}
+	}
+
+	p.matches = p.matches[:0]
+	for _, index := range candidates {
+		if m, ok := q.match(index, p.plain[index]); ok {
+			p.matches = append(p.matches, m)
+		}
+	}
+	p.sort()
+	p.cursor, p.top, p.previewTop = 0, 0, 0
+}
+
+// sort puts the best matches first, newer commits first among equals.
+func (p *picker) sort() {
+	sort.SliceStable(p.matches, func(i, j int) bool {
+		if p.matches[i].score != p.matches[j].score {
+			return p.matches[i].score > p.matches[j].score
+		}
+		return p.matches[i].index < p.matches[j].index
+	})
+}
+
+// handle acts on a key. Once done, picked holds the commits chosen, or
+// nil when the user cancelled.
+func (p *picker) handle(k key) (picked []git.Commit, done bool) {
+	_, rows := p.screen.Size()
+	page := max(rows/2-2, 1)
+	switch k {
+	case keyCtrlC, keyEscape:
+		return nil, true
+	case keyEnter:
+		return p.picked(), true
+	case keyTab:
+		if p.options.Multi && p.cursor < len(p.matches) {
+			index := p.matches[p.cursor].index
+			p.marked[index] = !p.marked[index]
+			if !p.marked[index] {
+				delete(p.marked, index)
+			}
+			p.move(1)
+		}
+	case keyUp, keyCtrlP, keyCtrlK:
+		p.move(-1)
+	case keyDown, keyCtrlN, keyCtrlJ:
+		p.move(1)
+	case keyPageUp:
+		p.move(-page)
+	case keyPageDown:
+		p.move(page)
+	case keyCtrlB:
+		p.previewTop = max(p.previewTop-page, 0)
+	case keyCtrlF:
+		p.previewTop += page
+	case keyBackspace:
+		if p.input != "" {
+			_, size := utf8.DecodeLastRuneInString(p.input)
+			p.setInput(p.input[:len(p.input)-size])
+		}
+	case keyCtrlU:
+		p.setInput("")
+	case keyCtrlW:
+		input := strings.TrimRight(p.input, " ")
+		p.setInput(input[:strings.LastIndex(input, " ")+1])
+	default:
+		if k >= ' ' {
+			p.setInput(p.input + string(rune(k)))
+		}
+	}
+	return nil, false
+}
+
+func (p *picker) move(n int) {
+	cursor := max(min(p.cursor+n, len(p.matches)-1), 0)
+	if cursor != p.cursor {
+		p.cursor, p.previewTop = cursor, 0
+	}
+}
+
+// picked is what Enter picks: the marked commits in history order, or
+// the one under the cursor.
+func (p *picker) picked() []git.Commit {
+	var picked []git.Commit
+	if len(p.marked) > 0 {
+		for i, commit := range p.commits {
+			if p.marked[i] {
+				picked = append(picked, commit)
+			}
+		}
+		return picked
+	}
+	if p.cursor < len(p.matches) {
+		picked = append(picked, p.commits[p.matches[p.cursor].index])
+	}
+	return picked
+}
+
+func (p *picker) draw() {
+	cols, rows := p.screen.Size()
+	lines := []string{bold("> ") + p.input + "▏"}
+	lines = append(lines, panes(cols, rows-2, p.listLines, p.previewLines)...)
+
+	count := fmt.Sprintf("%d/%d", len(p.matches), len(p.commits))
+	if !p.loader.done {
+		count += "+"
+	}
+	if len(p.marked) > 0 {
+		count += fmt.Sprintf("  %d marked", len(p.marked))
+	}
+	keys := "↑↓ move  ⏎ pick  ^F/^B preview  esc cancel"
+	if p.options.Multi {
+		keys = "↑↓ move  tab mark  ⏎ pick  ^F/^B preview  esc cancel"
+	}
+	p.screen.Draw(append(lines, bar(cols, count, keys)))
+}
+
+// listLines draws the matches around the cursor with the matched
+// characters highlighted and marked commits flagged, exactly height of
+// them.
+func (p *picker) listLines(width, height int) []string {
+	lines := make([]string, height)
+	if len(p.matches) == 0 {
+		if p.loader.done {
+			lines[0] = dim("No commits match")
+		} else {
+			lines[0] = dim("Reading history…")
+		}
+		return lines
+	}
+
+	if p.cursor < p.top {
+		p.top = p.cursor
+	}
+	if p.cursor >= p.top+height {
+		p.top = p.cursor - height + 1
+	}
+	for row := 0; row < height && p.top+row < len(p.matches); row++ {
+		m := p.matches[p.top+row]
+		flag := "  "
+		if p.marked[m.index] {
+			flag = yellow("●") + " "
+		}
+		if p.top+row == p.cursor {
+			lines[row] = flag + reverse + pad(highlight(p.plain[m.index], m.positions), width-2) + noReverse
+			continue
+		}
+		lines[row] = flag + highlight(p.lines[m.index], m.positions)
+	}
+	return lines
+}
+
+func (p *picker) previewLines(width, height int) []string {
+	if p.cursor >= len(p.matches) {
+		return make([]string, height)
+	}
+	return preview(p.commits[p.matches[p.cursor].index], width, height, &p.previewTop)
+}
+
+// highlight marks the runes at positions, counted without colour
+// sequences, in a line that may be coloured.
+func highlight(line string, positions []int) string {
+	if len(positions) == 0 {
+		return line
+	}
+	var out strings.Builder
+	n := 0
+	for i := 0; i < len(line); {
+		if line[i] == 0x1b {
+			end := strings.IndexByte(line[i:], 'm')
+			if end < 0 {
+				end = len(line) - i - 1
+			}
+			out.WriteString(line[i : i+end+1])
+			i += end + 1
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(line[i:])
+		for len(positions) > 0 && positions[0] < n {
+			positions = positions[1:]
+		}
+		if len(positions) > 0 && positions[0] == n {
+			out.WriteString(matchOn + line[i:i+size] + matchOff)
+		} else {
+			out.WriteString(line[i : i+size])
+		}
+		i += size
+		n++
+	}
+	return out.String()
+}
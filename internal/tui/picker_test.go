@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"human-git-history/internal/formatter"
+	"human-git-history/internal/git"
+)
+
+// newTestPicker holds the fixture history, without a screen, with input
+// typed in before the commits arrive.
+func newTestPicker(t *testing.T, input string) *picker {
+	t.Helper()
+	formatter.SetColor(false)
+	repo, err := git.LoadFixture("../git/testdata/history.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits, err := repo.Log(git.CommitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &picker{input: input, marked: make(map[int]bool)}
+	// In two batches, as the loader delivers them.
+	p.add(commits[:2])
+	p.add(commits[2:])
+	return p
+}
+
+func matchedIndexes(p *picker) []int {
+	indexes := make([]int, len(p.matches))
+	for i, m := range p.matches {
+		indexes[i] = m.index
+	}
+	return indexes
+}
+
+func TestPickerSetInput(t *testing.T) {
+	p := newTestPicker(t, "")
+	if len(p.matches) != len(p.commits) || len(p.commits) != 5 {
+		t.Fatalf("empty query matched %d of %d commits", len(p.matches), len(p.commits))
+	}
+
+	// Each step is checked against a picker that matched the whole
+	// history with the final input from the start.
+	for _, input := range []string{"f", "fi", "fix", "fix b", "fix blank", "fix b", "fi", "", "Ann", "ann", "zzz", ""} {
+		p.cursor, p.top = 2, 1
+		p.setInput(input)
+		want := newTestPicker(t, input)
+		if got := matchedIndexes(p); !reflect.DeepEqual(got, matchedIndexes(want)) {
+			t.Errorf("after typing to %q matches = %v, want %v", input, got, matchedIndexes(want))
+		}
+		if p.cursor != 0 || p.top != 0 {
+			t.Errorf("after typing to %q cursor, top = %d, %d", input, p.cursor, p.top)
+		}
+	}
+
+	// Backspace widens the matches again.
+	p.setInput("fix blank")
+	narrow := matchedIndexes(p)
+	p.setInput("fix")
+	wide := matchedIndexes(p)
+	if len(wide) <= len(narrow) {
+		t.Errorf("fix matched %v, no more than fix blank with %v", wide, narrow)
+	}
+	sort.Ints(wide)
+	for _, index := range narrow {
+		if i := sort.SearchInts(wide, index); i == len(wide) || wide[i] != index {
+			t.Errorf("commit %d matched fix blank but not fix", index)
+		}
+	}
+}
+
+func TestPickerRanksBestFirst(t *testing.T) {
+	p := newTestPicker(t, "docs")
+	if len(p.matches) < 2 {
+		t.Fatalf("docs matched %d commits", len(p.matches))
+	}
+	for i := 1; i < len(p.matches); i++ {
+		a, b := p.matches[i-1], p.matches[i]
+		if a.score < b.score || a.score == b.score && a.index > b.index {
+			t.Errorf("match %d (score %d, commit %d) comes before match %d (score %d, commit %d)", i-1, a.score, a.index, i, b.score, b.index)
+		}
+	}
+	// The docs commit has the word whole, ahead of newer scattered matches.
+	if best := p.commits[p.matches[0].index]; best.ShortHash != "9b2d4f6" {
+		t.Errorf("best match is %s %q", best.ShortHash, best.Message)
+	}
+}
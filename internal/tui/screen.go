@@ -0,0 +1,196 @@
+// Package tui draws full-screen interfaces over the commit history: the
+// browser behind `git-history tui` and the fuzzy finder behind
+// `git-history pick`.
+package tui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"unicode/utf8"
+
+	"human-git-history/internal/formatter"
+	"human-git-history/internal/term"
+)
+
+// key is a key the user pressed: the character typed, or one of the
+// negative constants below for keys that type none.
+type key rune
+
+const (
+	keyUp key = -(iota + 1)
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyPageUp
+	keyPageDown
+	keyDelete
+	keyUnknown
+)
+
+// Control characters as raw mode delivers them.
+const (
+	keyCtrlC     key = 0x03
+	keyTab       key = '\t'
+	keyEnter     key = '\r'
+	keyEscape    key = 0x1b
+	keyBackspace key = 0x7f
+	keyCtrlH     key = 0x08
+)
+
+// screen is the terminal in raw mode on the alternate screen, so history
+// scrolled through leaves the shell's scrollback as it was.
+type screen struct {
+	tty     *term.TTY
+	keys    <-chan key
+	resized chan os.Signal
+	frame   bytes.Buffer
+}
+
+// openScreen takes over the terminal until Close.
+func openScreen() (*screen, error) {
+	tty, err := term.OpenTTY()
+	if err != nil {
+		return nil, fmt.Errorf("cannot open the terminal: %v", err)
+	}
+	keys := make(chan key, 64)
+	resized := make(chan os.Signal, 1)
+	term.NotifyResize(resized)
+	s := &screen{tty: tty, keys: keys, resized: resized}
+
+	// Alternate screen, hidden cursor.
+	tty.Out.WriteString("\x1b[?1049h\x1b[?25l")
+	go readKeys(tty.In, keys)
+	return s, nil
+}
+
+// Size returns the columns and rows there are to draw on.
+func (s *screen) Size() (cols, rows int) {
+	return s.tty.Size()
+}
+
+// Draw replaces the screen with lines, each clipped to the width. Tabs
+// become four spaces, since the terminal's tab stops would throw the
+// clipping off. Rows below the last line are cleared.
+func (s *screen) Draw(lines []string) {
+	cols, rows := s.Size()
+	s.frame.Reset()
+	s.frame.WriteString("\x1b[H")
+	for i := 0; i < rows; i++ {
+		if i < len(lines) {
+			s.frame.WriteString(formatter.Clip(strings.ReplaceAll(lines[i], "\t", "    "), cols))
+		}
+		s.frame.WriteString("\x1b[0m\x1b[K")
+		if i < rows-1 {
+			s.frame.WriteString("\r\n")
+		}
+	}
+	s.tty.Out.Write(s.frame.Bytes())
+}
+
+// Copy puts text on the system clipboard with the OSC 52 sequence, which
+// terminals honour locally and over ssh alike.
+func (s *screen) Copy(text string) {
+	fmt.Fprintf(s.tty.Out, "\x1b]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+}
+
+// Close gives the terminal back as it was found.
+func (s *screen) Close() error {
+	signal.Stop(s.resized)
+	s.tty.Out.WriteString("\x1b[?25h\x1b[?1049l")
+	return s.tty.Close()
+}
+
+// readKeys decodes what the terminal sends into keys until it is closed.
+func readKeys(in *os.File, keys chan<- key) {
+	buf := make([]byte, 256)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		for _, k := range decodeKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+// decodeKeys splits one read from the terminal into keys. An escape
+// arriving on its own is the Escape key; followed by more it starts the
+// sequence of a cursor or editing key.
+func decodeKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		if b[0] == byte(keyEscape) && len(b) > 1 {
+			k, n := escapeKey(b)
+			if k != keyUnknown {
+				keys = append(keys, k)
+			}
+			b = b[n:]
+			continue
+		}
+		r, size := utf8.DecodeRune(b)
+		if key(r) == keyCtrlH {
+			r = rune(keyBackspace)
+		}
+		keys = append(keys, key(r))
+		b = b[size:]
+	}
+	return keys
+}
+
+// escapeKey decodes the CSI or SS3 sequence at the start of b, returning
+// keyUnknown for sequences that name no key handled here, and the number
+// of bytes it took.
+func escapeKey(b []byte) (key, int) {
+	if b[1] != '[' && b[1] != 'O' {
+		return keyUnknown, 1 // Alt+key: the key follows on its own
+	}
+	end := 2
+	for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+		end++
+	}
+	if end == len(b) {
+		return keyUnknown, len(b)
+	}
+	params := string(b[2:end])
+	if i := strings.IndexByte(params, ';'); i >= 0 {
+		params = params[:i] // Modifiers
+	}
+	switch b[end] {
+	case 'A':
+		return keyUp, end + 1
+	case 'B':
+		return keyDown, end + 1
+	case 'C':
+		return keyRight, end + 1
+	case 'D':
+		return keyLeft, end + 1
+	case 'H':
+		return keyHome, end + 1
+	case 'F':
+		return keyEnd, end + 1
+	case 'Z':
+		return keyTab, end + 1 // Shift-Tab
+	case '~':
+		switch params {
+		case "1", "7":
+			return keyHome, end + 1
+		case "4", "8":
+			return keyEnd, end + 1
+		case "3":
+			return keyDelete, end + 1
+		case "5":
+			return keyPageUp, end + 1
+		case "6":
+			return keyPageDown, end + 1
+		}
+	}
+	return keyUnknown, end + 1
+}
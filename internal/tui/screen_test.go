@@ -0,0 +1,76 @@
+package tui
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"human-git-history/internal/term"
+)
+
+func TestDecodeKeys(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []key
+	}{
+		{"ab", []key{'a', 'b'}},
+		{"é漢", []key{'é', '漢'}},
+		{"\x1b", []key{keyEscape}},
+		{"\r\t\x7f\x08\x03", []key{keyEnter, keyTab, keyBackspace, keyBackspace, keyCtrlC}},
+		{"\x1b[A\x1b[B\x1b[C\x1b[D", []key{keyUp, keyDown, keyRight, keyLeft}},
+		{"\x1bOA\x1bOH\x1bOF", []key{keyUp, keyHome, keyEnd}},
+		{"\x1b[1~\x1b[7~\x1b[4~\x1b[8~", []key{keyHome, keyHome, keyEnd, keyEnd}},
+		{"\x1b[3~\x1b[5~\x1b[6~", []key{keyDelete, keyPageUp, keyPageDown}},
+		{"\x1b[1;5C\x1b[Z", []key{keyRight, keyTab}},
+		{"\x1b[15~x", []key{'x'}},  // F5 names no key handled here
+		{"\x1bx", []key{'x'}},      // Alt+x
+		{"q\x1b[", []key{'q'}},     // A sequence cut short
+		{"\x1b[200~y", []key{'y'}}, // Bracketed paste markers
+	} {
+		if got := decodeKeys([]byte(test.in)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("decodeKeys(%q) = %v, want %v", test.in, got, test.want)
+		}
+	}
+}
+
+// newTestScreen is a screen of cols by rows drawn into a file instead of a
+// terminal, which test reads back.
+func newTestScreen(t *testing.T, cols, rows int) (s *screen, drawn func() string) {
+	t.Helper()
+	t.Setenv("COLUMNS", strconv.Itoa(cols))
+	t.Setenv("LINES", strconv.Itoa(rows))
+	name := filepath.Join(t.TempDir(), "screen")
+	out, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { out.Close() })
+	s = &screen{tty: &term.TTY{Out: out}}
+	return s, func() string {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Truncate(0)
+		out.Seek(0, 0)
+		return string(data)
+	}
+}
+
+func TestScreenDraw(t *testing.T) {
+	s, drawn := newTestScreen(t, 10, 3)
+	s.Draw([]string{"a\tb", "0123456789abc"})
+	rows := strings.Split(strings.TrimPrefix(drawn(), "\x1b[H"), "\r\n")
+	want := []string{"a    b\x1b[0m\x1b[K", "0123456789\x1b[0m\x1b[K", "\x1b[0m\x1b[K"}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Draw wrote rows %q, want %q", rows, want)
+	}
+
+	s.Copy("f6a1c3e")
+	if got, want := drawn(), "\x1b]52;c;ZjZhMWMzZQ==\a"; got != want {
+		t.Errorf("Copy wrote %q, want %q", got, want)
+	}
+}
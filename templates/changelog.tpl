@@ -20,6 +20,10 @@
             color: #666;
             font-size: 0.9em;
         }
+        .tag-message {
+            white-space: pre-line;
+            color: var(--text-muted);
+        }
         .change-type {
             margin: 20px 0;
         }
@@ -31,6 +35,20 @@
         .change-added h4 { color: var(--secondary-color); border-color: var(--secondary-color); }
         .change-changed h4 { color: var(--warning-color); border-color: var(--warning-color); }
         .change-fixed h4 { color: var(--danger-color); border-color: var(--danger-color); }
+        .change-deprecated h4, .change-removed h4 { color: var(--warning-dark); border-color: var(--warning-dark); }
+        .change-security h4 { color: var(--info-color); border-color: var(--info-color); }
+        .change-other h4 { color: var(--gray-dark); border-color: var(--gray-dark); }
+        .change-scope {
+            font-weight: bold;
+        }
+        .breaking {
+            color: var(--danger-color);
+            font-weight: bold;
+        }
+        .breaking-note {
+            margin: 2px 0 0 20px;
+            color: var(--text-muted);
+        }
         .change-item {
             margin: 5px 0;
             padding-left: 20px;
@@ -45,39 +63,36 @@
             <p>Generated: {{.GeneratedAt | formatDate}}</p>
         </header>
 
-        {{$currentDate := ""}}
-        {{range .Commits}}
-            {{$commitDate := .AuthorDate | formatDate}}
-            {{if ne $commitDate $currentDate}}
-                {{$currentDate = $commitDate}}
-                <div class="changelog-date">
-                    <h2>{{$currentDate}}</h2>
-                </div>
-            {{end}}
-            
+        {{if .Options.Range}}
+        <p class="range">Changes in <code>{{.Options.Range}}</code></p>
+        {{end}}
+
+        {{range .Releases}}
             <div class="changelog-version">
-                <h3>{{.Message}}</h3>
-                <p class="meta">
-                    <strong>{{.AuthorName}}</strong> - {{.AuthorDate | formatTimeAgo}}
-                </p>
-                
-                {{if .Body}}
-                <div class="description">
-                    {{.Body}}
-                </div>
+                <h2>{{if .Version}}{{.Version}}{{if not .Date.IsZero}} <span class="changelog-date">{{.Date | formatDate}}</span>{{end}}{{else}}{{.Date | formatDate}}{{end}}</h2>
+                {{if .Message}}
+                <p class="tag-message">{{.Message}}</p>
                 {{end}}
-                
-                {{if .Options.ShowFiles}}
-                <div class="changes">
-                    {{range .FileChanges}}
-                    <div class="change-type change-{{.Status | lower}}">
-                        <h4>{{.Status}}</h4>
-                        <div class="change-item">
-                            <code>{{.FilePath}}</code>
-                            {{if .Insertions .Deletions}}
-                            <span class="stats">(+{{.Insertions}}/-{{.Deletions}})</span>
+                {{range .Groups}}
+                <div class="change-type change-{{.Name | lower}}">
+                    <h4>{{.Name}}</h4>
+                    {{range .Entries}}
+                    <div class="change-item">
+                        {{if .Change.Breaking}}<span class="breaking">BREAKING</span>{{end}}
+                        {{if .Change.Scope}}<span class="change-scope">{{.Change.Scope}}:</span>{{end}}
+                        {{.Change.Description}}
+                        <code title="{{.Commit.Hash}}">{{.Commit.ShortHash}}</code>
+                        <span class="meta">{{.Commit.AuthorName}}</span>
+                        {{if .Change.BreakingNote}}
+                        <div class="breaking-note">{{.Change.BreakingNote}}</div>
+                        {{end}}
+                        {{if $.Options.ShowFiles}}
+                        <ul class="files">
+                            {{range .Commit.FileChanges}}
+                            <li><code>{{.FilePath}}</code>{{if or .Insertions .Deletions}} <span class="stats">(+{{.Insertions}}/-{{.Deletions}})</span>{{end}}</li>
                             {{end}}
-                        </div>
+                        </ul>
+                        {{end}}
                     </div>
                     {{end}}
                 </div>
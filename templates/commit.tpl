@@ -1,7 +1,8 @@
 <div class="commit-card" 
+     data-hash="{{.Commit.Hash}}"
      data-author="{{.Commit.AuthorName}}"
      data-date="{{.Commit.AuthorDate | formatDate}}"
-     data-change-type="{{if .Commit.FileChanges}}{{range .Commit.FileChanges}}{{.Status | slice 0 | upper}}{{end}}{{end}}">
+     data-change-type="{{range .Commit.FileChanges}}{{printf "%.1s" .Status}}{{end}}">
     
     <!-- Commit Header -->
     <div class="commit-header">
@@ -21,17 +22,33 @@
                 {{.Commit.AuthorDate | formatTimeAgo}}
                 <small>({{.Commit.AuthorDate | formatDateTime}})</small>
             </span>
+            {{if .Commit.Recommitted}}
+            <span class="committer" title="{{.Commit.CommitterEmail}}, {{.Commit.CommitDate | formatDateTime}}">
+                <i class="fas fa-code-branch"></i>
+                committed by {{.Commit.Committer}} {{.Commit.CommitDate | formatTimeAgo}}
+            </span>
+            {{end}}
         </div>
     </div>
 
     <!-- Commit Message -->
     <div class="commit-message">
         <h3>{{.Commit.Message}}</h3>
-        {{if .Commit.Body}}
+        {{if .Commit.BodyText}}
         <div class="commit-body">
-            <p>{{.Commit.Body}}</p>
+            <p>{{.Commit.BodyText}}</p>
         </div>
         {{end}}
+        {{if .Commit.Trailers}}
+        <dl class="commit-trailers">
+            {{range .Commit.Trailers}}
+            <div class="trailer trailer-{{.Key | lower}}">
+                <dt>{{.Key}}</dt>
+                <dd>{{.Value}}</dd>
+            </div>
+            {{end}}
+        </dl>
+        {{end}}
     </div>
 
     <!-- File Changes -->
@@ -50,9 +67,12 @@
                 <span class="file-rename">
                     <i class="fas fa-arrow-right"></i>
                     {{.OldPath}}
+                    {{if and .Similarity (lt .Similarity 100)}}<span class="file-similarity">{{.Similarity}}%</span>{{end}}
                 </span>
                 {{end}}
-                {{if or .Insertions .Deletions}}
+                {{if .Binary}}
+                <span class="file-stats binary">binary</span>
+                {{else if or .Insertions .Deletions}}
                 <span class="file-stats">
                     <span class="insertions">+{{.Insertions}}</span>
                     <span class="deletions">-{{.Deletions}}</span>
@@ -64,8 +84,80 @@
     </div>
     {{end}}
 
+    <!-- Diff -->
+    {{if and .Options.Patch .Patch}}
+    {{$options := .Options}}
+    <div class="commit-diff diff-{{.Options.Patch}}">
+        <h4><i class="fas fa-code"></i> Diff</h4>
+        {{range .Patch}}
+        {{$path := .Path}}
+        <div class="diff-file">
+            <div class="diff-file-header status-{{.Status | fileStatusColor}}">
+                <span class="file-status">
+                    <i class="fas {{.Status | fileStatusIcon}}"></i>
+                    {{.Status | fileStatusText}}
+                </span>
+                <span class="file-path">{{.Path}}</span>
+                {{if and .OldPath .NewPath (ne .OldPath .NewPath)}}
+                <span class="file-rename">
+                    <i class="fas fa-arrow-right"></i>
+                    {{.OldPath}}
+                </span>
+                {{end}}
+                {{if .Binary}}<span class="file-stats binary">binary</span>{{end}}
+            </div>
+            {{range .Hunks}}
+            {{$folded := and $options.HunkLines (gt (len .Lines) $options.HunkLines)}}
+            <details class="diff-hunk"{{if not $folded}} open{{end}}>
+                <summary class="diff-hunk-header">
+                    {{.Header}} <span class="diff-section">{{.Section}}</span>
+                    {{if $folded}}<span class="diff-folded">{{len .Lines}} lines</span>{{end}}
+                </summary>
+                <table class="diff-table">
+                    {{if eq $options.Patch "side-by-side"}}
+                    {{range .Rows}}
+                    <tr>
+                        {{if .Old}}
+                        <td class="diff-num">{{.Old.Old}}</td>
+                        <td class="diff-code diff-{{lineKind .Old.Kind}}">{{code (.OldSpans $path)}}</td>
+                        {{else}}
+                        <td class="diff-num"></td><td class="diff-code diff-empty"></td>
+                        {{end}}
+                        {{if .New}}
+                        <td class="diff-num">{{.New.New}}</td>
+                        <td class="diff-code diff-{{lineKind .New.Kind}}">{{code (.NewSpans $path)}}</td>
+                        {{else}}
+                        <td class="diff-num"></td><td class="diff-code diff-empty"></td>
+                        {{end}}
+                    </tr>
+                    {{end}}
+                    {{else if eq $options.Patch "word"}}
+                    {{range .Rows}}
+                    <tr>
+                        <td class="diff-num">{{if .New}}{{.New.New}}{{else}}{{.Old.Old}}{{end}}</td>
+                        <td class="diff-code diff-words">{{code (.WordSpans $path)}}</td>
+                    </tr>
+                    {{end}}
+                    {{else}}
+                    {{range .Unified}}
+                    <tr class="diff-{{lineKind .Kind}}">
+                        <td class="diff-num">{{if .Old}}{{.Old}}{{end}}</td>
+                        <td class="diff-num">{{if .New}}{{.New}}{{end}}</td>
+                        <td class="diff-marker">{{if eq (lineKind .Kind) "added"}}+{{else if eq (lineKind .Kind) "deleted"}}-{{end}}</td>
+                        <td class="diff-code">{{code (.Spans $path)}}</td>
+                    </tr>
+                    {{end}}
+                    {{end}}
+                </table>
+            </details>
+            {{end}}
+        </div>
+        {{end}}
+    </div>
+    {{end}}
+
     <!-- Commit Stats -->
-    {{if .Options.ShowStats}}
+    {{if and .Options.ShowStats .Commit.Stats}}
     <div class="commit-stats">
         <div class="stat-item">
             <i class="fas fa-file"></i>
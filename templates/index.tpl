@@ -39,6 +39,7 @@
                 <span><i class="fas fa-calendar"></i> Generated: {{.GeneratedAt | formatDateTime}}</span>
                 <span><i class="fas fa-code-branch"></i> Commits: {{.Stats.TotalCommits}}</span>
                 <span><i class="fas fa-users"></i> Authors: {{.Stats.TotalAuthors}}</span>
+                {{if .Options.Range}}<span><i class="fas fa-code-compare"></i> Range: {{.Options.Range}}</span>{{end}}
             </div>
         </header>
 
@@ -117,15 +118,7 @@
                             <h2><i class="fas fa-calendar-day"></i> {{$currentDate}}</h2>
                         </div>
                     {{end}}
-                    tmpl, err := template.New("index.tpl").Funcs(funcMap).ParseFiles(
-    "templates/index.tpl",
-    "templates/commit.tpl",
-    "templates/stats.tpl",
-)
-if err != nil {
-    fmt.Fprintf(os.Stderr, "Error parsing templates: %v\n", err)
-    os.Exit(1)
-}
+                    {{template "commit.tpl" (dict "Commit" . "Options" $.Options "Patch" (index $.Patches .Hash))}}
 
                 {{end}}
             {{else if .Options.GroupByAuthor}}
@@ -137,30 +130,12 @@ if err != nil {
                             <h2><i class="fas fa-user"></i> {{$currentAuthor}}</h2>
                         </div>
                     {{end}}
-                    <div class="commit-card" 
-     data-author="{{.AuthorName}}" 
-     data-date="{{.AuthorDate | formatDate}}">
-    <div class="commit-header">
-        <span class="commit-hash">{{.ShortHash}}</span>
-        <span class="commit-author">{{.AuthorName}}</span>
-        <span class="commit-date">{{.AuthorDate | formatDateTime}}</span>
-    </div>
-    <div class="commit-message">{{.Message}}</div>
-</div>
+                    {{template "commit.tpl" (dict "Commit" . "Options" $.Options "Patch" (index $.Patches .Hash))}}
 
                 {{end}}
             {{else}}
                 {{range .Commits}}
-                    <div class="commit-card" 
-     data-author="{{.AuthorName}}" 
-     data-date="{{.AuthorDate | formatDate}}">
-    <div class="commit-header">
-        <span class="commit-hash">{{.ShortHash}}</span>
-        <span class="commit-author">{{.AuthorName}}</span>
-        <span class="commit-date">{{.AuthorDate | formatDateTime}}</span>
-    </div>
-    <div class="commit-message">{{.Message}}</div>
-</div>
+                    {{template "commit.tpl" (dict "Commit" . "Options" $.Options "Patch" (index $.Patches .Hash))}}
 
                 {{end}}
             {{end}}
@@ -272,6 +247,19 @@ if err != nil {
             URL.revokeObjectURL(url);
         }
 
+        // Copy a commit's full hash
+        function copyHash(hash) {
+            navigator.clipboard.writeText(hash);
+        }
+
+        // Open every hunk of a commit's diff, folded ones included
+        function viewCommitDetails(hash) {
+            const card = document.querySelector('.commit-card[data-hash="' + hash + '"]');
+            if (!card) return;
+            card.querySelectorAll('.diff-hunk').forEach(hunk => hunk.open = true);
+            card.scrollIntoView({ behavior: 'smooth' });
+        }
+
         // Scroll to top
         function scrollToTop() {
             window.scrollTo({ top: 0, behavior: 'smooth' });